@@ -0,0 +1,43 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+// creditAuthors credits the authors of the books created before authors were
+// introduced, as the create_authors migration is applied. The free text
+// author line of every book is split into names, and an author is created for
+// every name that doesn't match an existing one, ignoring case. Only the
+// columns the books table had back then are read.
+func creditAuthors(tx *gorm.DB) error {
+	var books []struct {
+		ID     uint
+		Author string
+	}
+	if err := tx.Table("books").Select("id, coalesce(author, '') AS author").Order("id").Find(&books).Error; err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		for i, name := range models.SplitAuthors(book.Author) {
+			var author models.Author
+			err := tx.Where("name = ? COLLATE NOCASE", name).Order("id").First(&author).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				author = models.Author{Name: name}
+				err = tx.Create(&author).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			credit := models.BookAuthor{BookID: book.ID, AuthorID: author.ID, Role: models.RoleAuthor, Position: i + 1}
+			if err := tx.Create(&credit).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,26 +1,81 @@
 package database
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"strconv"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/nsltharaka/booksapi/models"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// ErrNoDatabase is returned when SQLITE_FILENAME doesn't name the database.
+var ErrNoDatabase = errors.New("SQLITE_FILENAME is not set")
+
+// Connect opens the database and makes sure its schema is up to date. A new
+// database is migrated right away, while pending migrations are only applied
+// to an existing database when AUTO_MIGRATE is true, ErrSchemaBehind being
+// returned otherwise so that they can be applied with `migrate up`.
 func Connect() (*gorm.DB, error) {
+	db, err := Open()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSchema(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Open opens the database named by SQLITE_FILENAME, leaving its schema as it
+// is.
+func Open() (*gorm.DB, error) {
 	dbFile := os.Getenv("SQLITE_FILENAME")
 	if dbFile == "" {
-		dbFile = "books.db"
+		return nil, ErrNoDatabase
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
+	return gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
+}
+
+// checkSchema applies the pending migrations to db when it is new or when
+// AUTO_MIGRATE is true, and fails when migrations are still pending or when
+// db has migrations applied that this build doesn't know. The migrations
+// needing a module SQLite was built without don't count as pending.
+func checkSchema(db *gorm.DB) error {
+	states, err := MigrationStatus(db)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	pending := 0
+	for _, state := range states {
+		if state.Unknown {
+			return fmt.Errorf("%w: migration %s was applied by a newer build", ErrSchemaAhead, state.Migration)
+		}
+		if state.AppliedAt == nil && !state.Unavailable {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return nil
 	}
 
-	db.AutoMigrate(&models.Book{})
+	empty, err := isEmpty(db)
+	if err != nil {
+		return err
+	}
+	if auto, _ := strconv.ParseBool(os.Getenv("AUTO_MIGRATE")); !empty && !auto {
+		return fmt.Errorf("%w: %d migrations are pending, run `migrate up` or set AUTO_MIGRATE=true", ErrSchemaBehind, pending)
+	}
 
-	return db, nil
+	applied, err := MigrateUp(db)
+	for _, m := range applied {
+		log.Printf("applied migration %s", m)
+	}
+	return err
 }
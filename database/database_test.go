@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"os"
 	"testing"
 )
@@ -10,8 +11,8 @@ func TestConnection(t *testing.T) {
 	t.Run("returns error if SQLITE_FILENAME is not set", func(t *testing.T) {
 		os.Unsetenv("SQLITE_FILENAME")
 		_, err := Connect()
-		if err == nil {
-			t.Fatal("expected an error, got nil")
+		if !errors.Is(err, ErrNoDatabase) {
+			t.Fatalf("expected ErrNoDatabase, got %v", err)
 		}
 	})
 
@@ -0,0 +1,374 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var (
+	// ErrSchemaBehind is returned by Connect when migrations are pending.
+	ErrSchemaBehind = errors.New("the database schema is behind")
+	// ErrSchemaAhead is returned when the database has migrations applied
+	// that this build doesn't know, so they can neither be trusted nor
+	// reverted.
+	ErrSchemaAhead = errors.New("the database schema is ahead of this build")
+	// ErrUnknownMigration is returned when migrating to a version that isn't
+	// one of the migrations.
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// Migration is a change to the schema, along with the change reverting it.
+// Requires names the SQLite module the migration needs, eg: fts5, given by a
+// `-- requires: <module>` line at the top of its up file.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Requires string
+
+	// backfill fills in the data the SQL of the migration can't, after it
+	// ran, in the same transaction.
+	backfill func(tx *gorm.DB) error
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationState tells whether a migration has been applied to a database.
+// Migrations applied to the database that this build doesn't know are
+// Unknown, and have no SQL. Pending migrations needing a module SQLite was
+// built without are Unavailable, and are left pending when migrating.
+type MigrationState struct {
+	Migration
+	AppliedAt   *time.Time
+	Unknown     bool
+	Unavailable bool
+}
+
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+var (
+	migrationFilename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+	migrationRequires = regexp.MustCompile(`^-- requires: (\w+)$`)
+)
+
+// backfills are the data changes of the migrations, by migration name, that
+// SQL alone can't make.
+var backfills = map[string]func(tx *gorm.DB) error{
+	"create_authors": creditAuthors,
+}
+
+// Migrations returns the migrations embedded in the build, ordered by
+// version.
+var Migrations = sync.OnceValues(func() ([]Migration, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	for name, backfill := range backfills {
+		i := slices.IndexFunc(migrations, func(m Migration) bool { return m.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("no migration %s to backfill", name)
+		}
+		migrations[i].backfill = backfill
+	}
+	return migrations, nil
+})
+
+// loadMigrations reads the migrations in dir of fsys, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. Every migration
+// needs both. The module the migration requires is read from the leading
+// comments of its up file.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilename.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("migration %s has no version", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", m, entry.Name(), version)
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+			m.Requires = requiredModule(m.Up)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// requiredModule returns the module named by a `-- requires: <module>` line
+// among the comments heading sql, if any.
+func requiredModule(sql string) string {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if match := migrationRequires.FindStringSubmatch(line); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// hasModule reports whether the SQLite of db was compiled with module, eg:
+// fts5 with `-tags sqlite_fts5`.
+func hasModule(db *gorm.DB, module string) (bool, error) {
+	var enabled bool
+	err := db.Raw("SELECT sqlite_compileoption_used(?)", "ENABLE_"+strings.ToUpper(module)).Scan(&enabled).Error
+	return enabled, err
+}
+
+// MigrationStatus returns the state of every migration, followed by the
+// unknown migrations applied to db.
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		state := MigrationState{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			state.AppliedAt = &row.AppliedAt
+			delete(applied, m.Version)
+		} else if m.Requires != "" {
+			enabled, err := hasModule(db, m.Requires)
+			if err != nil {
+				return nil, err
+			}
+			state.Unavailable = !enabled
+		}
+		states = append(states, state)
+	}
+	for _, row := range applied {
+		states = append(states, MigrationState{
+			Migration: Migration{Version: row.Version, Name: row.Name},
+			AppliedAt: &row.AppliedAt,
+			Unknown:   true,
+		})
+	}
+	slices.SortStableFunc(states[len(migrations):], func(a, b MigrationState) int { return a.Version - b.Version })
+	return states, nil
+}
+
+// SchemaVersion returns the version of the latest migration applied to db,
+// zero when there is none.
+func SchemaVersion(db *gorm.DB) (int, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// MigrateUp applies the pending migrations to db, returning them.
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+	return MigrateTo(db, migrations[len(migrations)-1].Version)
+}
+
+// MigrateDown reverts the latest migration applied to db, returning it.
+func MigrateDown(db *gorm.DB) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	slices.Sort(versions)
+	previous := 0
+	if len(versions) > 1 {
+		previous = versions[len(versions)-2]
+	}
+	return MigrateTo(db, previous)
+}
+
+// MigrateTo applies the pending migrations up to version, and reverts the
+// applied migrations after it, latest first, returning them in the order
+// they ran. Version zero reverts every migration. Each migration runs in a
+// transaction of its own, so a failing one leaves db at the version before
+// it. The pending migrations requiring a module SQLite lacks are skipped,
+// staying pending.
+func MigrateTo(db *gorm.DB, version int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == version }) {
+		return nil, fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []Migration
+	for _, row := range applied {
+		if row.Version <= version {
+			continue
+		}
+		if !slices.ContainsFunc(migrations, func(m Migration) bool { return m.Version == row.Version }) {
+			return nil, fmt.Errorf("%w: can't revert migration %d_%s", ErrSchemaAhead, row.Version, row.Name)
+		}
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].Version]; ok && migrations[i].Version > version {
+			steps = append(steps, migrations[i])
+		}
+	}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok || m.Version > version {
+			continue
+		}
+		if m.Requires != "" {
+			enabled, err := hasModule(db, m.Requires)
+			if err != nil {
+				return nil, err
+			}
+			if !enabled {
+				continue
+			}
+		}
+		steps = append(steps, m)
+	}
+
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at datetime NOT NULL
+	)`).Error; err != nil {
+		return nil, fmt.Errorf("error while creating the schema_migrations table : %w", err)
+	}
+
+	var done []Migration
+	for _, m := range steps {
+		_, revert := applied[m.Version]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if revert {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error
+			}
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return err
+			}
+			if m.backfill != nil {
+				if err := m.backfill(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			action := "applying"
+			if revert {
+				action = "reverting"
+			}
+			return done, fmt.Errorf("error while %s migration %s : %w", action, m, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// appliedMigrations returns the migrations applied to db by version. A
+// database without a schema_migrations table has none.
+func appliedMigrations(db *gorm.DB) (map[int]schemaMigration, error) {
+	applied := map[int]schemaMigration{}
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		return applied, nil
+	}
+
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error while reading the schema_migrations table : %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// isEmpty reports whether db has no tables yet.
+func isEmpty(db *gorm.DB) (bool, error) {
+	var count int64
+	err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").Scan(&count).Error
+	return count == 0, err
+}
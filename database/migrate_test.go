@@ -0,0 +1,355 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+// openTestDB opens a new database named name in a temporary directory.
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	t.Setenv("SQLITE_FILENAME", filepath.Join(t.TempDir(), name))
+	db, err := Open()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return db
+}
+
+func schemaVersion(t *testing.T, db *gorm.DB) int {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return version
+}
+
+// openBaselineDB opens a new database named name with the schema databases
+// had before migrations were introduced, holding books.
+func openBaselineDB(t *testing.T, name string, books ...string) *gorm.DB {
+	db := openTestDB(t, name)
+	statements := []string{
+		"CREATE TABLE `books` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`title` text,`author` text,`year` integer)",
+		"CREATE INDEX `idx_books_deleted_at` ON `books`(`deleted_at`)",
+	}
+	for _, book := range books {
+		statements = append(statements, "INSERT INTO books (created_at, updated_at, title, author, year) VALUES (CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, "+book+")")
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	return db
+}
+
+// schema describes the columns and the indexes of every table of db, but the
+// full-text index. Columns are sorted, as the columns added by migrations come
+// after the ones of the table they were added to.
+func schema(t *testing.T, db *gorm.DB) map[string][]string {
+	var tables []string
+	if err := db.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT LIKE 'books_fts%' AND name != 'schema_migrations'").Scan(&tables).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	schema := map[string][]string{}
+	for _, table := range tables {
+		var columns []struct {
+			Name    string
+			Type    string
+			NotNull bool
+			PK      int
+		}
+		if err := db.Raw("SELECT name, type, \"notnull\" AS not_null, pk FROM pragma_table_info(?)", table).Scan(&columns).Error; err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, column := range columns {
+			schema[table] = append(schema[table], fmt.Sprintf("%s %s not null=%t pk=%d", column.Name, column.Type, column.NotNull, column.PK))
+		}
+		slices.Sort(schema[table])
+
+		var indexes []string
+		if err := db.Raw("SELECT name || ' unique=' || \"unique\" || ' partial=' || partial FROM pragma_index_list(?)", table).Scan(&indexes).Error; err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		slices.Sort(indexes)
+		schema[table] = append(schema[table], indexes...)
+	}
+	return schema
+}
+
+func TestLoadMigrations(t *testing.T) {
+
+	t.Run("loads the embedded migrations in order", func(t *testing.T) {
+		migrations, err := Migrations()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(migrations) == 0 {
+			t.Fatal("expected migrations, got none")
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Fatalf("expected migration %d, got %s", i+1, m)
+			}
+		}
+	})
+
+	t.Run("returns error for incomplete migrations", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0001_create_books.up.sql":   {Data: []byte("CREATE TABLE books (id integer)")},
+			"migrations/0001_create_books.down.sql": {Data: []byte("DROP TABLE books")},
+			"migrations/0002_create_works.up.sql":   {Data: []byte("CREATE TABLE works (id integer)")},
+		}
+		if _, err := loadMigrations(fsys, "migrations"); err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+
+		delete(fsys, "migrations/0002_create_works.up.sql")
+		fsys["migrations/0001_create_works.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE works")}
+		if _, err := loadMigrations(fsys, "migrations"); err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+	})
+
+	t.Run("reads the module a migration requires", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0001_create_books.up.sql":       {Data: []byte("-- Books.\nCREATE TABLE books (id integer)\n-- requires: json1")},
+			"migrations/0001_create_books.down.sql":     {Data: []byte("DROP TABLE books")},
+			"migrations/0002_create_books_fts.up.sql":   {Data: []byte("-- requires: fts5\n-- The index.\nCREATE VIRTUAL TABLE books_fts USING fts5(title)")},
+			"migrations/0002_create_books_fts.down.sql": {Data: []byte("DROP TABLE books_fts")},
+		}
+		migrations, err := loadMigrations(fsys, "migrations")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if migrations[0].Requires != "" || migrations[1].Requires != "fts5" {
+			t.Fatalf("expected only the second migration to require fts5, got %v", migrations)
+		}
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	latest := migrations[len(migrations)-1].Version
+	db := openTestDB(t, "test_migrate.db")
+
+	t.Run("migrates to a version", func(t *testing.T) {
+		if _, err := MigrateTo(db, 10); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if schemaVersion(t, db) != 10 {
+			t.Fatal("expected the schema at version 10")
+		}
+		if !db.Migrator().HasTable("loans") || db.Migrator().HasTable("api_keys") {
+			t.Fatal("expected the tables of the first 10 migrations")
+		}
+
+		if _, err := MigrateTo(db, latest+1); !errors.Is(err, ErrUnknownMigration) {
+			t.Fatalf("expected ErrUnknownMigration, got %v", err)
+		}
+	})
+
+	t.Run("refuses existing databases that are behind", func(t *testing.T) {
+		if _, err := Connect(); !errors.Is(err, ErrSchemaBehind) {
+			t.Fatalf("expected ErrSchemaBehind, got %v", err)
+		}
+		if schemaVersion(t, db) != 10 {
+			t.Fatal("expected the schema to be left at version 10")
+		}
+
+		t.Setenv("AUTO_MIGRATE", "true")
+		if _, err := Connect(); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if schemaVersion(t, db) != latest {
+			t.Fatalf("expected the schema at version %d", latest)
+		}
+
+		var tenants int64
+		db.Table("tenants").Where("id = ?", models.DefaultTenant).Count(&tenants)
+		if tenants != 1 {
+			t.Fatal("expected the default tenant")
+		}
+	})
+
+	t.Run("leaves migrations needing a missing module pending", func(t *testing.T) {
+		fts5, err := hasModule(db, "fts5")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		states, err := MigrationStatus(db)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		i := slices.IndexFunc(states, func(state MigrationState) bool { return state.Requires == "fts5" })
+		if i < 0 {
+			t.Fatal("expected a migration requiring fts5")
+		}
+		if applied := states[i].AppliedAt != nil; applied != fts5 || states[i].Unavailable == fts5 {
+			t.Fatalf("expected %s to be applied only when SQLite has fts5, got %+v", states[i].Migration, states[i])
+		}
+		if db.Migrator().HasTable("books_fts") != fts5 {
+			t.Fatal("expected the full-text index only when SQLite has fts5")
+		}
+
+		if _, err := Connect(); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("reverts migrations", func(t *testing.T) {
+		reverted, err := MigrateDown(db)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(reverted) != 1 || reverted[0].Version != latest || schemaVersion(t, db) != latest-1 {
+			t.Fatalf("expected migration %d to be reverted, got %v", latest, reverted)
+		}
+
+		if _, err := MigrateTo(db, 0); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if schemaVersion(t, db) != 0 || db.Migrator().HasTable("books") || db.Migrator().HasTable("books_fts") {
+			t.Fatal("expected every migration to be reverted")
+		}
+
+		if _, err := MigrateUp(db); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if schemaVersion(t, db) != latest {
+			t.Fatalf("expected the schema at version %d", latest)
+		}
+	})
+
+	t.Run("refuses databases migrated by newer builds", func(t *testing.T) {
+		db.Create(&schemaMigration{Version: latest + 1, Name: "from_the_future"})
+
+		if _, err := Connect(); !errors.Is(err, ErrSchemaAhead) {
+			t.Fatalf("expected ErrSchemaAhead, got %v", err)
+		}
+		if _, err := MigrateTo(db, 0); !errors.Is(err, ErrSchemaAhead) {
+			t.Fatalf("expected ErrSchemaAhead, got %v", err)
+		}
+
+		states, err := MigrationStatus(db)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if last := states[len(states)-1]; !last.Unknown || last.Version != latest+1 {
+			t.Fatalf("expected the unknown migration last, got %v", last)
+		}
+	})
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	migrated := openTestDB(t, "test_migrated.db")
+	if _, err := MigrateUp(migrated); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	baseline := openBaselineDB(t, "test_baseline.db", "'Book One', 'Author A', 2021")
+	if _, err := MigrateUp(baseline); err != nil {
+		t.Fatalf("expected the baseline database to be migrated, got %v", err)
+	}
+
+	modelled := openTestDB(t, "test_modelled.db")
+	err := modelled.AutoMigrate(&models.Publisher{}, &models.Work{}, &models.Series{}, &models.Book{}, &models.Author{}, &models.BookAuthor{}, &models.Subject{}, &models.BookSubject{}, &models.Branch{}, &models.Copy{}, &models.Patron{}, &models.Loan{}, &models.Hold{}, &models.Notification{}, &models.FinePolicy{}, &models.ClosedDay{}, &models.LedgerEntry{}, &models.APIKey{}, &models.Tenant{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := schema(t, modelled)
+
+	for name, db := range map[string]*gorm.DB{"migrated": migrated, "baseline": baseline} {
+		got := schema(t, db)
+		for table, columns := range want {
+			if !slices.Equal(got[table], columns) {
+				t.Errorf("%s table %s:\nexpected %v\ngot      %v", name, table, columns, got[table])
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: expected %d tables, got %d", name, len(want), len(got))
+		}
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	db := openBaselineDB(t, "test_baseline.db",
+		"'Book One', 'Author A', 2021",
+		"'Book Two', 'Smith, Tom & author a', 2022",
+	)
+	if err := db.Exec("UPDATE books SET deleted_at = CURRENT_TIMESTAMP WHERE id = 2").Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var books []models.Book
+	if err := db.Unscoped().Preload("Authors", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).Preload("Authors.Author").Order("id").Find(&books).Error; err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("books keep their details", func(t *testing.T) {
+		if len(books) != 2 || books[0].Title != "Book One" || books[1].Year != 2022 {
+			t.Fatalf("expected the books, got %v", books)
+		}
+		for _, book := range books {
+			if book.TenantID != models.DefaultTenant || book.Version != 1 {
+				t.Fatalf("expected %s at version 1 in the default tenant, got %s and %d", book.Title, book.TenantID, book.Version)
+			}
+		}
+	})
+
+	t.Run("books are credited with their authors", func(t *testing.T) {
+		var names []string
+		for _, credit := range books[1].Authors {
+			names = append(names, credit.Author.Name)
+		}
+		if !slices.Equal(names, []string{"Tom Smith", "Author A"}) {
+			t.Fatalf("expected Tom Smith and Author A, got %v", names)
+		}
+		if books[1].Authors[1].AuthorID != books[0].Authors[0].AuthorID {
+			t.Fatal("expected the authors to be matched ignoring case")
+		}
+	})
+
+	t.Run("books are works of their own", func(t *testing.T) {
+		for _, book := range books {
+			var work models.Work
+			if book.WorkID == nil || db.First(&work, *book.WorkID).Error != nil || work.Title != book.Title {
+				t.Fatalf("expected %s to be a work of its own, got %v", book.Title, work)
+			}
+		}
+		if *books[0].WorkID == *books[1].WorkID {
+			t.Fatal("expected a work for every book")
+		}
+
+		work := models.Work{Title: "Book Three"}
+		if err := db.Create(&work).Error; err != nil || work.ID != 3 {
+			t.Fatalf("expected new works to follow the existing ones, got %d, %v", work.ID, err)
+		}
+	})
+
+	t.Run("books are indexed for search", func(t *testing.T) {
+		if fts5, _ := hasModule(db, "fts5"); !fts5 {
+			t.Skip("sqlite built without fts5, run with -tags sqlite_fts5")
+		}
+		var ids []uint
+		if err := db.Raw("SELECT rowid FROM books_fts WHERE books_fts MATCH 'smith'").Scan(&ids).Error; err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !slices.Equal(ids, []uint{2}) {
+			t.Fatalf("expected Book Two, got %v", ids)
+		}
+	})
+}
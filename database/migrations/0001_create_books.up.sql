@@ -0,0 +1,14 @@
+-- The books table as it was before migrations were introduced. It is only
+-- created when missing, so that the databases set up back then are adopted
+-- as they are, the later migrations bringing them up to date.
+
+CREATE TABLE IF NOT EXISTS books (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	title text,
+	author text,
+	year integer
+);
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books (deleted_at);
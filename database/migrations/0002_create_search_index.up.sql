@@ -0,0 +1,20 @@
+-- requires: fts5
+-- The full-text index over the title and the author of the books, kept in
+-- sync by triggers. It is left pending by builds of SQLite without the fts5
+-- module, search being unavailable until it is applied.
+
+CREATE VIRTUAL TABLE books_fts USING fts5(title, author, content='books', content_rowid='id');
+
+CREATE TRIGGER books_fts_ai AFTER INSERT ON books BEGIN
+	INSERT INTO books_fts(rowid, title, author) VALUES (new.id, new.title, new.author);
+END;
+CREATE TRIGGER books_fts_ad AFTER DELETE ON books BEGIN
+	INSERT INTO books_fts(books_fts, rowid, title, author) VALUES ('delete', old.id, old.title, old.author);
+END;
+CREATE TRIGGER books_fts_au AFTER UPDATE OF title, author ON books BEGIN
+	INSERT INTO books_fts(books_fts, rowid, title, author) VALUES ('delete', old.id, old.title, old.author);
+	INSERT INTO books_fts(rowid, title, author) VALUES (new.id, new.title, new.author);
+END;
+
+-- Index the existing books.
+INSERT INTO books_fts(books_fts) VALUES ('rebuild');
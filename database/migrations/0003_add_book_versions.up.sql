@@ -0,0 +1,3 @@
+-- The version of a book is bumped on every update and is used as its ETag.
+
+ALTER TABLE books ADD COLUMN version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- ISBNs, stored as ISBN-13s.
+
+ALTER TABLE books ADD COLUMN isbn text;
+CREATE UNIQUE INDEX idx_books_isbn ON books (isbn);
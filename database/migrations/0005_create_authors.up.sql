@@ -0,0 +1,23 @@
+-- Authors, credited on books. The existing books are credited with the names
+-- of their author line once the tables are created.
+
+CREATE TABLE authors (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL
+);
+CREATE INDEX idx_authors_name ON authors (name);
+CREATE INDEX idx_authors_deleted_at ON authors (deleted_at);
+
+CREATE TABLE book_authors (
+	book_id integer,
+	author_id integer,
+	role text DEFAULT 'author',
+	position integer,
+	PRIMARY KEY (book_id, author_id, role),
+	CONSTRAINT fk_book_authors_author FOREIGN KEY (author_id) REFERENCES authors (id),
+	CONSTRAINT fk_books_authors FOREIGN KEY (book_id) REFERENCES books (id)
+);
+CREATE INDEX idx_book_authors_author_id ON book_authors (author_id);
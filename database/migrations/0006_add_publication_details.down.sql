@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_books_publisher_id;
+ALTER TABLE books DROP COLUMN published_on;
+ALTER TABLE books DROP COLUMN language;
+ALTER TABLE books DROP COLUMN page_count;
+ALTER TABLE books DROP COLUMN format;
+ALTER TABLE books DROP COLUMN edition;
+ALTER TABLE books DROP COLUMN publisher_id;
+DROP TABLE IF EXISTS publishers;
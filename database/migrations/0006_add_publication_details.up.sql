@@ -0,0 +1,19 @@
+-- Publishers, and the publication details of books.
+
+CREATE TABLE publishers (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL
+);
+CREATE INDEX idx_publishers_name ON publishers (name);
+CREATE INDEX idx_publishers_deleted_at ON publishers (deleted_at);
+
+ALTER TABLE books ADD COLUMN publisher_id integer CONSTRAINT fk_books_publisher REFERENCES publishers (id);
+ALTER TABLE books ADD COLUMN edition text;
+ALTER TABLE books ADD COLUMN format text;
+ALTER TABLE books ADD COLUMN page_count integer;
+ALTER TABLE books ADD COLUMN language text;
+ALTER TABLE books ADD COLUMN published_on date;
+CREATE INDEX idx_books_publisher_id ON books (publisher_id);
@@ -0,0 +1,19 @@
+-- Works, grouping the editions of a book.
+
+CREATE TABLE works (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	title text NOT NULL
+);
+CREATE INDEX idx_works_deleted_at ON works (deleted_at);
+
+ALTER TABLE books ADD COLUMN work_id integer CONSTRAINT fk_works_editions REFERENCES works (id);
+CREATE INDEX idx_books_work_id ON books (work_id);
+
+-- Every existing book is a work of its own, named after it. The works table
+-- being new, the work takes the id of its book.
+INSERT INTO works (id, created_at, updated_at, title)
+SELECT id, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, coalesce(title, '') FROM books;
+UPDATE books SET work_id = id;
@@ -0,0 +1,23 @@
+-- Subjects, nested under a parent, and the books assigned to them.
+
+CREATE TABLE subjects (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL,
+	parent_id integer,
+	CONSTRAINT fk_subjects_children FOREIGN KEY (parent_id) REFERENCES subjects (id)
+);
+CREATE INDEX idx_subjects_parent_id ON subjects (parent_id);
+CREATE INDEX idx_subjects_name ON subjects (name);
+CREATE INDEX idx_subjects_deleted_at ON subjects (deleted_at);
+
+CREATE TABLE book_subjects (
+	book_id integer,
+	subject_id integer,
+	PRIMARY KEY (book_id, subject_id),
+	CONSTRAINT fk_book_subjects_subject FOREIGN KEY (subject_id) REFERENCES subjects (id),
+	CONSTRAINT fk_books_subjects FOREIGN KEY (book_id) REFERENCES books (id)
+);
+CREATE INDEX idx_book_subjects_subject_id ON book_subjects (subject_id);
@@ -0,0 +1,15 @@
+-- Series, and the position of books in their reading order.
+
+CREATE TABLE series (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL
+);
+CREATE INDEX idx_series_name ON series (name);
+CREATE INDEX idx_series_deleted_at ON series (deleted_at);
+
+ALTER TABLE books ADD COLUMN series_id integer;
+ALTER TABLE books ADD COLUMN series_position real;
+CREATE INDEX idx_books_series_id ON books (series_id);
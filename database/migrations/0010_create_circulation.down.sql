@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS holds;
+DROP TABLE IF EXISTS loans;
+DROP TABLE IF EXISTS patrons;
+DROP TABLE IF EXISTS copies;
+DROP TABLE IF EXISTS branches;
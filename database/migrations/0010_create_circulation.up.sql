@@ -0,0 +1,98 @@
+-- Circulation: the branches, the copies they shelve, and the patrons
+-- borrowing and holding them.
+
+CREATE TABLE branches (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL,
+	address text
+);
+CREATE INDEX idx_branches_name ON branches (name);
+CREATE INDEX idx_branches_deleted_at ON branches (deleted_at);
+
+CREATE TABLE copies (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	book_id integer NOT NULL,
+	barcode text NOT NULL,
+	branch_id integer NOT NULL,
+	shelf text,
+	condition text,
+	status text NOT NULL DEFAULT 'available',
+	CONSTRAINT fk_copies_branch FOREIGN KEY (branch_id) REFERENCES branches (id)
+);
+CREATE INDEX idx_copies_status ON copies (status);
+CREATE INDEX idx_copies_branch_id ON copies (branch_id);
+CREATE UNIQUE INDEX idx_copies_barcode ON copies (barcode);
+CREATE INDEX idx_copies_book_id ON copies (book_id);
+CREATE INDEX idx_copies_deleted_at ON copies (deleted_at);
+
+CREATE TABLE patrons (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL,
+	email text NOT NULL
+);
+CREATE INDEX idx_patrons_email ON patrons (email);
+CREATE INDEX idx_patrons_deleted_at ON patrons (deleted_at);
+
+CREATE TABLE loans (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	copy_id integer NOT NULL,
+	patron_id integer NOT NULL,
+	checked_out_at datetime NOT NULL,
+	due_at datetime NOT NULL,
+	returned_at datetime,
+	renewals integer NOT NULL DEFAULT 0,
+	CONSTRAINT fk_loans_copy FOREIGN KEY (copy_id) REFERENCES copies (id)
+);
+CREATE INDEX idx_loans_due_at ON loans (due_at);
+CREATE INDEX idx_loans_patron_id ON loans (patron_id);
+-- A copy is lent to a single patron at a time.
+CREATE UNIQUE INDEX idx_loans_active_copy ON loans (copy_id) WHERE returned_at IS NULL;
+CREATE INDEX idx_loans_copy_id ON loans (copy_id);
+CREATE INDEX idx_loans_deleted_at ON loans (deleted_at);
+
+CREATE TABLE holds (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	book_id integer NOT NULL,
+	patron_id integer NOT NULL,
+	pickup_branch_id integer NOT NULL,
+	status text NOT NULL DEFAULT 'waiting',
+	copy_id integer,
+	ready_at datetime,
+	expires_at datetime,
+	CONSTRAINT fk_holds_pickup_branch FOREIGN KEY (pickup_branch_id) REFERENCES branches (id)
+);
+CREATE INDEX idx_holds_expires_at ON holds (expires_at);
+CREATE INDEX idx_holds_copy_id ON holds (copy_id);
+CREATE INDEX idx_holds_status ON holds (status);
+CREATE INDEX idx_holds_patron_id ON holds (patron_id);
+CREATE INDEX idx_holds_book_id ON holds (book_id);
+CREATE INDEX idx_holds_deleted_at ON holds (deleted_at);
+
+CREATE TABLE notifications (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	patron_id integer NOT NULL,
+	event text NOT NULL,
+	hold_id integer NOT NULL,
+	book_id integer NOT NULL
+);
+CREATE INDEX idx_notifications_hold_id ON notifications (hold_id);
+CREATE INDEX idx_notifications_patron_id ON notifications (patron_id);
+CREATE INDEX idx_notifications_deleted_at ON notifications (deleted_at);
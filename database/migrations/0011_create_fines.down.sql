@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS closed_days;
+DROP TABLE IF EXISTS fine_policies;
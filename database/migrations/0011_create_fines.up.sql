@@ -0,0 +1,37 @@
+-- Overdue fines: the policies they are charged by, the days branches are
+-- closed, and the ledger of the charges and payments of patrons.
+
+CREATE TABLE fine_policies (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	format text,
+	daily_rate integer,
+	grace_days integer,
+	max_fine integer
+);
+CREATE UNIQUE INDEX idx_fine_policies_format ON fine_policies (format);
+CREATE INDEX idx_fine_policies_deleted_at ON fine_policies (deleted_at);
+
+CREATE TABLE closed_days (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	branch_id integer NOT NULL,
+	date text NOT NULL
+);
+CREATE UNIQUE INDEX idx_closed_days_branch_date ON closed_days (branch_id, date);
+
+CREATE TABLE ledger_entries (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	patron_id integer NOT NULL,
+	loan_id integer,
+	kind text NOT NULL,
+	amount integer NOT NULL,
+	note text
+);
+CREATE INDEX idx_ledger_entries_loan_id ON ledger_entries (loan_id);
+CREATE INDEX idx_ledger_entries_patron_id ON ledger_entries (patron_id);
+CREATE INDEX idx_ledger_entries_deleted_at ON ledger_entries (deleted_at);
@@ -0,0 +1,16 @@
+-- API keys, of which only a hash is stored.
+
+CREATE TABLE api_keys (
+	id integer PRIMARY KEY AUTOINCREMENT,
+	created_at datetime,
+	updated_at datetime,
+	deleted_at datetime,
+	name text NOT NULL,
+	prefix text NOT NULL,
+	hash text NOT NULL,
+	scopes text NOT NULL,
+	last_used_at datetime,
+	revoked_at datetime
+);
+CREATE UNIQUE INDEX idx_api_keys_hash ON api_keys (hash);
+CREATE INDEX idx_api_keys_deleted_at ON api_keys (deleted_at);
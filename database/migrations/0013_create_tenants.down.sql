@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_books_tenant_isbn;
+CREATE UNIQUE INDEX idx_books_isbn ON books (isbn);
+ALTER TABLE books DROP COLUMN tenant_id;
+DROP TABLE IF EXISTS tenants;
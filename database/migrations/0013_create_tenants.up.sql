@@ -0,0 +1,17 @@
+-- Tenants, each owning a catalog of books. The existing books belong to the
+-- default tenant, and ISBNs are only unique within a tenant.
+
+CREATE TABLE tenants (
+	id text,
+	name text,
+	settings text,
+	created_at datetime,
+	updated_at datetime,
+	PRIMARY KEY (id)
+);
+INSERT INTO tenants (id, name, settings, created_at, updated_at)
+VALUES ('default', 'Default', '{}', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+ALTER TABLE books ADD COLUMN tenant_id text NOT NULL DEFAULT 'default';
+DROP INDEX idx_books_isbn;
+CREATE UNIQUE INDEX idx_books_tenant_isbn ON books (tenant_id, isbn);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_api_keys_tenant_id;
+ALTER TABLE api_keys DROP COLUMN tenant_id;
@@ -0,0 +1,5 @@
+-- API keys are bound to the tenant they were issued for. The keys issued
+-- before tenants were bound to the default one.
+
+ALTER TABLE api_keys ADD COLUMN tenant_id text NOT NULL DEFAULT 'default';
+CREATE INDEX idx_api_keys_tenant_id ON api_keys (tenant_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_patrons_tenant_id;
+ALTER TABLE patrons DROP COLUMN tenant_id;
@@ -0,0 +1,5 @@
+-- Patrons are members of a tenant, and so are their loans, holds and ledger.
+-- The existing patrons are members of the default tenant.
+
+ALTER TABLE patrons ADD COLUMN tenant_id text NOT NULL DEFAULT 'default';
+CREATE INDEX idx_patrons_tenant_id ON patrons (tenant_id);
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	adminLocalKey           = "admin"
+	deploymentAdminLocalKey = "deployment_admin"
+)
+
+// AdminAuth marks requests that carry the admin token in the X-Admin-Token
+// header as requests of the deployment admin, who also gets admin privileges.
+// Admin access is disabled when token is empty.
+func AdminAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provided := c.Get("X-Admin-Token")
+		if token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			c.Locals(adminLocalKey, true)
+			c.Locals(deploymentAdminLocalKey, true)
+		}
+		return c.Next()
+	}
+}
+
+// isAdmin reports whether the request has admin privileges, given by the
+// admin token or by the admin role of a key or token bound to a tenant.
+func isAdmin(c *fiber.Ctx) bool {
+	admin, _ := c.Locals(adminLocalKey).(bool)
+	return admin
+}
+
+// isDeploymentAdmin reports whether the request was made with the admin token.
+// Unlike the admin role, it isn't bound to a tenant.
+func isDeploymentAdmin(c *fiber.Ctx) bool {
+	admin, _ := c.Locals(deploymentAdminLocalKey).(bool)
+	return admin
+}
+
+// deploymentAdminOnly rejects requests not made with the admin token, for the
+// routes managing what isn't bound to a single tenant, such as credentials.
+func deploymentAdminOnly(c *fiber.Ctx) error {
+	if !isDeploymentAdmin(c) {
+		return fiber.NewError(fiber.StatusForbidden, "the admin token is required")
+	}
+	return c.Next()
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+const apiKeyLocalKey = "api_key"
+
+// APIKeyAuth requires requests to carry an API key, as a bearer token in the
+// Authorization header or in the X-API-Key header. Reading needs the
+// books:read scope and anything else books:write, while keys granted
+// books:admin get admin privileges. Keys hold the reader, librarian or admin
+// role matching their widest scope. Admin requests made with the admin token,
+// and requests already authenticated with a JWT, need no key. Each request is
+// logged along with the key that made it.
+func APIKeyAuth(service services.IAPIKeyService, logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if isDeploymentAdmin(c) {
+			err := c.Next()
+			logger.Info("request", "method", c.Method(), "path", c.Path(), "status", responseStatus(c, err), "api_key", "admin token")
+			return err
+		}
+		if _, authenticated := c.Locals(roleLocalKey).(string); authenticated {
+			return c.Next()
+		}
+
+		key := requestAPIKey(c)
+		if key == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return &problemError{status: fiber.StatusUnauthorized, code: "missing_api_key", detail: "an API key is required"}
+		}
+
+		apiKey, err := service.Authenticate(key)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			}
+			return err
+		}
+
+		scope := models.ScopeBooksWrite
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			scope = models.ScopeBooksRead
+		}
+		if !apiKey.HasScope(scope) {
+			logger.Warn("api key lacks scope", "api_key_id", apiKey.ID, "api_key", apiKey.Prefix, "scope", scope)
+			return &problemError{status: fiber.StatusForbidden, code: "insufficient_scope", detail: fmt.Sprintf("the API key needs the %s scope", scope)}
+		}
+
+		c.Locals(apiKeyLocalKey, apiKey)
+		setRole(c, apiKeyRole(apiKey))
+
+		err = c.Next()
+		logger.Info("request", "method", c.Method(), "path", c.Path(), "status", responseStatus(c, err), "api_key_id", apiKey.ID, "api_key", apiKey.Prefix)
+		return err
+	}
+}
+
+// apiKeyRole returns the role matching the widest scope of apiKey.
+func apiKeyRole(apiKey *models.APIKey) string {
+	switch {
+	case apiKey.HasScope(models.ScopeBooksAdmin):
+		return RoleAdmin
+	case apiKey.HasScope(models.ScopeBooksWrite):
+		return RoleLibrarian
+	case apiKey.HasScope(models.ScopeBooksRead):
+		return RoleReader
+	}
+	return ""
+}
+
+// requestAPIKey returns the API key sent with the request, if any.
+func requestAPIKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// responseStatus returns the status of the response to a request handled
+// with err, which ErrorHandler hasn't reported yet.
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err != nil {
+		return errorStatus(err)
+	}
+	return c.Response().StatusCode()
+}
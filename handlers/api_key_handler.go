@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService services.IAPIKeyService
+	validate      *validator.Validate
+}
+
+func NewAPIKeyHandler(service services.IAPIKeyService, validator *validator.Validate) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: service,
+		validate:      validator,
+	}
+}
+
+// apiKeys returns the API key service of the tenant the request is made for.
+func (handler *APIKeyHandler) apiKeys(c *fiber.Ctx) services.IAPIKeyService {
+	return handler.apiKeyService.ForTenant(requestTenant(c))
+}
+
+// SetupRoutes registers the API key routes, which all need the admin token:
+// keys granting admin privileges can't issue more keys. Keys are issued for
+// the tenant the request is made for, and only the keys of that tenant are
+// listed, rotated and revoked.
+func (handler *APIKeyHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/api-keys", deploymentAdminOnly, handler.getAllAPIKeys)
+	router.Post("/api-keys", deploymentAdminOnly, handler.issueAPIKey)
+	router.Post("/api-keys/:id/rotate", deploymentAdminOnly, handler.rotateAPIKey)
+	router.Delete("/api-keys/:id", deploymentAdminOnly, handler.revokeAPIKey)
+}
+
+func (handler *APIKeyHandler) getAllAPIKeys(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	apiKeys, total, err := handler.apiKeys(c).GetAllAPIKeys(page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    apiKeys,
+		Meta:    meta,
+	})
+}
+
+func (handler *APIKeyHandler) issueAPIKey(c *fiber.Ctx) error {
+	var apiKey models.APIKey
+	if err := parseBody(c, &apiKey); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&apiKey); err != nil {
+		return validationProblem(err)
+	}
+	apiKey.TenantID = requestTenant(c).ID
+
+	issuedKey, err := handler.apiKeys(c).IssueAPIKey(&apiKey)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    issuedKey,
+	})
+}
+
+func (handler *APIKeyHandler) rotateAPIKey(c *fiber.Ctx) error {
+	apiKeyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	apiKey, err := handler.apiKeys(c).RotateAPIKey(uint(apiKeyId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    apiKey,
+	})
+}
+
+func (handler *APIKeyHandler) revokeAPIKey(c *fiber.Ctx) error {
+	apiKeyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	apiKey, err := handler.apiKeys(c).RevokeAPIKey(uint(apiKeyId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    apiKey,
+	})
+}
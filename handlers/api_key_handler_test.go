@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAPIKeyTestApp serves the API key and the book routes behind the API
+// key middleware, logging requests to logs.
+func setupAPIKeyTestApp(t *testing.T, logs *bytes.Buffer) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+	validator.RegisterValidation("isbn", ValidateISBN)
+
+	service := NewMockedAPIKeyService()
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(AdminAuth(testAdminToken))
+	app.Use(APIKeyAuth(service, logger))
+
+	NewAPIKeyHandler(service, validator).SetupRoutes(app)
+	NewBookHandler(NewMockedBookService(), validator, logger).SetupRoutes(app)
+	return app
+}
+
+func TestAPIKeyHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string, headers ...string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("requests need a key", func(t *testing.T) {
+		app := setupAPIKeyTestApp(t, &bytes.Buffer{})
+		req := httptest.NewRequest("GET", "/books", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, "missing_api_key", p.Code)
+		assert.Equal(t, "Bearer", res.Header.Get("WWW-Authenticate"))
+
+		res, _ = send(app, "GET", "/books", "", "Authorization", "Bearer bk_unknown")
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("keys are checked for scopes", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		app := setupAPIKeyTestApp(t, logs)
+		res, _ := send(app, "GET", "/books", "", "Authorization", "Bearer bk_reader")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, logs.String(), "api_key_id=1")
+
+		res, _ = send(app, "DELETE", "/books/1", "", "X-API-Key", "bk_reader")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", "bk_writer")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/books/1?purge=true", "", "X-API-Key", "bk_writer")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/books/1?purge=true", "", "X-API-Key", "bk_admin")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("keys are managed with the admin token", func(t *testing.T) {
+		app := setupAPIKeyTestApp(t, &bytes.Buffer{})
+		res, _ := send(app, "POST", "/api-keys", `{"name": "sync", "scopes": ["books:read"]}`, "X-API-Key", "bk_writer")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, response := send(app, "POST", "/api-keys", `{"name": "sync", "scopes": ["books:read"]}`, "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		key := response.Data.(map[string]any)["key"].(string)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "POST", "/api-keys", `{"name": "sync", "scopes": ["books:delete"]}`, "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, response = send(app, "POST", "/api-keys/1/rotate", "", "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEqual(t, "bk_reader", response.Data.(map[string]any)["key"])
+
+		res, _ = send(app, "DELETE", "/api-keys/2", "", "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", "bk_writer")
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res, response = send(app, "GET", "/api-keys", "", "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 4)
+	})
+
+	t.Run("admin keys can't manage keys", func(t *testing.T) {
+		app := setupAPIKeyTestApp(t, &bytes.Buffer{})
+		for _, route := range [][2]string{
+			{"GET", "/api-keys"},
+			{"POST", "/api-keys"},
+			{"POST", "/api-keys/1/rotate"},
+			{"DELETE", "/api-keys/2"},
+		} {
+			res, _ := send(app, route[0], route[1], `{"name": "sync", "scopes": ["books:admin"]}`, "X-API-Key", "bk_admin")
+			assert.Equal(t, http.StatusForbidden, res.StatusCode, route)
+		}
+
+		res, _ := send(app, "GET", "/books", "", "X-API-Key", "bk_reader")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res, _ = send(app, "DELETE", "/books/1?purge=true", "", "X-API-Key", "bk_admin")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+}
+
+// mockedAPIKeyService knows the keys bk_reader, bk_writer and bk_admin, granted
+// the read, write and admin scopes for the default tenant. Keys are only
+// listed, rotated and revoked for the tenant of the last ForTenant call.
+type mockedAPIKeyService struct {
+	apiKeys []*models.APIKey
+	tenant  string
+}
+
+var _ services.IAPIKeyService = (*mockedAPIKeyService)(nil)
+
+func NewMockedAPIKeyService() *mockedAPIKeyService {
+	apiKeys := []*models.APIKey{
+		{Name: "reader", Key: "bk_reader", Scopes: []string{models.ScopeBooksRead}},
+		{Name: "writer", Key: "bk_writer", Scopes: []string{models.ScopeBooksWrite}},
+		{Name: "admin", Key: "bk_admin", Scopes: []string{models.ScopeBooksAdmin}},
+	}
+	for i, apiKey := range apiKeys {
+		apiKey.ID = uint(i + 1)
+		apiKey.Prefix = apiKey.Key
+		apiKey.TenantID = models.DefaultTenant
+	}
+	return &mockedAPIKeyService{apiKeys: apiKeys}
+}
+
+func (m *mockedAPIKeyService) ForTenant(tenant *models.Tenant) services.IAPIKeyService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedAPIKeyService) GetAllAPIKeys(page, limit int) ([]*models.APIKey, int64, error) {
+	var apiKeys []*models.APIKey
+	for _, apiKey := range m.apiKeys {
+		if apiKey.TenantID == m.tenant {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys, int64(len(apiKeys)), nil
+}
+
+func (m *mockedAPIKeyService) IssueAPIKey(apiKey *models.APIKey) (*models.APIKey, error) {
+	apiKey.ID = uint(len(m.apiKeys) + 1)
+	apiKey.Key = "bk_" + apiKey.Name
+	m.apiKeys = append(m.apiKeys, apiKey)
+	return apiKey, nil
+}
+
+func (m *mockedAPIKeyService) RotateAPIKey(id uint) (*models.APIKey, error) {
+	for _, apiKey := range m.apiKeys {
+		if apiKey.ID == id && apiKey.TenantID == m.tenant {
+			apiKey.Key += "_rotated"
+			return apiKey, nil
+		}
+	}
+	return nil, services.ErrAPIKeyNotFound
+}
+
+func (m *mockedAPIKeyService) RevokeAPIKey(id uint) (*models.APIKey, error) {
+	for _, apiKey := range m.apiKeys {
+		if apiKey.ID == id && apiKey.TenantID == m.tenant {
+			apiKey.Key = ""
+			return apiKey, nil
+		}
+	}
+	return nil, services.ErrAPIKeyNotFound
+}
+
+func (m *mockedAPIKeyService) Authenticate(key string) (*models.APIKey, error) {
+	for _, apiKey := range m.apiKeys {
+		if apiKey.Key != "" && apiKey.Key == key {
+			return apiKey, nil
+		}
+	}
+	return nil, services.ErrInvalidAPIKey
+}
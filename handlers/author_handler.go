@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type AuthorHandler struct {
+	authorService services.IAuthorService
+	validate      *validator.Validate
+}
+
+func NewAuthorHandler(service services.IAuthorService, validator *validator.Validate) *AuthorHandler {
+	return &AuthorHandler{
+		authorService: service,
+		validate:      validator,
+	}
+}
+
+func (handler *AuthorHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/authors", reader, handler.getAllAuthors)
+	router.Get("/authors/:id", reader, handler.getAuthor)
+	router.Post("/authors", librarian, handler.newAuthor)
+	router.Put("/authors/:id", librarian, handler.updateAuthor)
+	router.Delete("/authors/:id", librarian, handler.deleteAuthor)
+}
+
+func (handler *AuthorHandler) getAllAuthors(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	authors, total, err := handler.authorService.GetAllAuthors(strings.TrimSpace(c.Query("name")), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    authors,
+		Meta:    meta,
+	})
+}
+
+func (handler *AuthorHandler) getAuthor(c *fiber.Ctx) error {
+	authorId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	author, err := handler.authorService.GetAuthor(uint(authorId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    author,
+	})
+}
+
+func (handler *AuthorHandler) newAuthor(c *fiber.Ctx) error {
+	var author models.Author
+	if err := parseBody(c, &author); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&author); err != nil {
+		return validationProblem(err)
+	}
+
+	createdAuthor, err := handler.authorService.CreateAuthor(&author)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdAuthor,
+	})
+}
+
+func (handler *AuthorHandler) updateAuthor(c *fiber.Ctx) error {
+	authorId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var author models.Author
+	if err := parseBody(c, &author); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&author); err != nil {
+		return validationProblem(err)
+	}
+
+	author.ID = uint(authorId)
+	updatedAuthor, err := handler.authorService.UpdateAuthor(&author)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedAuthor,
+	})
+}
+
+func (handler *AuthorHandler) deleteAuthor(c *fiber.Ctx) error {
+	authorId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	author, err := handler.authorService.DeleteAuthor(uint(authorId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    author,
+	})
+}
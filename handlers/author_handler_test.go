@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupAuthorTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewAuthorHandler(NewMockedAuthorService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestAuthorHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("list authors", func(t *testing.T) {
+		app := setupAuthorTestApp(t)
+		res, response := send(app, "GET", "/authors?limit=1", "")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, float64(2), response.Meta.(map[string]any)["total"])
+		assert.NotEmpty(t, res.Header.Get("Link"))
+	})
+
+	t.Run("get author", func(t *testing.T) {
+		app := setupAuthorTestApp(t)
+		res, response := send(app, "GET", "/authors/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Dan Brown", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "GET", "/authors/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "GET", "/authors/xx", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("create author", func(t *testing.T) {
+		app := setupAuthorTestApp(t)
+		res, response := send(app, "POST", "/authors", `{"name": "Ann Lee"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "Ann Lee", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "POST", "/authors", `{"name": ""}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "POST", "/authors", `{"name": "dan brown"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("update author", func(t *testing.T) {
+		app := setupAuthorTestApp(t)
+		res, response := send(app, "PUT", "/authors/2", `{"name": "Thomas Smith"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Thomas Smith", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "PUT", "/authors/99", `{"name": "Nobody"}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("delete author", func(t *testing.T) {
+		app := setupAuthorTestApp(t)
+		res, _ := send(app, "DELETE", "/authors/1", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/authors/2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/authors/2", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("book credits are validated", func(t *testing.T) {
+		app := setupTestApp(t)
+		body := `{"title": "Title", "author": "Author", "year": 2000, "authors": [{"author_id": 1, "role": "narrator"}, {"role": "editor"}]}`
+		req := httptest.NewRequest("POST", "/books", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, []fieldError{
+			{Pointer: "/authors/0/role", Rule: "oneof", Message: "role must be one of author, editor, translator, illustrator"},
+			{Pointer: "/authors/1/author_id", Rule: "required", Message: "author_id is required"},
+		}, p.Errors)
+	})
+
+}
+
+type mockedAuthorService struct {
+	authors []*models.Author
+}
+
+var _ services.IAuthorService = (*mockedAuthorService)(nil)
+
+func NewMockedAuthorService() *mockedAuthorService {
+	authors := []*models.Author{
+		{Name: "Dan Brown"},
+		{Name: "Tom Smith"},
+	}
+	for i, author := range authors {
+		author.ID = uint(i + 1)
+	}
+
+	return &mockedAuthorService{authors: authors}
+}
+
+func (m *mockedAuthorService) GetAllAuthors(name string, page, limit int) ([]*models.Author, int64, error) {
+	start := min((page-1)*limit, len(m.authors))
+	end := min(start+limit, len(m.authors))
+	return m.authors[start:end], int64(len(m.authors)), nil
+}
+
+func (m *mockedAuthorService) GetAuthor(id uint) (*models.Author, error) {
+	for _, author := range m.authors {
+		if author.ID == id {
+			return author, nil
+		}
+	}
+	return nil, services.ErrAuthorNotFound
+}
+
+func (m *mockedAuthorService) CreateAuthor(author *models.Author) (*models.Author, error) {
+	for _, existing := range m.authors {
+		if strings.EqualFold(existing.Name, author.Name) {
+			return nil, services.ErrDuplicateAuthor
+		}
+	}
+	author.ID = uint(len(m.authors) + 1)
+	m.authors = append(m.authors, author)
+	return author, nil
+}
+
+func (m *mockedAuthorService) UpdateAuthor(payload *models.Author) (*models.Author, error) {
+	author, err := m.GetAuthor(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	author.Name = payload.Name
+	return author, nil
+}
+
+// DeleteAuthor treats the first author as credited on a book.
+func (m *mockedAuthorService) DeleteAuthor(id uint) (*models.Author, error) {
+	author, err := m.GetAuthor(id)
+	if err != nil {
+		return nil, err
+	}
+	if id == 1 {
+		return nil, services.ErrAuthorInUse
+	}
+	m.authors = slices.DeleteFunc(m.authors, func(a *models.Author) bool { return a.ID == id })
+	return author, nil
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -10,51 +13,62 @@ import (
 	"github.com/nsltharaka/booksapi/services"
 )
 
-func ErrorHandler(c *fiber.Ctx, err error) error {
-
-	code := fiber.StatusInternalServerError
-
-	var e *fiber.Error
-	if errors.As(err, &e) {
-		code = e.Code
-	}
-
-	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
-
-	return c.Status(code).JSON(apiResponse{
-		Message: "error",
-		Error:   err.Error(),
-	})
-}
-
 type BookHandler struct {
 	bookService services.IBookService
 	validate    *validator.Validate
+	logger      *slog.Logger
 }
 
-func NewBookHandler(service services.IBookService, validator *validator.Validate) *BookHandler {
+func NewBookHandler(service services.IBookService, validator *validator.Validate, logger *slog.Logger) *BookHandler {
 	return &BookHandler{
 		bookService: service,
 		validate:    validator,
+		logger:      logger,
 	}
 }
 
+// books returns the book service of the tenant the request is made for.
+func (handler *BookHandler) books(c *fiber.Ctx) services.IBookService {
+	return handler.bookService.ForTenant(requestTenant(c))
+}
+
 func (handler *BookHandler) SetupRoutes(router fiber.Router) {
-	router.Get("/books", handler.getAllBooks)
-	router.Get("/books/:id", handler.getBook)
-	router.Post("/books", handler.newBook)
-	router.Put("/books/:id", handler.updateBook)
-	router.Delete("/books/:id", handler.deleteBook)
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/books", reader, handler.getAllBooks)
+	router.Get("/books/search", reader, handler.searchBooks)
+	router.Get("/books/trash", librarian, handler.getTrashedBooks)
+	router.Get("/books/export", reader, handler.exportBooks)
+	router.Get("/books/subjects", reader, handler.getSubjectCounts)
+	router.Get("/books/isbn/:isbn", reader, handler.getBookByISBN)
+	router.Get("/books/:id", reader, handler.getBook)
+	router.Post("/books", librarian, handler.newBook)
+	router.Post("/books/bulk", librarian, handler.bulkBooks)
+	router.Post("/books/import", librarian, handler.importBooks)
+	router.Put("/books/:id", librarian, handler.updateBook)
+	router.Patch("/books/:id", librarian, handler.patchBook)
+	router.Delete("/books/:id", librarian, handler.deleteBook)
+	router.Post("/books/:id/restore", librarian, handler.restoreBook)
 }
 
-func (handler *BookHandler) getAllBooks(c *fiber.Ctx) error {
+// parseBody decodes the request body into out. Unlike a bare BodyParser call,
+// a malformed body is reported rather than leaving out half filled.
+func parseBody(c *fiber.Ctx, out any) error {
+	if err := c.BodyParser(out); err != nil {
+		if errors.Is(err, fiber.ErrUnprocessableEntity) {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid Content-Type")
+		}
+		return bodyProblem(err)
+	}
+	return nil
+}
 
-	page := c.QueryInt("page")
+func paginationParams(c *fiber.Ctx) (page, limit int) {
+	page = c.QueryInt("page")
 	if page <= 0 {
 		page = 1
 	}
 
-	limit := c.QueryInt("limit")
+	limit = c.QueryInt("limit")
 	switch {
 	case limit > 100:
 		limit = 100
@@ -62,14 +76,136 @@ func (handler *BookHandler) getAllBooks(c *fiber.Ctx) error {
 		limit = 10
 	}
 
-	books, err := handler.bookService.GetAllBooks(page, limit)
+	return page, limit
+}
+
+// bookQuery reads the filters, sort order and page of a book list request.
+func (handler *BookHandler) bookQuery(c *fiber.Ctx) (services.BookQuery, error) {
+	var query services.BookQuery
+	if err := c.QueryParser(&query); err != nil {
+		return query, fiber.NewError(fiber.StatusBadRequest, "invalid query parameter")
+	}
+
+	if err := handler.validate.Struct(&query); err != nil {
+		return query, validationProblem(err)
+	}
+
+	sort, err := services.ParseSort(c.Query("sort"))
+	if err != nil {
+		return query, err
+	}
+
+	query.Page, query.Limit = paginationParams(c)
+	query.Sort = sort
+	return query, nil
+}
+
+func (handler *BookHandler) getAllBooks(c *fiber.Ctx) error {
+
+	query, err := handler.bookQuery(c)
 	if err != nil {
 		return err
 	}
 
+	switch c.Query("group_by") {
+	case "":
+	case "work":
+		if c.Request().URI().QueryArgs().Has("cursor") {
+			return fiber.NewError(fiber.StatusBadRequest, "group_by can't be used with cursor")
+		}
+		return handler.getBooksByWork(c, query)
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "invalid group_by")
+	}
+
+	if c.Request().URI().QueryArgs().Has("cursor") {
+		return handler.getBooksAfter(c, query)
+	}
+
+	books, total, err := handler.books(c).GetAllBooks(query)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, query.Page, query.Limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
 	return c.Status(http.StatusOK).JSON(apiResponse{
 		Message: "success",
 		Data:    books,
+		Meta:    meta,
+	})
+}
+
+func (handler *BookHandler) getBooksAfter(c *fiber.Ctx, query services.BookQuery) error {
+	books, next, err := handler.books(c).GetBooksAfter(query, c.Query("cursor"))
+	if err != nil {
+		return err
+	}
+
+	meta := &cursorMeta{Limit: query.Limit, NextCursor: next}
+	if next != "" {
+		c.Set(fiber.HeaderLink, cursorLink(c, next))
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    books,
+		Meta:    meta,
+	})
+}
+
+func (handler *BookHandler) getBooksByWork(c *fiber.Ctx, query services.BookQuery) error {
+	works, total, err := handler.books(c).GetBooksByWork(query)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, query.Page, query.Limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    works,
+		Meta:    meta,
+	})
+}
+
+// getSubjectCounts lists the subjects of the books matching the filters of the
+// book list, with how many of those books each subject has.
+func (handler *BookHandler) getSubjectCounts(c *fiber.Ctx) error {
+	query, err := handler.bookQuery(c)
+	if err != nil {
+		return err
+	}
+
+	counts, err := handler.books(c).GetSubjectCounts(query)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    counts,
+	})
+}
+
+func (handler *BookHandler) searchBooks(c *fiber.Ctx) error {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "missing search query")
+	}
+
+	page, limit := paginationParams(c)
+
+	results, err := handler.books(c).SearchBooks(query, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    results,
 	})
 }
 
@@ -79,38 +215,62 @@ func (handler *BookHandler) getBook(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
 	}
 
-	book, err := handler.bookService.GetBook(uint(bookId))
+	book, err := handler.books(c).GetBook(uint(bookId))
 	if err != nil {
-		if errors.Is(err, services.ErrNotFound) {
-			return fiber.NewError(fiber.StatusNotFound, err.Error())
-		}
 		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(apiResponse{
+	if book.SeriesID == nil {
+		return sendTagged(c, book.Version, apiResponse{
+			Message: "success",
+			Data:    book,
+		})
+	}
+
+	previous, next, err := handler.books(c).GetSeriesNeighbors(book)
+	if err != nil {
+		return err
+	}
+
+	meta := &seriesMeta{}
+	var links []string
+	if previous != 0 {
+		meta.Previous = &previous
+		links = append(links, bookLink(c, previous, "prev"))
+	}
+	if next != 0 {
+		meta.Next = &next
+		links = append(links, bookLink(c, next, "next"))
+	}
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+
+	return sendTagged(c, book.Version, apiResponse{
 		Message: "success",
 		Data:    book,
+		Meta:    meta,
 	})
 }
 
 func (handler *BookHandler) newBook(c *fiber.Ctx) error {
 	var book models.Book
-	if err := c.BodyParser(&book); err != nil {
-		if errors.Is(err, fiber.ErrUnprocessableEntity) {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid Content-Type")
-		}
+	if err := parseBody(c, &book); err != nil {
+		return err
 	}
 
 	err := handler.validate.Struct(&book)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		return validationProblem(err)
 	}
 
-	createdBook, err := handler.bookService.CreateBook(&book)
+	createdBook, err := handler.books(c).CreateBook(&book)
 	if err != nil {
 		return err
 	}
 
+	c.Set(fiber.HeaderETag, etag(createdBook.Version))
+
 	return c.Status(http.StatusCreated).JSON(apiResponse{
 		Message: "success",
 		Data:    createdBook,
@@ -125,26 +285,29 @@ func (handler *BookHandler) updateBook(c *fiber.Ctx) error {
 	}
 
 	var book models.Book
-	if err := c.BodyParser(&book); err != nil {
-		if errors.Is(err, fiber.ErrUnprocessableEntity) {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid Content-Type")
-		}
+	if err := parseBody(c, &book); err != nil {
+		return err
 	}
 
 	err = handler.validate.Struct(&book)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		return validationProblem(err)
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
 	}
 
 	book.ID = uint(bookId)
-	updatedBook, err := handler.bookService.UpdateBook(&book)
+	book.Version = version
+	updatedBook, err := handler.books(c).UpdateBook(&book)
 	if err != nil {
-		if errors.Is(err, services.ErrNotFound) {
-			return fiber.NewError(fiber.StatusNotFound, err.Error())
-		}
 		return err
 	}
 
+	c.Set(fiber.HeaderETag, etag(updatedBook.Version))
+
 	return c.Status(fiber.StatusOK).JSON(apiResponse{
 		Message: "success",
 		Data:    updatedBook,
@@ -152,17 +315,102 @@ func (handler *BookHandler) updateBook(c *fiber.Ctx) error {
 
 }
 
+func (handler *BookHandler) patchBook(c *fiber.Ctx) error {
+	bookId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	applyPatch, err := decodePatch(c.Get(fiber.HeaderContentType), c.Body())
+	if err != nil {
+		c.Set("Accept-Patch", acceptedPatchTypes)
+		return err
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	patchedBook, err := handler.books(c).PatchBook(uint(bookId), version, func(book *models.Book) error {
+		document, err := json.Marshal(book)
+		if err != nil {
+			return err
+		}
+		original := book.Authors
+
+		patched, err := applyPatch(document)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "patch could not be applied")
+		}
+
+		var result models.Book
+		if err := json.Unmarshal(patched, &result); err != nil {
+			return bodyProblem(err)
+		}
+
+		if err := handler.validate.Struct(&result); err != nil {
+			return validationProblem(err)
+		}
+
+		book.Title = result.Title
+		book.Year = result.Year
+		book.ISBN = result.ISBN
+		book.Publisher = patchedPublisher(book.Publisher, result.Publisher)
+		book.Edition = result.Edition
+		book.Format = result.Format
+		book.PageCount = result.PageCount
+		book.Language = result.Language
+		book.PublishedOn = result.PublishedOn
+		if result.WorkID != nil {
+			book.WorkID = result.WorkID
+		}
+		book.Subjects = result.Subjects
+		book.SeriesID = result.SeriesID
+		book.SeriesPosition = result.SeriesPosition
+
+		// Credits left untouched by a patch that changes the author line are
+		// derived from the new author line again.
+		book.Authors = result.Authors
+		if result.Author != book.Author && sameCredits(result.Authors, original) {
+			book.Authors = nil
+		}
+		book.Author = result.Author
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, etag(patchedBook.Version))
+
+	return c.Status(fiber.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    patchedBook,
+	})
+}
+
 func (handler *BookHandler) deleteBook(c *fiber.Ctx) error {
 	bookId, err := c.ParamsInt("id")
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
 	}
 
-	book, err := handler.bookService.DeleteBook(uint(bookId))
+	version, err := ifMatchVersion(c)
 	if err != nil {
-		if errors.Is(err, services.ErrNotFound) {
-			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		return err
+	}
+
+	deleteBook := handler.books(c).DeleteBook
+	if c.QueryBool("purge") {
+		if !isAdmin(c) {
+			return fiber.NewError(fiber.StatusForbidden, "admin privileges required")
 		}
+		deleteBook = handler.books(c).PurgeBook
+	}
+
+	book, err := deleteBook(uint(bookId), version)
+	if err != nil {
 		return err
 	}
 
@@ -172,8 +420,46 @@ func (handler *BookHandler) deleteBook(c *fiber.Ctx) error {
 	})
 }
 
+func (handler *BookHandler) getTrashedBooks(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	books, total, err := handler.books(c).GetTrashedBooks(page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    books,
+		Meta:    meta,
+	})
+}
+
+func (handler *BookHandler) restoreBook(c *fiber.Ctx) error {
+	bookId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	book, err := handler.books(c).RestoreBook(uint(bookId))
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, etag(book.Version))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    book,
+	})
+}
+
 type apiResponse struct {
 	Message string `json:"message,omitempty"`
 	Error   string `json:"error,omitempty"`
 	Data    any    `json:"data,omitempty"`
+	Meta    any    `json:"meta,omitempty"`
 }
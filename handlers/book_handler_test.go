@@ -2,10 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"cmp"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -14,17 +21,40 @@ import (
 	"github.com/nsltharaka/booksapi/models"
 	"github.com/nsltharaka/booksapi/services"
 	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
 )
 
+const testAdminToken = "test-admin-token"
+
+// testAuth gives the role to requests that no authentication middleware gave
+// one, standing in for them in the handler tests.
+func testAuth(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, authenticated := c.Locals(roleLocalKey).(string); !authenticated && !isAdmin(c) {
+			setRole(c, role)
+		}
+		return c.Next()
+	}
+}
+
 func setupTestApp(t *testing.T) *fiber.App {
+	return setupTestAppWith(t, NewMockedBookService())
+}
+
+// setupTestAppWith serves the book routes backed by service.
+func setupTestAppWith(t *testing.T, service *mockedBookService) *fiber.App {
 	validator := validator.New(validator.WithRequiredStructEnabled())
-	mockedBookService := NewMockedBookService()
+	validator.RegisterTagNameFunc(FieldName)
+	validator.RegisterValidation("isbn", ValidateISBN)
 
-	handler := NewBookHandler(mockedBookService, validator)
+	handler := NewBookHandler(service, validator, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
 
 	app := fiber.New(fiber.Config{
 		ErrorHandler: ErrorHandler,
 	})
+	app.Use(AdminAuth(testAdminToken))
+	app.Use(testAuth(RoleLibrarian))
 
 	handler.SetupRoutes(app)
 	return app
@@ -62,6 +92,107 @@ func TestGetBooksHandler(t *testing.T) {
 		assert.Len(t, apiResponse.Data, 2)
 	})
 
+	t.Run("Get all books with pagination metadata", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books?page=2&limit=1&author=Author%20B", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse struct {
+			Meta *pageMeta `json:"meta"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, &pageMeta{Total: 1, Page: 2, Limit: 1, TotalPages: 1}, apiResponse.Meta)
+
+		req = httptest.NewRequest("GET", "/books?page=2&limit=1", nil)
+		res, err = app.Test(req, -1)
+		assert.NoError(t, err)
+
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, &pageMeta{Total: 3, Page: 2, Limit: 1, TotalPages: 3}, apiResponse.Meta)
+		links := res.Header.Get("Link")
+		assert.Contains(t, links, `<http://example.com/books?page=1&limit=1>; rel="first"`)
+		assert.Contains(t, links, `<http://example.com/books?page=1&limit=1>; rel="prev"`)
+		assert.Contains(t, links, `<http://example.com/books?page=3&limit=1>; rel="next"`)
+		assert.Contains(t, links, `<http://example.com/books?page=3&limit=1>; rel="last"`)
+	})
+
+	t.Run("Get all books with a cursor", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books?cursor=&limit=2", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse struct {
+			Data []models.Book `json:"data"`
+			Meta *cursorMeta   `json:"meta"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, apiResponse.Data, 2)
+		assert.Equal(t, &cursorMeta{Limit: 2, NextCursor: "2"}, apiResponse.Meta)
+		assert.Equal(t, `<http://example.com/books?cursor=2&limit=2>; rel="next"`, res.Header.Get("Link"))
+
+		req = httptest.NewRequest("GET", "/books?cursor=2&limit=2", nil)
+		res, err = app.Test(req, -1)
+		assert.NoError(t, err)
+
+		apiResponse.Meta = nil
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Len(t, apiResponse.Data, 1)
+		assert.Equal(t, &cursorMeta{Limit: 2}, apiResponse.Meta)
+		assert.Empty(t, res.Header.Get("Link"))
+
+		req = httptest.NewRequest("GET", "/books?cursor=tampered", nil)
+		res, err = app.Test(req, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("Get all books with filters", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books?author=author%20b&year_from=2020&year_to=2025&sort=-year,title", nil)
+		res, err := app.Test(req, -1)
+
+		var apiResponse struct {
+			Data    []models.Book `json:"data"`
+			Message string        `json:"message"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, apiResponse.Data, 1)
+		assert.Equal(t, "Book Two", apiResponse.Data[0].Title)
+	})
+
+	t.Run("Get all books with invalid query parameters", func(t *testing.T) {
+		testQueries := []string{
+			"sort=price",                  // field not in the whitelist
+			"sort=-year,;drop",            // garbage sort field
+			"year_from=abc",               // non numeric year
+			"year_from=2020&year_to=2010", // inverted year range
+		}
+
+		for _, query := range testQueries {
+			app := setupTestApp(t)
+			req := httptest.NewRequest("GET", "/books?"+query, nil)
+			res, err := app.Test(req, -1)
+			assert.NoError(t, err)
+
+			var apiResponse apiResponse
+			json.NewDecoder(res.Body).Decode(&apiResponse)
+
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+			assert.Equal(t, "error", apiResponse.Message)
+		}
+	})
+
 	t.Run("Get existing book", func(t *testing.T) {
 
 		expected := models.Book{Title: "Book One", Author: "Author A", Year: 2021}
@@ -109,6 +240,42 @@ func TestGetBooksHandler(t *testing.T) {
 
 }
 
+func TestSearchBooksHandler(t *testing.T) {
+
+	t.Run("searching without a query", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books/search?q=%20", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "error", apiResponse.Message)
+		assert.Equal(t, "missing search query", apiResponse.Error)
+	})
+
+	t.Run("searching with a query", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books/search?q=Two", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse struct {
+			Data    []models.BookSearchResult `json:"data"`
+			Message string                    `json:"message"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "success", apiResponse.Message)
+		assert.Len(t, apiResponse.Data, 1)
+		assert.Equal(t, "Book Two", apiResponse.Data[0].Title)
+	})
+
+}
+
 func TestCreateBook(t *testing.T) {
 
 	book := struct {
@@ -328,6 +495,123 @@ func TestUpdateBook(t *testing.T) {
 
 }
 
+func TestPatchBook(t *testing.T) {
+
+	testCases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		want        models.Book
+		error       string
+	}{
+		{
+			name:        "merge patch changing the year",
+			path:        "/books/1",
+			contentType: "application/merge-patch+json",
+			body:        `{"year": 1999}`,
+			status:      http.StatusOK,
+			want:        models.Book{Title: "Book One", Author: "Author A", Year: 1999},
+		},
+		{
+			name:        "json patch with a passing test operation",
+			path:        "/books/2",
+			contentType: "application/json-patch+json; charset=utf-8",
+			body:        `[{"op": "test", "path": "/title", "value": "Book Two"}, {"op": "replace", "path": "/author", "value": "Someone"}]`,
+			status:      http.StatusOK,
+			want:        models.Book{Title: "Book Two", Author: "Someone", Year: 2022},
+		},
+		{
+			name:        "json patch with a failing test operation",
+			path:        "/books/2",
+			contentType: "application/json-patch+json",
+			body:        `[{"op": "test", "path": "/title", "value": "Other"}, {"op": "replace", "path": "/author", "value": "Someone"}]`,
+			status:      http.StatusUnprocessableEntity,
+			error:       "patch could not be applied",
+		},
+		{
+			name:        "merge patch removing a required field",
+			path:        "/books/1",
+			contentType: "application/merge-patch+json",
+			body:        `{"title": null}`,
+			status:      http.StatusBadRequest,
+			error:       "invalid payload",
+		},
+		{
+			name:        "merge patch with a wrongly typed field",
+			path:        "/books/1",
+			contentType: "application/merge-patch+json",
+			body:        `{"year": "1999"}`,
+			status:      http.StatusBadRequest,
+			error:       "invalid payload",
+		},
+		{
+			name:        "malformed json patch",
+			path:        "/books/1",
+			contentType: "application/json-patch+json",
+			body:        `{"op": "replace"}`,
+			status:      http.StatusBadRequest,
+			error:       "invalid patch document",
+		},
+		{
+			name:        "unsupported content type",
+			path:        "/books/1",
+			contentType: "application/json",
+			body:        `{"year": 1999}`,
+			status:      http.StatusUnsupportedMediaType,
+			error:       "unsupported patch Content-Type",
+		},
+		{
+			name:        "non-existing book",
+			path:        "/books/99",
+			contentType: "application/merge-patch+json",
+			body:        `{"year": 1999}`,
+			status:      http.StatusNotFound,
+			error:       "book not found",
+		},
+		{
+			name:        "invalid param",
+			path:        "/books/xx",
+			contentType: "application/merge-patch+json",
+			body:        `{"year": 1999}`,
+			status:      http.StatusBadRequest,
+			error:       "invalid parameter",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := setupTestApp(t)
+
+			req := httptest.NewRequest("PATCH", tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			res, err := app.Test(req, -1)
+			assert.NoError(t, err)
+
+			var apiResponse struct {
+				Error   string      `json:"error"`
+				Message string      `json:"message"`
+				Data    models.Book `json:"data"`
+			}
+			json.NewDecoder(res.Body).Decode(&apiResponse)
+
+			assert.Equal(t, tc.status, res.StatusCode)
+			if tc.error != "" {
+				assert.Equal(t, "error", apiResponse.Message)
+				assert.Contains(t, apiResponse.Error, tc.error)
+				return
+			}
+			assert.Equal(t, "success", apiResponse.Message)
+			assert.Equal(t, tc.want.Title, apiResponse.Data.Title)
+			assert.Equal(t, tc.want.Author, apiResponse.Data.Author)
+			assert.Equal(t, tc.want.Year, apiResponse.Data.Year)
+		})
+	}
+
+}
+
 func TestDeleteBook(t *testing.T) {
 	app := setupTestApp(t)
 
@@ -414,69 +698,1079 @@ func TestDeleteBook(t *testing.T) {
 	})
 }
 
-type mockedBookService struct {
-	books []*models.Book
-}
+func TestConditionalRequests(t *testing.T) {
 
-var _ services.IBookService = (*mockedBookService)(nil)
+	get := func(app *fiber.App, ifNoneMatch string) *http.Response {
+		req := httptest.NewRequest("GET", "/books/1", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		return res
+	}
 
-func NewMockedBookService() *mockedBookService {
-	books := []*models.Book{
-		{Title: "Book One", Author: "Author A", Year: 2021},
-		{Title: "Book Two", Author: "Author B", Year: 2022},
-		{Title: "Book Three", Author: "Author C", Year: 2023},
+	t.Run("GET returns the version as a strong ETag", func(t *testing.T) {
+		app := setupTestApp(t)
+		res := get(app, "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+	})
+
+	t.Run("GET with a matching If-None-Match", func(t *testing.T) {
+		app := setupTestApp(t)
+		tag := get(app, "").Header.Get("ETag")
+		for _, header := range []string{tag, "W/" + tag, `"7", ` + tag, `*`} {
+			res := get(app, header)
+			assert.Equal(t, http.StatusNotModified, res.StatusCode, header)
+			assert.Equal(t, tag, res.Header.Get("ETag"))
+		}
+	})
+
+	t.Run("GET with a stale If-None-Match", func(t *testing.T) {
+		app := setupTestApp(t)
+		for _, header := range []string{`"2"`, `"01"`, `"1-9f86d081884c7d65"`} {
+			res := get(app, header)
+			assert.Equal(t, http.StatusOK, res.StatusCode, header)
+		}
+	})
+
+	t.Run("GET with the ETag of a write", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("PUT", "/books/1", strings.NewReader(`{"title": "New", "author": "Author", "year": 2000}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		tag := res.Header.Get("ETag")
+
+		res = get(app, tag)
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+		assert.Equal(t, tag, res.Header.Get("ETag"))
+	})
+
+	t.Run("PUT with the ETag of a GET", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("PUT", "/books/1", strings.NewReader(`{"title": "New", "author": "Author", "year": 2000}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", get(app, "").Header.Get("ETag"))
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	testCases := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+	}{
+		{"PUT", "PUT", "application/json", `{"title": "New", "author": "Author", "year": 2000}`},
+		{"PATCH", "PATCH", "application/merge-patch+json", `{"year": 2000}`},
+		{"DELETE", "DELETE", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name+" with a stale If-Match", func(t *testing.T) {
+			app := setupTestApp(t)
+			for _, header := range []string{`"2"`, `W/"1"`, `"01"`, `"1-9f86d081884c7d65"`, `garbage`} {
+				req := httptest.NewRequest(tc.method, "/books/1", strings.NewReader(tc.body))
+				req.Header.Set("Content-Type", tc.contentType)
+				req.Header.Set("If-Match", header)
+				res, err := app.Test(req, -1)
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusPreconditionFailed, res.StatusCode, header)
+			}
+		})
+
+		t.Run(tc.name+" with a matching If-Match", func(t *testing.T) {
+			app := setupTestApp(t)
+			req := httptest.NewRequest(tc.method, "/books/1", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("If-Match", `"1"`)
+			res, err := app.Test(req, -1)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			if tc.method != "DELETE" {
+				assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+			}
+		})
 	}
 
-	return &mockedBookService{books: books}
 }
 
-func (m *mockedBookService) CreateBook(book *models.Book) (*models.Book, error) {
-	book.ID = uint(len(m.books) + 1)
-	m.books = append(m.books, book)
-	return book, nil
+func TestTrash(t *testing.T) {
+	app := setupTestApp(t)
+
+	deleteReq := httptest.NewRequest("DELETE", "/books/2", nil)
+	res, err := app.Test(deleteReq, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	t.Run("listing the trash", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/books/trash", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse struct {
+			Data []models.Book `json:"data"`
+			Meta *pageMeta     `json:"meta"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, apiResponse.Data, 1)
+		assert.Equal(t, "Book Two", apiResponse.Data[0].Title)
+		assert.Equal(t, int64(1), apiResponse.Meta.Total)
+	})
+
+	t.Run("restoring a trashed book", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/books/2/restore", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse struct {
+			Data models.Book `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Book Two", apiResponse.Data.Title)
+	})
+
+	t.Run("restoring a book that is not in the trash", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/books/1/restore", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.Contains(t, apiResponse.Error, "book not found in trash")
+	})
+
+	t.Run("purging without admin privileges", func(t *testing.T) {
+		for _, token := range []string{"", "wrong-token"} {
+			req := httptest.NewRequest("DELETE", "/books/1?purge=true", nil)
+			req.Header.Set("X-Admin-Token", token)
+			res, err := app.Test(req, -1)
+			assert.NoError(t, err)
+
+			var apiResponse apiResponse
+			json.NewDecoder(res.Body).Decode(&apiResponse)
+
+			assert.Equal(t, http.StatusForbidden, res.StatusCode)
+			assert.Equal(t, "admin privileges required", apiResponse.Error)
+		}
+	})
+
+	t.Run("purging as admin", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/books/1?purge=true", nil)
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse struct {
+			Data models.Book `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Book One", apiResponse.Data.Title)
+	})
 }
 
-func (m *mockedBookService) GetBook(id uint) (*models.Book, error) {
-	if id > uint(len(m.books)) {
-		return nil, services.ErrNotFound
+func TestBulkBooks(t *testing.T) {
+
+	type bulkResponse struct {
+		Message string           `json:"message"`
+		Error   string           `json:"error"`
+		Data    []bulkItemResult `json:"data"`
 	}
-	return m.books[id-1], nil
-}
 
-func (m *mockedBookService) GetAllBooks(page, limit int) ([]*models.Book, error) {
-	if page <= 0 {
-		page = 1
+	statuses := func(response bulkResponse) []int {
+		var statuses []int
+		for _, item := range response.Data {
+			statuses = append(statuses, item.Status)
+		}
+		return statuses
 	}
-	if limit <= 0 {
-		limit = 10
+
+	send := func(app *fiber.App, path, contentType, body string) (*http.Response, bulkResponse) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var response bulkResponse
+		json.NewDecoder(res.Body).Decode(&response)
+		return res, response
 	}
-	if limit > 100 {
-		limit = 100
+
+	t.Run("running a json array of operations", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, response := send(app, "/books/bulk", "application/json", `[
+			{"op": "create", "book": {"title": "Book Four", "author": "Author D", "year": 2024}},
+			{"op": "update", "id": 1, "book": {"title": "Book One", "author": "Author A", "year": 1999}},
+			{"op": "delete", "id": 2}
+		]`)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "success", response.Message)
+		assert.Equal(t, []int{201, 200, 200}, statuses(response))
+		assert.Equal(t, "create", response.Data[0].Op)
+	})
+
+	t.Run("partial mode with ndjson", func(t *testing.T) {
+		app := setupTestApp(t)
+		body := `{"op": "create", "book": {"title": "Book Four", "author": "Author D", "year": 2024}}
+{"op": "create", "book": {"title": "", "author": "Author E", "year": 2024}}
+
+{"op": "delete", "id": 99}
+not json
+{"op": "launch", "id": 1}
+{"op": "delete", "id": 1, "version": 1}
+`
+		res, response := send(app, "/books/bulk?mode=partial", "application/x-ndjson", body)
+
+		assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+		assert.Equal(t, []int{201, 400, 404, 400, 400, 200}, statuses(response))
+		for i, item := range response.Data {
+			assert.Equal(t, i, item.Index)
+		}
+		assert.Contains(t, response.Data[2].Error, "book not found")
+		assert.Equal(t, "/1/book/title", response.Data[1].Errors[0].Pointer)
+		assert.Equal(t, "/3", response.Data[3].Errors[0].Pointer)
+		assert.Equal(t, "/4/op", response.Data[4].Errors[0].Pointer)
+		assert.Equal(t, "oneof", response.Data[4].Errors[0].Rule)
+	})
+
+	t.Run("atomic mode with an invalid operation", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, response := send(app, "/books/bulk", "application/json", `[
+			{"op": "delete", "id": 1},
+			{"op": "update", "id": 2}
+		]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, "bulk operation aborted", response.Error)
+		assert.Equal(t, []int{424, 400}, statuses(response))
+		assert.Equal(t, []fieldError{{Pointer: "/1/book", Rule: "required_unless", Message: "book is required"}}, response.Data[1].Errors)
+
+		req := httptest.NewRequest("GET", "/books/1", nil)
+		getRes, _ := app.Test(req, -1)
+		assert.Equal(t, http.StatusOK, getRes.StatusCode)
+	})
+
+	t.Run("atomic mode with a failing operation", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, response := send(app, "/books/bulk?mode=atomic", "application/json", `[
+			{"op": "delete", "id": 1},
+			{"op": "update", "id": 2, "version": 5, "book": {"title": "Book Two", "author": "Author B", "year": 1999}},
+			{"op": "delete", "id": 3}
+		]`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+		assert.Equal(t, []int{424, 412, 424}, statuses(response))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		app := setupTestApp(t)
+
+		res, _ := send(app, "/books/bulk?mode=some", "application/json", `[{"op": "delete", "id": 1}]`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "/books/bulk", "application/json", `{"op": "delete", "id": 1}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "/books/bulk", "application/json", `[]`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "/books/bulk", "text/plain", `[{"op": "delete", "id": 1}]`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+	})
+
+}
+
+func TestImportBooks(t *testing.T) {
+
+	type importResponse struct {
+		Message string            `json:"message"`
+		Error   string            `json:"error"`
+		Data    []importRowResult `json:"data"`
+		Meta    importSummary     `json:"meta"`
 	}
 
-	start := (page - 1) * limit
-	end := start + limit
+	send := func(app *fiber.App, path, contentType, body string) (*http.Response, importResponse) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
 
-	if start >= len(m.books) {
-		return []*models.Book{}, nil
+		var response importResponse
+		json.NewDecoder(res.Body).Decode(&response)
+		return res, response
 	}
 
-	if end > len(m.books) {
-		end = len(m.books)
+	statuses := func(response importResponse) []string {
+		var statuses []string
+		for _, row := range response.Data {
+			statuses = append(statuses, row.Status)
+		}
+		return statuses
 	}
 
-	return m.books[start:end], nil
-}
+	csvBody := `Book Title,Writer,Published
+Book Four,Author D,2024
+Book One,Author A,2021
+,Author E,2024
+Book Six,Author F,soon
+"Book Seven,Author G,2020
+`
 
-func (m *mockedBookService) UpdateBook(payload *models.Book) (*models.Book, error) {
-	if payload.ID == 0 || payload.ID > uint(len(m.books)) {
-		return nil, services.ErrNotFound
-	}
-	m.books[payload.ID-1] = payload
-	return m.books[payload.ID-1], nil
+	t.Run("importing csv with a column mapping", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, response := send(app, "/books/import?map=title:book%20title,author:Writer,year:Published", "text/csv", csvBody)
+
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, []string{"created", "duplicate", "rejected", "rejected", "rejected"}, statuses(response))
+		assert.Equal(t, importSummary{Created: 1, Duplicates: 1, Rejected: 3}, response.Meta)
+		assert.Equal(t, "invalid title failed on required", response.Data[2].Error)
+		assert.Equal(t, `invalid year "soon"`, response.Data[3].Error)
+		assert.Equal(t, 4, response.Data[3].Row)
+
+		req := httptest.NewRequest("GET", "/books/4", nil)
+		getRes, _ := app.Test(req, -1)
+		assert.Equal(t, http.StatusOK, getRes.StatusCode)
+	})
+
+	t.Run("books rejected by the service", func(t *testing.T) {
+		service := NewMockedBookService()
+		service.requireISBN = true
+		app := setupTestAppWith(t, service)
+		res, response := send(app, "/books/import?dry_run=true", "application/x-ndjson", `{"title": "Book Four", "author": "Author D", "year": 2024}
+{"title": "Book Five", "author": "Author E", "year": 2024, "isbn": "9780262033848"}
+`)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{"rejected", "created"}, statuses(response))
+		assert.Equal(t, importSummary{DryRun: true, Created: 1, Rejected: 1}, response.Meta)
+		assert.Equal(t, "isbn is required", response.Data[0].Error)
+		assert.Nil(t, response.Data[0].Data)
+	})
+
+	t.Run("dry run doesn't write anything", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, response := send(app, "/books/import?dry_run=true&map=title:book%20title,author:Writer,year:Published", "text/csv", csvBody)
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, importSummary{DryRun: true, Created: 1, Duplicates: 1, Rejected: 3}, response.Meta)
+
+		req := httptest.NewRequest("GET", "/books/4", nil)
+		getRes, _ := app.Test(req, -1)
+		assert.Equal(t, http.StatusNotFound, getRes.StatusCode)
+	})
+
+	t.Run("importing json and ndjson", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, response := send(app, "/books/import", "application/json", `[
+			{"title": "Book Four", "author": "Author D", "year": 2024},
+			{"title": "Book Five", "author": "Author E", "year": "2025"},
+			"not an object"
+		]`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, []string{"created", "created", "rejected"}, statuses(response))
+
+		res, response = send(app, "/books/import?map=title:name", "application/x-ndjson", `{"name": "Book Six", "author": "Author F", "year": 2026}
+{"title": "Book Seven", "author": "Author G", "year": 2027}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, []string{"created", "rejected"}, statuses(response))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		app := setupTestApp(t)
+
+		res, _ := send(app, "/books/import?map=price:cost", "text/csv", csvBody)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "/books/import", "text/csv", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "/books/import", "application/xml", "<books/>")
+		assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+	})
+
+}
+
+func TestExportBooks(t *testing.T) {
+
+	export := func(t *testing.T, query string) (*http.Response, []byte) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books/export?"+query, nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		return res, body
+	}
+
+	t.Run("exporting csv", func(t *testing.T) {
+		res, body := export(t, "format=csv&author=Author%20B")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/csv", res.Header.Get("Content-Type"))
+		assert.Regexp(t, `^attachment; filename="books-\d{8}\.csv"$`, res.Header.Get("Content-Disposition"))
+
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, exportColumns, records[0])
+		assert.Equal(t, "Book Two", records[1][1])
+	})
+
+	t.Run("exporting ndjson", func(t *testing.T) {
+		res, body := export(t, "format=ndjson")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+		assert.Len(t, strings.Split(strings.TrimSpace(string(body)), "\n"), 3)
+	})
+
+	t.Run("exporting json", func(t *testing.T) {
+		res, body := export(t, "format=json")
+
+		var books []models.Book
+		assert.NoError(t, json.Unmarshal(body, &books))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, books, 3)
+		assert.Equal(t, "Book Three", books[2].Title)
+	})
+
+	t.Run("exporting xlsx", func(t *testing.T) {
+		res, body := export(t, "format=xlsx")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		file, err := excelize.OpenReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		rows, err := file.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Len(t, rows, 4)
+		assert.Equal(t, "Book One", rows[1][1])
+	})
+
+	t.Run("filtered by book format", func(t *testing.T) {
+		service := NewMockedBookService()
+		service.books[1].Format = models.FormatEbook
+		res, err := setupTestAppWith(t, service).Test(httptest.NewRequest("GET", "/books/export?format=csv&book_format=ebook", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		records, err := csv.NewReader(res.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, "ebook", records[1][7])
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		res, _ := export(t, "format=pdf")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("failed exports", func(t *testing.T) {
+		service := NewMockedBookService()
+		service.exportErr = errors.New("disk full")
+		logs := &bytes.Buffer{}
+		app := fiber.New(fiber.Config{
+			ErrorHandler: ErrorHandler,
+		})
+		app.Use(testAuth(RoleLibrarian))
+		NewBookHandler(service, validator.New(), slog.New(slog.NewTextHandler(logs, nil))).SetupRoutes(app)
+
+		// workbooks are built before the response starts
+		res, err := app.Test(httptest.NewRequest("GET", "/books/export?format=xlsx", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.Equal(t, mimeProblemJSON, res.Header.Get("Content-Type"))
+		assert.Empty(t, res.Header.Get("Content-Disposition"))
+
+		// streamed exports are cut short, and the error logged
+		res, err = app.Test(httptest.NewRequest("GET", "/books/export?format=csv", nil), -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Contains(t, logs.String(), `msg="export cut short"`)
+		assert.Contains(t, logs.String(), "disk full")
+	})
+
+}
+
+func TestProblemDetails(t *testing.T) {
+
+	send := func(t *testing.T, method, path, body string) (*http.Response, problem) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		return res, p
+	}
+
+	t.Run("domain errors carry a stable code", func(t *testing.T) {
+		res, p := send(t, "GET", "/books/99", "")
+
+		assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+		assert.Equal(t, http.StatusNotFound, p.Status)
+		assert.Equal(t, "Not Found", p.Title)
+		assert.Equal(t, "book_not_found", p.Code)
+		assert.Equal(t, "urn:problem-type:book_not_found", p.Type)
+		assert.Equal(t, "/books/99", p.Instance)
+		assert.Contains(t, p.Detail, "book not found")
+	})
+
+	t.Run("other errors are coded after their status", func(t *testing.T) {
+		_, p := send(t, "GET", "/books/xx", "")
+
+		assert.Equal(t, http.StatusBadRequest, p.Status)
+		assert.Equal(t, "about:blank", p.Type)
+		assert.Equal(t, "bad_request", p.Code)
+		assert.Equal(t, "invalid parameter", p.Detail)
+	})
+
+	t.Run("validation errors list every field", func(t *testing.T) {
+		_, p := send(t, "POST", "/books", `{"title": "Title ", "year": 2000}`)
+
+		assert.Equal(t, http.StatusBadRequest, p.Status)
+		assert.Equal(t, "validation_failed", p.Code)
+		assert.Equal(t, []fieldError{
+			{Pointer: "/title", Rule: "endsnotwith", Message: `title must not end with " "`},
+			{Pointer: "/author", Rule: "required", Message: "author is required"},
+		}, p.Errors)
+	})
+
+	t.Run("malformed bodies are reported", func(t *testing.T) {
+		_, p := send(t, "PUT", "/books/1", `{"title": "Title", "author": "Author", "year": "2000"}`)
+
+		assert.Equal(t, http.StatusBadRequest, p.Status)
+		assert.Equal(t, "malformed_body", p.Code)
+		assert.Equal(t, []fieldError{{Pointer: "/year", Rule: "json", Message: "must be of type int"}}, p.Errors)
+
+		_, p = send(t, "POST", "/books", `{"title": "Title",`)
+		assert.Equal(t, "malformed_body", p.Code)
+		assert.Equal(t, "", p.Errors[0].Pointer)
+	})
+
+	t.Run("query parameters are named as in the request", func(t *testing.T) {
+		_, p := send(t, "GET", "/books?year_from=2020&year_to=2010", "")
+
+		assert.Equal(t, "validation_failed", p.Code)
+		assert.Equal(t, "/year_to", p.Errors[0].Pointer)
+		assert.Equal(t, "gtefield", p.Errors[0].Rule)
+	})
+
+}
+
+func TestISBN(t *testing.T) {
+
+	t.Run("lookup accepts either format", func(t *testing.T) {
+		app := setupTestApp(t)
+		for _, isbn := range []string{"0-306-40615-2", "978-0-306-40615-7", "9780306406157"} {
+			req := httptest.NewRequest("GET", "/books/isbn/"+isbn, nil)
+			res, err := app.Test(req, -1)
+			assert.NoError(t, err)
+
+			var apiResponse struct {
+				Data models.Book `json:"data"`
+			}
+			json.NewDecoder(res.Body).Decode(&apiResponse)
+
+			assert.Equal(t, http.StatusOK, res.StatusCode, isbn)
+			assert.Equal(t, "Book One", apiResponse.Data.Title)
+			assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+		}
+	})
+
+	t.Run("lookup of an invalid isbn", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books/isbn/0306406153", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "invalid_isbn", p.Code)
+	})
+
+	t.Run("lookup of an unknown isbn", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books/isbn/9781861972712", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("check digits are validated", func(t *testing.T) {
+		app := setupTestApp(t)
+		body := `{"title": "Title", "author": "Author", "year": 2000, "isbn": "978 0 306 40615 8"}`
+		req := httptest.NewRequest("POST", "/books", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, []fieldError{
+			{Pointer: "/isbn", Rule: "isbn", Message: "isbn must be a valid ISBN-10 or ISBN-13"},
+		}, p.Errors)
+	})
+
+	t.Run("hyphens and spaces are accepted", func(t *testing.T) {
+		app := setupTestApp(t)
+		body := `{"title": "Title", "author": "Author", "year": 2000, "isbn": "0 8044 2957-X"}`
+		req := httptest.NewRequest("POST", "/books", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+	})
+
+}
+
+func TestPublicationDetails(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, contentType, body string) (*http.Response, []byte) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		data, _ := io.ReadAll(res.Body)
+		return res, data
+	}
+
+	t.Run("created with every detail", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, body := send(app, "POST", "/books", "application/json", `{
+			"title": "Title", "author": "Author", "year": 2003,
+			"publisher": {"name": "Doubleday"}, "edition": "First edition", "format": "hardcover",
+			"page_count": 454, "language": "en-GB", "published_on": "2003-03-18"
+		}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		var response struct {
+			Data map[string]any `json:"data"`
+		}
+		json.Unmarshal(body, &response)
+		assert.Equal(t, "Doubleday", response.Data["publisher"].(map[string]any)["name"])
+		assert.Equal(t, "2003-03-18", response.Data["published_on"])
+		assert.Equal(t, float64(454), response.Data["page_count"])
+		assert.NotContains(t, response.Data, "publisher_id")
+	})
+
+	t.Run("validated", func(t *testing.T) {
+		app := setupTestApp(t)
+		_, body := send(app, "POST", "/books", "application/json", `{
+			"title": "Title", "author": "Author", "year": 2003,
+			"format": "vinyl", "page_count": -1, "language": "not a tag!"
+		}`)
+
+		var p problem
+		json.Unmarshal(body, &p)
+		assert.Equal(t, []fieldError{
+			{Pointer: "/format", Rule: "oneof", Message: "format must be one of hardcover, paperback, ebook, audiobook"},
+			{Pointer: "/page_count", Rule: "min", Message: "page_count must be at least 1"},
+			{Pointer: "/language", Rule: "bcp47_language_tag", Message: "language must be a BCP 47 language tag, eg: en-GB"},
+		}, p.Errors)
+
+		res, body := send(app, "POST", "/books", "application/json", `{"title": "Title", "author": "Author", "year": 2003, "published_on": "18/03/2003"}`)
+		json.Unmarshal(body, &p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "malformed_body", p.Code)
+	})
+
+	t.Run("list filters are validated", func(t *testing.T) {
+		app := setupTestApp(t)
+		for _, query := range []string{"book_format=vinyl", "language=not%20a%20tag", "published_from=2003-13-01", "pages_min=500&pages_max=100"} {
+			res, _ := send(app, "GET", "/books?"+query, "", "")
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+		}
+
+		res, _ := send(app, "GET", "/books?book_format=ebook&language=en&published_from=2003-01-01&pages_min=100&publisher=Doubleday", "", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("filtered by book_format", func(t *testing.T) {
+		service := NewMockedBookService()
+		service.books[1].Format = models.FormatEbook
+		app := setupTestAppWith(t, service)
+
+		var response struct {
+			Data []models.Book `json:"data"`
+		}
+		res, body := send(app, "GET", "/books?book_format=ebook", "", "")
+		json.Unmarshal(body, &response)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, "Book Two", response.Data[0].Title)
+
+		// format isn't a filter, it names the format of exports
+		_, body = send(app, "GET", "/books?format=ebook", "", "")
+		json.Unmarshal(body, &response)
+		assert.Len(t, response.Data, 3)
+	})
+
+	t.Run("patching the publisher name", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, _ := send(app, "PATCH", "/books/1", "application/merge-patch+json", `{"publisher": {"id": 7, "name": "Doubleday"}}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, body := send(app, "PATCH", "/books/1", "application/merge-patch+json", `{"publisher": {"name": "Penguin"}}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var response struct {
+			Data models.Book `json:"data"`
+		}
+		json.Unmarshal(body, &response)
+		assert.Equal(t, "Penguin", response.Data.Publisher.Name)
+		assert.Zero(t, response.Data.Publisher.ID)
+	})
+
+	t.Run("grouped by work", func(t *testing.T) {
+		app := setupTestApp(t)
+		res, _ := send(app, "PATCH", "/books/2", "application/merge-patch+json", `{"work_id": 1}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, body := send(app, "GET", "/books?group_by=work&limit=1", "", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEmpty(t, res.Header.Get("Link"))
+
+		var response struct {
+			Data []models.Work `json:"data"`
+			Meta pageMeta      `json:"meta"`
+		}
+		json.Unmarshal(body, &response)
+		assert.Equal(t, int64(2), response.Meta.Total)
+		assert.Len(t, response.Data[0].Editions, 2)
+
+		for _, query := range []string{"group_by=author", "group_by=work&cursor="} {
+			res, _ := send(app, "GET", "/books?"+query, "", "")
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+		}
+	})
+
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+type mockedBookService struct {
+	books  []*models.Book
+	trash  []*models.Book
+	tenant string
+	// requireISBN rejects imported books without an ISBN.
+	requireISBN bool
+	// exportErr stops exports after their first book.
+	exportErr error
+}
+
+var _ services.IBookService = (*mockedBookService)(nil)
+
+func NewMockedBookService() *mockedBookService {
+	books := []*models.Book{
+		{Title: "Book One", Author: "Author A", Year: 2021, Version: 1, ISBN: ptr("9780306406157")},
+		{Title: "Book Two", Author: "Author B", Year: 2022, Version: 1},
+		{Title: "Book Three", Author: "Author C", Year: 2023, Version: 1},
+	}
+
+	return &mockedBookService{books: books}
+}
+
+func (m *mockedBookService) ForTenant(tenant *models.Tenant) services.IBookService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedBookService) CreateBook(book *models.Book) (*models.Book, error) {
+	book.ID = uint(len(m.books) + 1)
+	book.Version = 1
+	m.books = append(m.books, book)
+	return book, nil
+}
+
+func (m *mockedBookService) GetBook(id uint) (*models.Book, error) {
+	if id > uint(len(m.books)) {
+		return nil, services.ErrNotFound
+	}
+	return m.books[id-1], nil
+}
+
+func (m *mockedBookService) GetBookByISBN(isbn string) (*models.Book, error) {
+	normalized, ok := models.NormalizeISBN(isbn)
+	if !ok {
+		return nil, services.ErrInvalidISBN
+	}
+	for _, book := range m.books {
+		if book.ISBN != nil && *book.ISBN == normalized {
+			return book, nil
+		}
+	}
+	return nil, services.ErrNotFound
+}
+
+func (m *mockedBookService) GetBooksByWork(query services.BookQuery) ([]*models.Work, int64, error) {
+	books, _, _ := m.GetAllBooks(services.BookQuery{Page: 1, Limit: len(m.books), Author: query.Author, YearFrom: query.YearFrom, YearTo: query.YearTo})
+
+	var works []*models.Work
+	byID := make(map[uint]*models.Work)
+	for _, book := range books {
+		id := book.ID
+		if book.WorkID != nil {
+			id = *book.WorkID
+		}
+		work, ok := byID[id]
+		if !ok {
+			work = &models.Work{Model: gorm.Model{ID: id}, Title: book.Title}
+			byID[id] = work
+			works = append(works, work)
+		}
+		work.Editions = append(work.Editions, *book)
+	}
+
+	start := min((query.Page-1)*query.Limit, len(works))
+	end := min(start+query.Limit, len(works))
+	return works[start:end], int64(len(works)), nil
+}
+
+// GetSeriesNeighbors orders the books of a series by position only.
+func (m *mockedBookService) GetSeriesNeighbors(book *models.Book) (previous, next uint, err error) {
+	if book.SeriesID == nil {
+		return 0, 0, nil
+	}
+
+	var series []*models.Book
+	for _, b := range m.books {
+		if b.SeriesID != nil && *b.SeriesID == *book.SeriesID {
+			series = append(series, b)
+		}
+	}
+	slices.SortFunc(series, func(a, b *models.Book) int { return cmp.Compare(*a.SeriesPosition, *b.SeriesPosition) })
+
+	id := func(b *models.Book) uint { return uint(slices.Index(m.books, b) + 1) }
+	i := slices.Index(series, book)
+	if i > 0 {
+		previous = id(series[i-1])
+	}
+	if i+1 < len(series) {
+		next = id(series[i+1])
+	}
+	return previous, next, nil
+}
+
+// GetSubjectCounts counts a single subject, assigned to every book.
+func (m *mockedBookService) GetSubjectCounts(query services.BookQuery) ([]*models.SubjectCount, error) {
+	_, total, _ := m.GetAllBooks(services.BookQuery{Page: 1, Limit: len(m.books), Author: query.Author, YearFrom: query.YearFrom, YearTo: query.YearTo})
+	if total == 0 {
+		return []*models.SubjectCount{}, nil
+	}
+	return []*models.SubjectCount{{Subject: models.Subject{Model: gorm.Model{ID: 1}, Name: "Fiction"}, Books: total}}, nil
+}
+
+func (m *mockedBookService) GetAllBooks(query services.BookQuery) ([]*models.Book, int64, error) {
+	page, limit := query.Page, query.Limit
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var books []*models.Book
+	for _, book := range m.books {
+		if query.Author != "" && !strings.EqualFold(book.Author, query.Author) {
+			continue
+		}
+		if query.YearFrom > 0 && book.Year < query.YearFrom {
+			continue
+		}
+		if query.YearTo > 0 && book.Year > query.YearTo {
+			continue
+		}
+		if query.Format != "" && book.Format != query.Format {
+			continue
+		}
+		books = append(books, book)
+	}
+
+	start := (page - 1) * limit
+	end := start + limit
+
+	if start >= len(books) {
+		return []*models.Book{}, int64(len(books)), nil
+	}
+
+	if end > len(books) {
+		end = len(books)
+	}
+
+	return books[start:end], int64(len(books)), nil
+}
+
+func (m *mockedBookService) GetBooksAfter(query services.BookQuery, cursor string) ([]*models.Book, string, error) {
+	start := 0
+	if cursor != "" {
+		var err error
+		if start, err = strconv.Atoi(cursor); err != nil {
+			return nil, "", services.ErrInvalidCursor
+		}
+	}
+
+	end := min(start+query.Limit, len(m.books))
+	if end < len(m.books) {
+		return m.books[start:end], strconv.Itoa(end), nil
+	}
+	return m.books[start:end], "", nil
+}
+
+func (m *mockedBookService) ExportBooks(query services.BookQuery, fn func(book *models.Book) error) error {
+	query.Page, query.Limit = 1, len(m.books)
+	books, _, _ := m.GetAllBooks(query)
+	for _, book := range books {
+		if err := fn(book); err != nil {
+			return err
+		}
+		if m.exportErr != nil {
+			return m.exportErr
+		}
+	}
+	return nil
+}
+
+func (m *mockedBookService) SearchBooks(query string, page, limit int) ([]*models.BookSearchResult, error) {
+	var results []*models.BookSearchResult
+	for _, book := range m.books {
+		if strings.Contains(book.Title, query) || strings.Contains(book.Author, query) {
+			results = append(results, &models.BookSearchResult{Book: *book, Score: 1, Snippet: book.Title})
+		}
+	}
+	return results, nil
+}
+
+func (m *mockedBookService) UpdateBook(payload *models.Book) (*models.Book, error) {
+	if payload.ID == 0 || payload.ID > uint(len(m.books)) {
+		return nil, services.ErrNotFound
+	}
+	current := m.books[payload.ID-1].Version
+	if payload.Version != 0 && payload.Version != current {
+		return nil, services.ErrVersionMismatch
+	}
+	payload.Version = current + 1
+	m.books[payload.ID-1] = payload
+	return m.books[payload.ID-1], nil
+}
+
+func (m *mockedBookService) PatchBook(id, version uint, patch func(book *models.Book) error) (*models.Book, error) {
+	if id == 0 || id > uint(len(m.books)) {
+		return nil, services.ErrNotFound
+	}
+	book := *m.books[id-1]
+	book.ID = id
+	if version != 0 && version != book.Version {
+		return nil, services.ErrVersionMismatch
+	}
+	if err := patch(&book); err != nil {
+		return nil, err
+	}
+	book.Version++
+	m.books[id-1] = &book
+	return &book, nil
+}
+
+func (m *mockedBookService) DeleteBook(id, version uint) (*models.Book, error) {
+	if id == 0 || id > uint(len(m.books)) {
+		return nil, services.ErrNotFound
+	}
+	book := m.books[id-1]
+	if version != 0 && version != book.Version {
+		return nil, services.ErrVersionMismatch
+	}
+	m.books = append(m.books[:id-1], m.books[id:]...)
+	book.ID = id
+	m.trash = append(m.trash, book)
+	return book, nil
+}
+
+func (m *mockedBookService) BulkWrite(ops []services.BulkOperation, atomic bool) ([]services.BulkResult, error) {
+	snapshot := append([]*models.Book{}, m.books...)
+	results := make([]services.BulkResult, len(ops))
+	for i, op := range ops {
+		var book *models.Book
+		var err error
+		switch op.Op {
+		case services.BulkCreate:
+			book, err = m.CreateBook(op.Book)
+		case services.BulkUpdate:
+			op.Book.ID, op.Book.Version = op.ID, op.Version
+			book, err = m.UpdateBook(op.Book)
+		case services.BulkDelete:
+			book, err = m.DeleteBook(op.ID, op.Version)
+		}
+		results[i] = services.BulkResult{Book: book, Err: err}
+		if err != nil && atomic {
+			m.books = snapshot
+			return results, services.ErrBulkAborted
+		}
+	}
+	return results, nil
+}
+
+func (m *mockedBookService) ImportBooks(books []*models.Book, dryRun bool) ([]services.ImportResult, error) {
+	results := make([]services.ImportResult, len(books))
+	for i, book := range books {
+		results[i] = services.ImportResult{Status: services.ImportCreated, Book: book}
+		if m.requireISBN && book.ISBN == nil {
+			results[i] = services.ImportResult{Status: services.ImportRejected, Book: book, Err: services.ErrISBNRequired}
+			continue
+		}
+		for _, existing := range m.books {
+			if existing.Title == book.Title && existing.Author == book.Author && existing.Year == book.Year {
+				results[i].Status = services.ImportDuplicate
+			}
+		}
+		if results[i].Status == services.ImportCreated && !dryRun {
+			m.CreateBook(book)
+		}
+	}
+	return results, nil
+}
+
+func (m *mockedBookService) GetTrashedBooks(page, limit int) ([]*models.Book, int64, error) {
+	return m.trash, int64(len(m.trash)), nil
+}
+
+func (m *mockedBookService) RestoreBook(id uint) (*models.Book, error) {
+	for i, book := range m.trash {
+		if book.ID == id {
+			m.trash = append(m.trash[:i], m.trash[i+1:]...)
+			return book, nil
+		}
+	}
+	return nil, services.ErrNotInTrash
 }
 
-func (m *mockedBookService) DeleteBook(id uint) (*models.Book, error) {
+func (m *mockedBookService) PurgeBook(id, version uint) (*models.Book, error) {
 	if id == 0 || id > uint(len(m.books)) {
 		return nil, services.ErrNotFound
 	}
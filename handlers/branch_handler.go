@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type BranchHandler struct {
+	branchService services.IBranchService
+	validate      *validator.Validate
+}
+
+func NewBranchHandler(service services.IBranchService, validator *validator.Validate) *BranchHandler {
+	return &BranchHandler{
+		branchService: service,
+		validate:      validator,
+	}
+}
+
+func (handler *BranchHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/branches", reader, handler.getAllBranches)
+	router.Get("/branches/:id", reader, handler.getBranch)
+	router.Post("/branches", librarian, handler.newBranch)
+	router.Put("/branches/:id", librarian, handler.updateBranch)
+	router.Delete("/branches/:id", librarian, handler.deleteBranch)
+}
+
+func (handler *BranchHandler) getAllBranches(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	branches, total, err := handler.branchService.GetAllBranches(page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    branches,
+		Meta:    meta,
+	})
+}
+
+func (handler *BranchHandler) getBranch(c *fiber.Ctx) error {
+	branchId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	branch, err := handler.branchService.GetBranch(uint(branchId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    branch,
+	})
+}
+
+func (handler *BranchHandler) newBranch(c *fiber.Ctx) error {
+	var branch models.Branch
+	if err := parseBody(c, &branch); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&branch); err != nil {
+		return validationProblem(err)
+	}
+
+	createdBranch, err := handler.branchService.CreateBranch(&branch)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdBranch,
+	})
+}
+
+func (handler *BranchHandler) updateBranch(c *fiber.Ctx) error {
+	branchId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var branch models.Branch
+	if err := parseBody(c, &branch); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&branch); err != nil {
+		return validationProblem(err)
+	}
+
+	branch.ID = uint(branchId)
+	updatedBranch, err := handler.branchService.UpdateBranch(&branch)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedBranch,
+	})
+}
+
+func (handler *BranchHandler) deleteBranch(c *fiber.Ctx) error {
+	branchId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	branch, err := handler.branchService.DeleteBranch(uint(branchId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    branch,
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupBranchTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewBranchHandler(NewMockedBranchService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestBranchHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("list branches", func(t *testing.T) {
+		app := setupBranchTestApp(t)
+		res, response := send(app, "GET", "/branches?limit=1", "")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, float64(2), response.Meta.(map[string]any)["total"])
+	})
+
+	t.Run("get branch", func(t *testing.T) {
+		app := setupBranchTestApp(t)
+		res, response := send(app, "GET", "/branches/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Central", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "GET", "/branches/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("create and update branch", func(t *testing.T) {
+		app := setupBranchTestApp(t)
+		res, response := send(app, "POST", "/branches", `{"name": "West", "address": "3 West Street"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "3 West Street", response.Data.(map[string]any)["address"])
+
+		res, _ = send(app, "POST", "/branches", `{"name": "central"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "PUT", "/branches/2", `{"name": ""}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, response = send(app, "PUT", "/branches/2", `{"name": "East Side"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "East Side", response.Data.(map[string]any)["name"])
+	})
+
+	t.Run("delete branch", func(t *testing.T) {
+		app := setupBranchTestApp(t)
+		res, _ := send(app, "DELETE", "/branches/1", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/branches/2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+}
+
+type mockedBranchService struct {
+	branches []*models.Branch
+}
+
+var _ services.IBranchService = (*mockedBranchService)(nil)
+
+func NewMockedBranchService() *mockedBranchService {
+	branches := []*models.Branch{
+		{Name: "Central"},
+		{Name: "East"},
+	}
+	for i, branch := range branches {
+		branch.ID = uint(i + 1)
+	}
+
+	return &mockedBranchService{branches: branches}
+}
+
+func (m *mockedBranchService) GetAllBranches(page, limit int) ([]*models.Branch, int64, error) {
+	start := min((page-1)*limit, len(m.branches))
+	end := min(start+limit, len(m.branches))
+	return m.branches[start:end], int64(len(m.branches)), nil
+}
+
+func (m *mockedBranchService) GetBranch(id uint) (*models.Branch, error) {
+	for _, branch := range m.branches {
+		if branch.ID == id {
+			return branch, nil
+		}
+	}
+	return nil, services.ErrBranchNotFound
+}
+
+func (m *mockedBranchService) CreateBranch(branch *models.Branch) (*models.Branch, error) {
+	for _, existing := range m.branches {
+		if strings.EqualFold(existing.Name, branch.Name) {
+			return nil, services.ErrDuplicateBranch
+		}
+	}
+	branch.ID = uint(len(m.branches) + 1)
+	m.branches = append(m.branches, branch)
+	return branch, nil
+}
+
+func (m *mockedBranchService) UpdateBranch(payload *models.Branch) (*models.Branch, error) {
+	branch, err := m.GetBranch(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	branch.Name, branch.Address = payload.Name, payload.Address
+	return branch, nil
+}
+
+// DeleteBranch treats the first branch as holding copies.
+func (m *mockedBranchService) DeleteBranch(id uint) (*models.Branch, error) {
+	branch, err := m.GetBranch(id)
+	if err != nil {
+		return nil, err
+	}
+	if id == 1 {
+		return nil, services.ErrBranchInUse
+	}
+	m.branches = slices.DeleteFunc(m.branches, func(b *models.Branch) bool { return b.ID == id })
+	return branch, nil
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+const (
+	mimeNDJSON        = "application/x-ndjson"
+	maxBulkOperations = 1000
+)
+
+type bulkItemResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op,omitempty"`
+	Status int          `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Errors []fieldError `json:"errors,omitempty"`
+	Data   any          `json:"data,omitempty"`
+}
+
+// invalid reports the operation as rejected by problem, its field errors
+// pointing into the body from the operation's index, eg: /3/book/title.
+func (r *bulkItemResult) invalid(problem error) {
+	p := problem.(*problemError)
+	r.Status, r.Error = p.status, p.detail
+	for _, field := range p.errors {
+		field.Pointer = fmt.Sprintf("/%d%s", r.Index, field.Pointer)
+		r.Errors = append(r.Errors, field)
+	}
+}
+
+// decodeBulkBody splits a JSON array or an NDJSON body into its items, so each
+// one can be decoded and reported on by its index.
+func decodeBulkBody(contentType string, body []byte) ([]json.RawMessage, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case fiber.MIMEApplicationJSON:
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+		return items, nil
+
+	case mimeNDJSON:
+		var items []json.RawMessage
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, len(body)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			items = append(items, json.RawMessage(bytes.Clone(line)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+		return items, nil
+	}
+
+	return nil, fiber.NewError(fiber.StatusUnsupportedMediaType, "invalid Content-Type")
+}
+
+func (handler *BookHandler) bulkBooks(c *fiber.Ctx) error {
+	mode := c.Query("mode", "atomic")
+	if mode != "atomic" && mode != "partial" {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid mode")
+	}
+	atomic := mode == "atomic"
+
+	items, err := decodeBulkBody(c.Get(fiber.HeaderContentType), c.Body())
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(items) == 0:
+		return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+	case len(items) > maxBulkOperations:
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "too many operations")
+	}
+
+	results := make([]bulkItemResult, len(items))
+	var ops []services.BulkOperation
+	var positions []int
+
+	for i, item := range items {
+		results[i].Index = i
+
+		var op services.BulkOperation
+		if err := json.Unmarshal(item, &op); err != nil {
+			results[i].invalid(bodyProblem(err))
+			continue
+		}
+		results[i].Op = op.Op
+
+		if err := handler.validate.Struct(&op); err != nil {
+			results[i].invalid(validationProblem(err))
+			continue
+		}
+
+		ops = append(ops, op)
+		positions = append(positions, i)
+	}
+
+	// in atomic mode nothing is written as soon as one operation is invalid
+	if atomic && len(ops) != len(items) {
+		for _, i := range positions {
+			results[i].Status, results[i].Error = fiber.StatusFailedDependency, "not executed"
+		}
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(apiResponse{
+			Message: "error",
+			Error:   services.ErrBulkAborted.Error(),
+			Data:    results,
+		})
+	}
+
+	var outcomes []services.BulkResult
+	if len(ops) > 0 {
+		outcomes, err = handler.books(c).BulkWrite(ops, atomic)
+		if err != nil && !errors.Is(err, services.ErrBulkAborted) {
+			return err
+		}
+	}
+	aborted := err != nil
+
+	failed := len(ops) != len(items)
+	for j, outcome := range outcomes {
+		result := &results[positions[j]]
+		switch {
+		case outcome.Err != nil:
+			result.Status, result.Error = errorStatus(outcome.Err), outcome.Err.Error()
+			failed = true
+		case aborted:
+			result.Status, result.Error = fiber.StatusFailedDependency, "rolled back"
+		case result.Op == services.BulkCreate:
+			result.Status, result.Data = fiber.StatusCreated, outcome.Book
+		default:
+			result.Status, result.Data = fiber.StatusOK, outcome.Book
+		}
+	}
+
+	switch {
+	case aborted:
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(apiResponse{
+			Message: "error",
+			Error:   services.ErrBulkAborted.Error(),
+			Data:    results,
+		})
+	case failed:
+		return c.Status(fiber.StatusMultiStatus).JSON(apiResponse{
+			Message: "partial success",
+			Data:    results,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    results,
+	})
+}
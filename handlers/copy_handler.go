@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type CopyHandler struct {
+	copyService services.ICopyService
+	validate    *validator.Validate
+}
+
+func NewCopyHandler(service services.ICopyService, validator *validator.Validate) *CopyHandler {
+	return &CopyHandler{
+		copyService: service,
+		validate:    validator,
+	}
+}
+
+// copies returns the copy service of the tenant the request is made for.
+func (handler *CopyHandler) copies(c *fiber.Ctx) services.ICopyService {
+	return handler.copyService.ForTenant(requestTenant(c))
+}
+
+func (handler *CopyHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/copies", reader, handler.getAllCopies)
+	router.Get("/copies/barcode/:barcode", reader, handler.getCopyByBarcode)
+	router.Get("/copies/:id", reader, handler.getCopy)
+	router.Post("/copies", librarian, handler.newCopy)
+	router.Put("/copies/:id", librarian, handler.updateCopy)
+	router.Delete("/copies/:id", librarian, handler.deleteCopy)
+}
+
+func (handler *CopyHandler) getAllCopies(c *fiber.Ctx) error {
+	var filter services.CopyFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid query parameter")
+	}
+
+	if err := handler.validate.Struct(&filter); err != nil {
+		return validationProblem(err)
+	}
+
+	page, limit := paginationParams(c)
+
+	copies, total, err := handler.copies(c).GetAllCopies(filter, page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    copies,
+		Meta:    meta,
+	})
+}
+
+func (handler *CopyHandler) getCopy(c *fiber.Ctx) error {
+	copyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	bookCopy, err := handler.copies(c).GetCopy(uint(copyId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    bookCopy,
+	})
+}
+
+func (handler *CopyHandler) getCopyByBarcode(c *fiber.Ctx) error {
+	bookCopy, err := handler.copies(c).GetCopyByBarcode(c.Params("barcode"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    bookCopy,
+	})
+}
+
+func (handler *CopyHandler) newCopy(c *fiber.Ctx) error {
+	var bookCopy models.Copy
+	if err := parseBody(c, &bookCopy); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&bookCopy); err != nil {
+		return validationProblem(err)
+	}
+
+	createdCopy, err := handler.copies(c).CreateCopy(&bookCopy)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdCopy,
+	})
+}
+
+func (handler *CopyHandler) updateCopy(c *fiber.Ctx) error {
+	copyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var bookCopy models.Copy
+	if err := parseBody(c, &bookCopy); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&bookCopy); err != nil {
+		return validationProblem(err)
+	}
+
+	bookCopy.ID = uint(copyId)
+	updatedCopy, err := handler.copies(c).UpdateCopy(&bookCopy)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedCopy,
+	})
+}
+
+func (handler *CopyHandler) deleteCopy(c *fiber.Ctx) error {
+	copyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	bookCopy, err := handler.copies(c).DeleteCopy(uint(copyId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    bookCopy,
+	})
+}
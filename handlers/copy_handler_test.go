@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupCopyTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewCopyHandler(NewMockedCopyService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestCopyHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("list copies", func(t *testing.T) {
+		app := setupCopyTestApp(t)
+		res, response := send(app, "GET", "/copies?status=on_loan", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, "C002", response.Data.([]any)[0].(map[string]any)["barcode"])
+
+		res, _ = send(app, "GET", "/copies?status=shelved", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "GET", "/copies?book_id=one", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("get copy", func(t *testing.T) {
+		app := setupCopyTestApp(t)
+		res, response := send(app, "GET", "/copies/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "C001", response.Data.(map[string]any)["barcode"])
+
+		res, response = send(app, "GET", "/copies/barcode/c002", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, float64(2), response.Data.(map[string]any)["ID"])
+
+		res, _ = send(app, "GET", "/copies/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("create copy", func(t *testing.T) {
+		app := setupCopyTestApp(t)
+		res, response := send(app, "POST", "/copies", `{"book_id": 1, "barcode": "C003", "branch_id": 1, "shelf": "FIC BRO", "condition": "new"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "available", response.Data.(map[string]any)["status"])
+
+		res, _ = send(app, "POST", "/copies", `{"book_id": 1, "barcode": "c001", "branch_id": 1}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "POST", "/copies", `{"book_id": 99, "barcode": "C004", "branch_id": 1}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		req := httptest.NewRequest("POST", "/copies", strings.NewReader(`{"barcode": "C-004", "branch_id": 1, "condition": "mint", "status": "gone"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, _ = app.Test(req, -1)
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, []fieldError{
+			{Pointer: "/book_id", Rule: "required", Message: "book_id is required"},
+			{Pointer: "/barcode", Rule: "alphanum", Message: "barcode must only contain letters and digits"},
+			{Pointer: "/condition", Rule: "oneof", Message: "condition must be one of new, good, fair, poor, damaged"},
+			{Pointer: "/status", Rule: "oneof", Message: "status must be one of available, on_loan, lost, in_repair, on_hold"},
+		}, p.Errors)
+	})
+
+	t.Run("update and delete copy", func(t *testing.T) {
+		app := setupCopyTestApp(t)
+		res, response := send(app, "PUT", "/copies/1", `{"book_id": 1, "barcode": "C001", "branch_id": 1, "status": "in_repair"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "in_repair", response.Data.(map[string]any)["status"])
+
+		res, _ = send(app, "DELETE", "/copies/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/copies/1", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+type mockedCopyService struct {
+	copies []*models.Copy
+	tenant string
+}
+
+var _ services.ICopyService = (*mockedCopyService)(nil)
+
+func NewMockedCopyService() *mockedCopyService {
+	copies := []*models.Copy{
+		{BookID: 1, Barcode: "C001", BranchID: 1, Status: models.CopyAvailable},
+		{BookID: 1, Barcode: "C002", BranchID: 1, Status: models.CopyOnLoan},
+	}
+	for i, bookCopy := range copies {
+		bookCopy.ID = uint(i + 1)
+	}
+
+	return &mockedCopyService{copies: copies}
+}
+
+func (m *mockedCopyService) ForTenant(tenant *models.Tenant) services.ICopyService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedCopyService) GetAllCopies(filter services.CopyFilter, page, limit int) ([]*models.Copy, int64, error) {
+	var copies []*models.Copy
+	for _, bookCopy := range m.copies {
+		if filter.Status == "" || bookCopy.Status == filter.Status {
+			copies = append(copies, bookCopy)
+		}
+	}
+	return copies, int64(len(copies)), nil
+}
+
+func (m *mockedCopyService) GetCopy(id uint) (*models.Copy, error) {
+	for _, bookCopy := range m.copies {
+		if bookCopy.ID == id {
+			return bookCopy, nil
+		}
+	}
+	return nil, services.ErrCopyNotFound
+}
+
+func (m *mockedCopyService) GetCopyByBarcode(barcode string) (*models.Copy, error) {
+	for _, bookCopy := range m.copies {
+		if strings.EqualFold(bookCopy.Barcode, barcode) {
+			return bookCopy, nil
+		}
+	}
+	return nil, services.ErrCopyNotFound
+}
+
+// CreateCopy only knows of the book with id 1.
+func (m *mockedCopyService) CreateCopy(bookCopy *models.Copy) (*models.Copy, error) {
+	if bookCopy.BookID != 1 {
+		return nil, services.ErrUnknownBook
+	}
+	if _, err := m.GetCopyByBarcode(bookCopy.Barcode); err == nil {
+		return nil, services.ErrDuplicateBarcode
+	}
+	if bookCopy.Status == "" {
+		bookCopy.Status = models.CopyAvailable
+	}
+	bookCopy.ID = uint(len(m.copies) + 1)
+	m.copies = append(m.copies, bookCopy)
+	return bookCopy, nil
+}
+
+func (m *mockedCopyService) UpdateCopy(payload *models.Copy) (*models.Copy, error) {
+	bookCopy, err := m.GetCopy(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	*bookCopy = *payload
+	return bookCopy, nil
+}
+
+func (m *mockedCopyService) DeleteCopy(id uint) (*models.Copy, error) {
+	bookCopy, err := m.GetCopy(id)
+	if err != nil {
+		return nil, err
+	}
+	m.copies = slices.DeleteFunc(m.copies, func(c *models.Copy) bool { return c.ID == id })
+	return bookCopy, nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+// etag returns the strong entity tag of a resource at the given version.
+func etag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// sendTagged sends response, which describes a book at the given version,
+// with the entity tag of that version, the same tag writes return. A matching
+// If-None-Match gets 304 instead.
+func sendTagged(c *fiber.Ctx, version uint, response apiResponse) error {
+	tag := etag(version)
+	c.Set(fiber.HeaderETag, tag)
+	if noneMatch(c, tag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// ifMatchVersion returns the version required by the If-Match header, or 0
+// when the request is unconditional. Only a single strong entity tag is
+// supported, compared as a whole with the tag of the version it names, so
+// anything else can never match and fails with 412.
+func ifMatchVersion(c *fiber.Ctx) (uint, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		tag, ok = strings.CutSuffix(tag, `"`)
+	}
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if !ok || err != nil || version == 0 || etag(uint(version)) != header {
+		return 0, services.ErrVersionMismatch
+	}
+	return uint(version), nil
+}
+
+// noneMatch reports whether the If-None-Match header matches the current tag,
+// using the weak comparison required for GET requests.
+func noneMatch(c *fiber.Ctx, current string) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == current {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/xuri/excelize/v2"
+)
+
+const mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+var exportColumns = []string{
+	"id", "title", "author", "year", "isbn",
+	"publisher", "edition", "format", "page_count", "language", "published_on",
+	"version", "created_at", "updated_at",
+}
+
+func exportISBN(book *models.Book) string {
+	if book.ISBN == nil {
+		return ""
+	}
+	return *book.ISBN
+}
+
+func exportPublisher(book *models.Book) string {
+	if book.Publisher == nil {
+		return ""
+	}
+	return book.Publisher.Name
+}
+
+func exportPageCount(book *models.Book) string {
+	if book.PageCount == 0 {
+		return ""
+	}
+	return strconv.Itoa(book.PageCount)
+}
+
+func exportPublishedOn(book *models.Book) string {
+	if book.PublishedOn == nil {
+		return ""
+	}
+	return book.PublishedOn.String()
+}
+
+func exportRecord(book *models.Book) []string {
+	return []string{
+		strconv.FormatUint(uint64(book.ID), 10),
+		book.Title,
+		book.Author,
+		strconv.Itoa(book.Year),
+		exportISBN(book),
+		exportPublisher(book),
+		book.Edition,
+		book.Format,
+		exportPageCount(book),
+		book.Language,
+		exportPublishedOn(book),
+		strconv.FormatUint(uint64(book.Version), 10),
+		book.CreatedAt.Format(time.RFC3339),
+		book.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// exportFunc calls fn for every exported book.
+type exportFunc func(fn func(book *models.Book) error) error
+
+// exportWriter prepares the export of the books in one export format, and
+// returns the function writing it to the response. Errors returned before the
+// response starts can still be answered with a problem.
+type exportWriter func(export exportFunc) (func(w io.Writer) error, error)
+
+var exportFormats = map[string]struct {
+	contentType string
+	write       exportWriter
+}{
+	"csv":    {mimeCSV, streamed(writeCSV)},
+	"ndjson": {mimeNDJSON, streamed(writeNDJSON)},
+	"json":   {fiber.MIMEApplicationJSON, streamed(writeJSON)},
+	"xlsx":   {mimeXLSX, buildXLSX},
+}
+
+// streamed reads the exported books while write streams them to the
+// response, so its errors can only cut the export short.
+func streamed(write func(w io.Writer, export exportFunc) error) exportWriter {
+	return func(export exportFunc) (func(w io.Writer) error, error) {
+		return func(w io.Writer) error {
+			return write(w, export)
+		}, nil
+	}
+}
+
+func writeCSV(w io.Writer, export exportFunc) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	err := export(func(book *models.Book) error {
+		return writer.Write(exportRecord(book))
+	})
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func writeNDJSON(w io.Writer, export exportFunc) error {
+	encoder := json.NewEncoder(w)
+	return export(func(book *models.Book) error {
+		return encoder.Encode(book)
+	})
+}
+
+func writeJSON(w io.Writer, export exportFunc) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	separator := ""
+	err := export(func(book *models.Book) error {
+		data, err := json.Marshal(book)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		separator = ","
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// buildXLSX builds the whole workbook before the response starts, with the
+// excelize stream writer, which keeps rows in a temporary file rather than in
+// memory until the workbook is written out.
+func buildXLSX(export exportFunc) (func(w io.Writer) error, error) {
+	file := excelize.NewFile()
+	if err := fillXLSX(file, export); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func(w io.Writer) error {
+		defer file.Close()
+		_, err := file.WriteTo(w)
+		return err
+	}, nil
+}
+
+func fillXLSX(file *excelize.File, export exportFunc) error {
+	sheet, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return err
+	}
+
+	header := make([]any, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	if err := sheet.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	row := 2
+	err = export(func(book *models.Book) error {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		row++
+		return sheet.SetRow(cell, []any{
+			book.ID,
+			book.Title,
+			book.Author,
+			book.Year,
+			exportISBN(book),
+			exportPublisher(book),
+			book.Edition,
+			book.Format,
+			exportPageCount(book),
+			book.Language,
+			exportPublishedOn(book),
+			book.Version,
+			book.CreatedAt.Format(time.RFC3339),
+			book.UpdatedAt.Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return sheet.Flush()
+}
+
+func (handler *BookHandler) exportBooks(c *fiber.Ctx) error {
+	format, ok := exportFormats[c.Query("format", "csv")]
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid export format")
+	}
+
+	query, err := handler.bookQuery(c)
+	if err != nil {
+		return err
+	}
+
+	books := handler.books(c)
+	write, err := format.write(func(fn func(book *models.Book) error) error {
+		return books.ExportBooks(query, fn)
+	})
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("books-%s.%s", time.Now().Format("20060102"), c.Query("format", "csv"))
+	c.Set(fiber.HeaderContentType, format.contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// the body is written after the handler returns, so errors past this
+	// point can only cut the export short, and are logged
+	logger := handler.logger.With("tenant", requestTenant(c).ID, "content_type", format.contentType)
+	c.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := write(w); err != nil {
+			logger.Error("export cut short", "error", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
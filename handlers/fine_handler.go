@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type FineHandler struct {
+	fineService services.IFineService
+	validate    *validator.Validate
+}
+
+func NewFineHandler(service services.IFineService, validator *validator.Validate) *FineHandler {
+	return &FineHandler{
+		fineService: service,
+		validate:    validator,
+	}
+}
+
+// fines returns the fine service of the tenant the request is made for.
+func (handler *FineHandler) fines(c *fiber.Ctx) services.IFineService {
+	return handler.fineService.ForTenant(requestTenant(c))
+}
+
+func (handler *FineHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/fine-policies", reader, handler.getFinePolicies)
+	router.Post("/fine-policies", librarian, handler.newFinePolicy)
+	router.Put("/fine-policies/:id", librarian, handler.updateFinePolicy)
+	router.Delete("/fine-policies/:id", librarian, handler.deleteFinePolicy)
+	router.Get("/branches/:id/closed-days", reader, handler.getClosedDays)
+	router.Post("/branches/:id/closed-days", librarian, handler.addClosedDay)
+	router.Delete("/branches/:id/closed-days/:date", librarian, handler.removeClosedDay)
+	router.Get("/patrons/:id/balance", librarian, handler.getBalance)
+	router.Get("/patrons/:id/ledger", librarian, handler.getLedger)
+	router.Post("/patrons/:id/payments", librarian, handler.newEntry(models.EntryPayment))
+	router.Post("/patrons/:id/waivers", librarian, handler.newEntry(models.EntryWaiver))
+}
+
+func (handler *FineHandler) getFinePolicies(c *fiber.Ctx) error {
+	policies, err := handler.fineService.GetFinePolicies()
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    policies,
+	})
+}
+
+func (handler *FineHandler) newFinePolicy(c *fiber.Ctx) error {
+	var policy models.FinePolicy
+	if err := parseBody(c, &policy); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&policy); err != nil {
+		return validationProblem(err)
+	}
+
+	createdPolicy, err := handler.fineService.CreateFinePolicy(&policy)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdPolicy,
+	})
+}
+
+func (handler *FineHandler) updateFinePolicy(c *fiber.Ctx) error {
+	policyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var policy models.FinePolicy
+	if err := parseBody(c, &policy); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&policy); err != nil {
+		return validationProblem(err)
+	}
+
+	policy.ID = uint(policyId)
+	updatedPolicy, err := handler.fineService.UpdateFinePolicy(&policy)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedPolicy,
+	})
+}
+
+func (handler *FineHandler) deleteFinePolicy(c *fiber.Ctx) error {
+	policyId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	policy, err := handler.fineService.DeleteFinePolicy(uint(policyId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    policy,
+	})
+}
+
+func (handler *FineHandler) getClosedDays(c *fiber.Ctx) error {
+	branchId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	days, err := handler.fineService.GetClosedDays(uint(branchId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    days,
+	})
+}
+
+func (handler *FineHandler) addClosedDay(c *fiber.Ctx) error {
+	branchId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var day models.ClosedDay
+	if err := parseBody(c, &day); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&day); err != nil {
+		return validationProblem(err)
+	}
+
+	day.ID = 0
+	day.BranchID = uint(branchId)
+	addedDay, err := handler.fineService.AddClosedDay(&day)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    addedDay,
+	})
+}
+
+func (handler *FineHandler) removeClosedDay(c *fiber.Ctx) error {
+	branchId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	day, err := handler.fineService.RemoveClosedDay(uint(branchId), c.Params("date"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    day,
+	})
+}
+
+func (handler *FineHandler) getBalance(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	balance, err := handler.fines(c).GetBalance(uint(patronId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    balance,
+	})
+}
+
+func (handler *FineHandler) getLedger(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	page, limit := paginationParams(c)
+
+	entries, total, err := handler.fines(c).GetLedger(uint(patronId), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    entries,
+		Meta:    meta,
+	})
+}
+
+// newEntry records a payment or a waiver, waivers needing admin privileges.
+func (handler *FineHandler) newEntry(kind string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		patronId, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+		}
+
+		if kind == models.EntryWaiver && !isAdmin(c) {
+			return fiber.NewError(fiber.StatusForbidden, "admin privileges required")
+		}
+
+		var entry models.LedgerEntry
+		if err := parseBody(c, &entry); err != nil {
+			return err
+		}
+
+		if err := handler.validate.Struct(&entry); err != nil {
+			return validationProblem(err)
+		}
+
+		entry.ID = 0
+		entry.PatronID = uint(patronId)
+		entry.Kind = kind
+		recordedEntry, err := handler.fines(c).RecordEntry(&entry)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusCreated).JSON(apiResponse{
+			Message: "success",
+			Data:    recordedEntry,
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupFineTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewFineHandler(NewMockedFineService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(AdminAuth(testAdminToken))
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestFineHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string, headers ...string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("fine policies", func(t *testing.T) {
+		app := setupFineTestApp(t)
+		res, response := send(app, "POST", "/fine-policies", `{"format": "audiobook", "daily_rate": 50, "max_fine": 1000}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, float64(50), response.Data.(map[string]any)["daily_rate"])
+
+		res, _ = send(app, "POST", "/fine-policies", `{"daily_rate": 10}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "POST", "/fine-policies", `{"format": "scroll", "daily_rate": -1}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, response = send(app, "GET", "/fine-policies", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 2)
+
+		res, _ = send(app, "DELETE", "/fine-policies/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("closed days", func(t *testing.T) {
+		app := setupFineTestApp(t)
+		res, _ := send(app, "POST", "/branches/1/closed-days", `{"date": "2024-12-25"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res, _ = send(app, "POST", "/branches/1/closed-days", `{"date": "25/12/2024"}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, response := send(app, "GET", "/branches/1/closed-days", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+
+		res, _ = send(app, "DELETE", "/branches/1/closed-days/2024-12-25", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/branches/1/closed-days/2024-12-25", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("balance and ledger", func(t *testing.T) {
+		app := setupFineTestApp(t)
+		res, response := send(app, "GET", "/patrons/1/balance", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, float64(300), response.Data.(map[string]any)["balance"])
+
+		res, _ = send(app, "POST", "/patrons/1/payments", `{"amount": 500}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res, _ = send(app, "POST", "/patrons/1/payments", `{"amount": 100, "note": "cash"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+		res, _ = send(app, "POST", "/patrons/1/waivers", `{"amount": 100}`)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, response = send(app, "POST", "/patrons/1/waivers", `{"amount": 100}`, "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, models.EntryWaiver, response.Data.(map[string]any)["kind"])
+
+		res, response = send(app, "GET", "/patrons/1/balance", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, float64(100), response.Data.(map[string]any)["balance"])
+
+		res, response = send(app, "GET", "/patrons/1/ledger?limit=2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, float64(3), response.Meta.(map[string]any)["total"])
+
+		res, _ = send(app, "GET", "/patrons/99/balance", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+// mockedFineService knows branch 1 and patron 1, who was charged 300.
+type mockedFineService struct {
+	policies []*models.FinePolicy
+	days     []*models.ClosedDay
+	entries  []*models.LedgerEntry
+	tenant   string
+}
+
+var _ services.IFineService = (*mockedFineService)(nil)
+
+func NewMockedFineService() *mockedFineService {
+	policy := &models.FinePolicy{DailyRate: 25, GraceDays: 1, MaxFine: 500}
+	policy.ID = 1
+	charge := &models.LedgerEntry{PatronID: 1, Kind: models.EntryCharge, Amount: 300}
+	charge.ID = 1
+	return &mockedFineService{policies: []*models.FinePolicy{policy}, entries: []*models.LedgerEntry{charge}}
+}
+
+func (m *mockedFineService) ForTenant(tenant *models.Tenant) services.IFineService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedFineService) GetFinePolicies() ([]*models.FinePolicy, error) {
+	return m.policies, nil
+}
+
+func (m *mockedFineService) CreateFinePolicy(policy *models.FinePolicy) (*models.FinePolicy, error) {
+	for _, existing := range m.policies {
+		if existing.Format == policy.Format {
+			return nil, services.ErrDuplicateFinePolicy
+		}
+	}
+	policy.ID = uint(len(m.policies) + 1)
+	m.policies = append(m.policies, policy)
+	return policy, nil
+}
+
+func (m *mockedFineService) UpdateFinePolicy(payload *models.FinePolicy) (*models.FinePolicy, error) {
+	for i, policy := range m.policies {
+		if policy.ID == payload.ID {
+			m.policies[i] = payload
+			return payload, nil
+		}
+	}
+	return nil, services.ErrFinePolicyNotFound
+}
+
+func (m *mockedFineService) DeleteFinePolicy(id uint) (*models.FinePolicy, error) {
+	for _, policy := range m.policies {
+		if policy.ID == id {
+			m.policies = slices.DeleteFunc(m.policies, func(p *models.FinePolicy) bool { return p.ID == id })
+			return policy, nil
+		}
+	}
+	return nil, services.ErrFinePolicyNotFound
+}
+
+func (m *mockedFineService) GetClosedDays(branchID uint) ([]*models.ClosedDay, error) {
+	if branchID != 1 {
+		return nil, services.ErrBranchNotFound
+	}
+	return m.days, nil
+}
+
+func (m *mockedFineService) AddClosedDay(day *models.ClosedDay) (*models.ClosedDay, error) {
+	if day.BranchID != 1 {
+		return nil, services.ErrBranchNotFound
+	}
+	day.ID = uint(len(m.days) + 1)
+	m.days = append(m.days, day)
+	return day, nil
+}
+
+func (m *mockedFineService) RemoveClosedDay(branchID uint, date string) (*models.ClosedDay, error) {
+	for _, day := range m.days {
+		if day.BranchID == branchID && day.Date == date {
+			m.days = slices.DeleteFunc(m.days, func(d *models.ClosedDay) bool { return d == day })
+			return day, nil
+		}
+	}
+	return nil, services.ErrClosedDayNotFound
+}
+
+func (m *mockedFineService) GetBalance(patronID uint) (*models.Balance, error) {
+	if patronID != 1 {
+		return nil, services.ErrPatronNotFound
+	}
+	balance := &models.Balance{PatronID: patronID}
+	for _, entry := range m.entries {
+		switch entry.Kind {
+		case models.EntryCharge:
+			balance.Charges += entry.Amount
+		case models.EntryPayment:
+			balance.Payments += entry.Amount
+		case models.EntryWaiver:
+			balance.Waivers += entry.Amount
+		}
+	}
+	balance.Balance = balance.Charges - balance.Payments - balance.Waivers
+	return balance, nil
+}
+
+func (m *mockedFineService) GetLedger(patronID uint, page, limit int) ([]*models.LedgerEntry, int64, error) {
+	if patronID != 1 {
+		return nil, 0, services.ErrPatronNotFound
+	}
+	start := min((page-1)*limit, len(m.entries))
+	end := min(start+limit, len(m.entries))
+	return m.entries[start:end], int64(len(m.entries)), nil
+}
+
+func (m *mockedFineService) RecordEntry(entry *models.LedgerEntry) (*models.LedgerEntry, error) {
+	balance, err := m.GetBalance(entry.PatronID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Amount > balance.Balance {
+		return nil, services.ErrOverpayment
+	}
+	entry.ID = uint(len(m.entries) + 1)
+	m.entries = append(m.entries, entry)
+	return entry, nil
+}
+
+func (m *mockedFineService) AccrueFines() (int64, error) {
+	return 0, nil
+}
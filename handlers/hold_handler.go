@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type HoldHandler struct {
+	holdService services.IHoldService
+	validate    *validator.Validate
+}
+
+func NewHoldHandler(service services.IHoldService, validator *validator.Validate) *HoldHandler {
+	return &HoldHandler{
+		holdService: service,
+		validate:    validator,
+	}
+}
+
+// holds returns the hold service of the tenant the request is made for.
+func (handler *HoldHandler) holds(c *fiber.Ctx) services.IHoldService {
+	return handler.holdService.ForTenant(requestTenant(c))
+}
+
+func (handler *HoldHandler) SetupRoutes(router fiber.Router) {
+	librarian := requireRole(RoleLibrarian)
+	router.Get("/books/:id/holds", librarian, handler.getBookHolds)
+	router.Post("/books/:id/holds", librarian, handler.placeHold)
+	router.Get("/holds/:id", librarian, handler.getHold)
+	router.Delete("/holds/:id", librarian, handler.cancelHold)
+	router.Get("/patrons/:id/notifications", librarian, handler.getPatronNotifications)
+}
+
+func (handler *HoldHandler) getBookHolds(c *fiber.Ctx) error {
+	bookId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	page, limit := paginationParams(c)
+
+	holds, total, err := handler.holds(c).GetBookHolds(uint(bookId), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    holds,
+		Meta:    meta,
+	})
+}
+
+func (handler *HoldHandler) placeHold(c *fiber.Ctx) error {
+	bookId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var hold models.Hold
+	if err := parseBody(c, &hold); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&hold); err != nil {
+		return validationProblem(err)
+	}
+
+	hold.BookID = uint(bookId)
+	placedHold, err := handler.holds(c).PlaceHold(&hold)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    placedHold,
+	})
+}
+
+func (handler *HoldHandler) getHold(c *fiber.Ctx) error {
+	holdId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	hold, err := handler.holds(c).GetHold(uint(holdId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    hold,
+	})
+}
+
+func (handler *HoldHandler) cancelHold(c *fiber.Ctx) error {
+	holdId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	hold, err := handler.holds(c).CancelHold(uint(holdId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    hold,
+	})
+}
+
+func (handler *HoldHandler) getPatronNotifications(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	page, limit := paginationParams(c)
+
+	notifications, total, err := handler.holds(c).GetPatronNotifications(uint(patronId), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    notifications,
+		Meta:    meta,
+	})
+}
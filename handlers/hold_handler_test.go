@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHoldTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewHoldHandler(NewMockedHoldService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestHoldHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("place hold", func(t *testing.T) {
+		app := setupHoldTestApp(t)
+		res, response := send(app, "POST", "/books/1/holds", `{"patron_id": 2, "pickup_branch_id": 1}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, float64(2), response.Data.(map[string]any)["position"])
+
+		res, _ = send(app, "POST", "/books/1/holds", `{"patron_id": 2, "pickup_branch_id": 1}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "POST", "/books/99/holds", `{"patron_id": 3, "pickup_branch_id": 1}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "POST", "/books/1/holds", `{"patron_id": 3}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("list holds", func(t *testing.T) {
+		app := setupHoldTestApp(t)
+		res, response := send(app, "GET", "/books/1/holds", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, float64(1), response.Meta.(map[string]any)["total"])
+	})
+
+	t.Run("cancel hold", func(t *testing.T) {
+		app := setupHoldTestApp(t)
+		res, response := send(app, "DELETE", "/holds/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.HoldCancelled, response.Data.(map[string]any)["status"])
+
+		res, _ = send(app, "DELETE", "/holds/1", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "GET", "/holds/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("patron notifications", func(t *testing.T) {
+		app := setupHoldTestApp(t)
+		res, response := send(app, "GET", "/patrons/1/notifications", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.EventHoldPlaced, response.Data.([]any)[0].(map[string]any)["event"])
+
+		res, _ = send(app, "GET", "/patrons/99/notifications", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+// mockedHoldService knows book 1 and patrons 1 to 3, patron 1 waiting for
+// book 1.
+type mockedHoldService struct {
+	holds  []*models.Hold
+	tenant string
+}
+
+var _ services.IHoldService = (*mockedHoldService)(nil)
+
+func NewMockedHoldService() *mockedHoldService {
+	hold := &models.Hold{BookID: 1, PatronID: 1, PickupBranchID: 1, Status: models.HoldWaiting}
+	hold.ID = 1
+	return &mockedHoldService{holds: []*models.Hold{hold}}
+}
+
+func (m *mockedHoldService) ForTenant(tenant *models.Tenant) services.IHoldService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedHoldService) PlaceHold(hold *models.Hold) (*models.Hold, error) {
+	if hold.BookID != 1 {
+		return nil, services.ErrNotFound
+	}
+	for _, existing := range m.holds {
+		if existing.PatronID == hold.PatronID && existing.Status == models.HoldWaiting {
+			return nil, services.ErrDuplicateHold
+		}
+	}
+	hold.ID = uint(len(m.holds) + 1)
+	hold.Status = models.HoldWaiting
+	hold.Position = len(m.holds) + 1
+	m.holds = append(m.holds, hold)
+	return hold, nil
+}
+
+func (m *mockedHoldService) GetHold(id uint) (*models.Hold, error) {
+	for _, hold := range m.holds {
+		if hold.ID == id {
+			return hold, nil
+		}
+	}
+	return nil, services.ErrHoldNotFound
+}
+
+func (m *mockedHoldService) GetBookHolds(bookID uint, page, limit int) ([]*models.Hold, int64, error) {
+	if bookID != 1 {
+		return nil, 0, services.ErrNotFound
+	}
+	return m.holds, int64(len(m.holds)), nil
+}
+
+func (m *mockedHoldService) CancelHold(id uint) (*models.Hold, error) {
+	hold, err := m.GetHold(id)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != models.HoldWaiting && hold.Status != models.HoldReady {
+		return nil, services.ErrHoldClosed
+	}
+	hold.Status = models.HoldCancelled
+	return hold, nil
+}
+
+func (m *mockedHoldService) ExpireHolds() (int64, error) {
+	return 0, nil
+}
+
+func (m *mockedHoldService) GetPatronNotifications(patronID uint, page, limit int) ([]*models.Notification, int64, error) {
+	if patronID < 1 || patronID > 3 {
+		return nil, 0, services.ErrPatronNotFound
+	}
+	var notifications []*models.Notification
+	for _, hold := range m.holds {
+		if hold.PatronID == patronID {
+			notifications = append(notifications, &models.Notification{PatronID: patronID, Event: models.EventHoldPlaced, HoldID: hold.ID, BookID: hold.BookID})
+		}
+	}
+	return notifications, int64(len(notifications)), nil
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+const (
+	mimeCSV = "text/csv"
+
+	importRejected = services.ImportRejected
+)
+
+var importFields = []string{
+	"title", "author", "year", "isbn",
+	"publisher", "edition", "format", "page_count", "language", "published_on",
+}
+
+type importRowResult struct {
+	Row    int          `json:"row"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Data   *models.Book `json:"data,omitempty"`
+}
+
+type importSummary struct {
+	DryRun     bool `json:"dry_run"`
+	Created    int  `json:"created"`
+	Duplicates int  `json:"duplicates"`
+	Rejected   int  `json:"rejected"`
+}
+
+// columnMapping maps book fields to the column, or key, they are read from.
+// Fields map to their own name unless the map query parameter, written as
+// "title:Book Title,year:Published", says otherwise.
+func columnMapping(spec string) (map[string]string, error) {
+	mapping := make(map[string]string, len(importFields))
+	for _, field := range importFields {
+		mapping[field] = field
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(pair, ":")
+		field, column = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(column)
+		if _, known := mapping[field]; !ok || !known || column == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid column mapping")
+		}
+		mapping[field] = column
+	}
+	return mapping, nil
+}
+
+// decodeImportBody reads the records of a CSV, JSON array or NDJSON body as
+// key/value pairs. CSV keys come from the header row. A record that can't be
+// decoded is returned as nil so it can be reported by its row.
+func decodeImportBody(contentType string, body []byte) ([]map[string]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case mimeCSV:
+		reader := csv.NewReader(bytes.NewReader(body))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		var records []map[string]string
+		for {
+			row, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil || len(row) != len(header) {
+				var parseErr *csv.ParseError
+				if err != nil && !errors.As(err, &parseErr) {
+					return nil, fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+				}
+				records = append(records, nil)
+				continue
+			}
+
+			record := make(map[string]string, len(header))
+			for i, column := range header {
+				record[strings.ToLower(column)] = row[i]
+			}
+			records = append(records, record)
+		}
+		return records, nil
+
+	case fiber.MIMEApplicationJSON, mimeNDJSON:
+		items, err := decodeBulkBody(mediaType, body)
+		if err != nil {
+			return nil, err
+		}
+
+		records := make([]map[string]string, len(items))
+		for i, item := range items {
+			var object map[string]any
+			if err := json.Unmarshal(item, &object); err != nil {
+				continue
+			}
+
+			record := make(map[string]string, len(object))
+			for key, value := range object {
+				switch value := value.(type) {
+				case string:
+					record[strings.ToLower(key)] = value
+				case float64:
+					record[strings.ToLower(key)] = strconv.FormatFloat(value, 'f', -1, 64)
+				}
+			}
+			records[i] = record
+		}
+		return records, nil
+	}
+
+	return nil, fiber.NewError(fiber.StatusUnsupportedMediaType, "invalid Content-Type")
+}
+
+// bookFromRecord builds a book out of a record using the column mapping.
+func bookFromRecord(record map[string]string, mapping map[string]string) (*models.Book, error) {
+	if record == nil {
+		return nil, errors.New("malformed row")
+	}
+
+	field := func(name string) string {
+		return strings.TrimSpace(record[strings.ToLower(mapping[name])])
+	}
+
+	book := &models.Book{
+		Title:    record[strings.ToLower(mapping["title"])],
+		Author:   record[strings.ToLower(mapping["author"])],
+		Edition:  field("edition"),
+		Format:   strings.ToLower(field("format")),
+		Language: field("language"),
+	}
+
+	if publisher := field("publisher"); publisher != "" {
+		book.Publisher = &models.Publisher{Name: publisher}
+	}
+
+	if pageCount := field("page_count"); pageCount != "" {
+		value, err := strconv.Atoi(pageCount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page_count %q", pageCount)
+		}
+		book.PageCount = value
+	}
+
+	if publishedOn := field("published_on"); publishedOn != "" {
+		date, err := models.ParseDate(publishedOn)
+		if err != nil {
+			return nil, err
+		}
+		book.PublishedOn = &date
+	}
+
+	if isbn := field("isbn"); isbn != "" {
+		book.ISBN = &isbn
+	}
+
+	if year := field("year"); year != "" {
+		value, err := strconv.Atoi(year)
+		if err != nil {
+			return nil, fmt.Errorf("invalid year %q", year)
+		}
+		book.Year = value
+	}
+	return book, nil
+}
+
+func (handler *BookHandler) importBooks(c *fiber.Ctx) error {
+	dryRun := c.QueryBool("dry_run")
+
+	mapping, err := columnMapping(c.Query("map"))
+	if err != nil {
+		return err
+	}
+
+	records, err := decodeImportBody(c.Get(fiber.HeaderContentType), c.Body())
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+	}
+
+	results := make([]importRowResult, len(records))
+	summary := importSummary{DryRun: dryRun}
+	var books []*models.Book
+	var positions []int
+
+	for i, record := range records {
+		results[i].Row = i + 1
+
+		book, err := bookFromRecord(record, mapping)
+		if err == nil {
+			err = handler.validate.Struct(book)
+		}
+		if err != nil {
+			results[i].Status, results[i].Error = importRejected, importError(err)
+			summary.Rejected++
+			continue
+		}
+
+		books = append(books, book)
+		positions = append(positions, i)
+	}
+
+	if len(books) > 0 {
+		outcomes, err := handler.books(c).ImportBooks(books, dryRun)
+		if err != nil {
+			return err
+		}
+
+		for j, outcome := range outcomes {
+			result := &results[positions[j]]
+			result.Status, result.Data = outcome.Status, outcome.Book
+			switch outcome.Status {
+			case services.ImportCreated:
+				summary.Created++
+			case services.ImportDuplicate:
+				summary.Duplicates++
+			case services.ImportRejected:
+				result.Error, result.Data = importError(outcome.Err), nil
+				summary.Rejected++
+			}
+		}
+	}
+
+	status := fiber.StatusCreated
+	if dryRun || summary.Created == 0 {
+		status = fiber.StatusOK
+	}
+
+	return c.Status(status).JSON(apiResponse{
+		Message: "success",
+		Data:    results,
+		Meta:    summary,
+	})
+}
+
+// importError describes why a row was rejected, naming the fields that
+// failed validation.
+func importError(err error) string {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err.Error()
+	}
+
+	var fields []string
+	for _, fieldError := range validationErrors {
+		fields = append(fields, fmt.Sprintf("%s failed on %s", strings.ToLower(fieldError.Field()), fieldError.Tag()))
+	}
+	return "invalid " + strings.Join(fields, ", ")
+}
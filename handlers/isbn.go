@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+)
+
+// ValidateISBN is the isbn validation tag. It replaces the built in one so
+// that ISBN-10 and ISBN-13 check digits are verified the same way the books
+// are stored, with hyphens and spaces allowed.
+func ValidateISBN(fl validator.FieldLevel) bool {
+	_, ok := models.NormalizeISBN(fl.Field().String())
+	return ok
+}
+
+func (handler *BookHandler) getBookByISBN(c *fiber.Ctx) error {
+	book, err := handler.books(c).GetBookByISBN(c.Params("isbn"))
+	if err != nil {
+		return err
+	}
+
+	return sendTagged(c, book.Version, apiResponse{
+		Message: "success",
+		Data:    book,
+	})
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksReloadInterval limits how often the keys are reloaded when a token is
+// signed with a key they don't have, as happens after the issuer rotates keys.
+const jwksReloadInterval = time.Minute
+
+// JWKS holds the public keys tokens are signed with, loaded from a JSON Web
+// Key Set in a file or served at a URL. Only RSA keys and EC keys on the P-256
+// curve are kept.
+type JWKS struct {
+	source string
+	client *http.Client
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// LoadJWKS loads the key set at source, an http(s) URL or a file path.
+func LoadJWKS(source string) (*JWKS, error) {
+	set := &JWKS{
+		source: source,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := set.load(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// jwk is a single key of a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (set *JWKS) load() error {
+	data, err := set.read()
+	if err != nil {
+		return fmt.Errorf("error while reading the key set %s : %w", set.source, err)
+	}
+
+	var document struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("error while parsing the key set %s : %w", set.source, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(document.Keys))
+	for _, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return fmt.Errorf("error while parsing key %q of the key set %s : %w", key.Kid, set.source, err)
+		}
+		if publicKey != nil {
+			keys[key.Kid] = publicKey
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("the key set %s has no RSA or P-256 signing keys", set.source)
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.keys = keys
+	set.loadedAt = time.Now()
+	return nil
+}
+
+func (set *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(set.source, "http://") && !strings.HasPrefix(set.source, "https://") {
+		return os.ReadFile(set.source)
+	}
+
+	res, err := set.client.Get(set.source)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// key returns the key with the given id, reloading the set at most once per
+// jwksReloadInterval when it isn't known. Tokens naming no key can use the
+// only key of a set holding one.
+func (set *JWKS) key(kid string) (crypto.PublicKey, error) {
+	set.mu.Lock()
+	key, ok := set.lookup(kid)
+	reload := !ok && time.Since(set.loadedAt) >= jwksReloadInterval
+	if reload {
+		set.loadedAt = time.Now()
+	}
+	set.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	if reload {
+		if err := set.load(); err != nil {
+			return nil, err
+		}
+		set.mu.RLock()
+		key, ok = set.lookup(kid)
+		set.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (set *JWKS) lookup(kid string) (crypto.PublicKey, bool) {
+	if key, ok := set.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(set.keys) == 1 {
+		for _, key := range set.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// publicKey returns the key k describes, or nil for key types other than RSA
+// and EC keys on curves other than P-256.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeKeyParam(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeKeyParam(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys need at least 2048 bits")
+		}
+		return key, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeKeyParam(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeKeyParam(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 point")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, errors.New("invalid P-256 point")
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, nil
+}
+
+func decodeKeyParam(param string) ([]byte, error) {
+	value, err := base64.RawURLEncoding.DecodeString(param)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key parameter : %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const jwtClaimsLocalKey = "jwt_claims"
+
+// jwtLeeway is the clock skew allowed when checking when tokens expire or
+// become valid.
+const jwtLeeway = 30 * time.Second
+
+// JWTConfig configures JWTAuth.
+type JWTConfig struct {
+	Keys     *JWKS
+	Issuer   string
+	Audience string
+	// RolesClaim names the claim holding the caller's roles, as a string or a
+	// list of strings. Nested claims are named by their path, eg:
+	// realm_access.roles. Defaults to roles.
+	RolesClaim string
+	// Roles maps the values of the roles claim to roles. Values naming a role
+	// map to that role.
+	Roles map[string]string
+}
+
+// JWTAuth authenticates requests carrying a JWT as a bearer token in the
+// Authorization header. Tokens must be signed with RS256 or ES256 by one of
+// the configured keys, be issued by the configured issuer for the configured
+// audience, and not be expired. The caller gets the widest role its roles
+// claim maps to. Other requests are left to the next middleware, while
+// requests with an invalid token are rejected. Each request is logged along
+// with the subject of its token.
+func JWTAuth(config JWTConfig, logger *slog.Logger) fiber.Handler {
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+
+	return func(c *fiber.Ctx) error {
+		token, ok := requestJWT(c)
+		if !ok || isDeploymentAdmin(c) {
+			return c.Next()
+		}
+
+		claims, err := config.verify(token, time.Now())
+		if err != nil {
+			logger.Warn("invalid token", "error", err)
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return &problemError{status: fiber.StatusUnauthorized, code: "invalid_token", detail: err.Error()}
+		}
+
+		subject, _ := claims["sub"].(string)
+		role := config.role(claims)
+		setRole(c, role)
+		c.Locals(jwtClaimsLocalKey, claims)
+
+		err = c.Next()
+		logger.Info("request", "method", c.Method(), "path", c.Path(), "status", responseStatus(c, err), "subject", subject, "role", role)
+		return err
+	}
+}
+
+// requestJWT returns the bearer token sent with the request when it looks
+// like a JWT, API keys having no dots.
+func requestJWT(c *fiber.Ctx) (string, bool) {
+	scheme, token, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, strings.Count(token, ".") == 2
+}
+
+// verify checks the signature and the registered claims of token, returning
+// its claims.
+func (config JWTConfig) verify(token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+
+	key, err := config.Keys.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, digest[:], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != config.Issuer {
+		return nil, fmt.Errorf("unexpected token issuer %q", issuer)
+	}
+	if !slices.Contains(stringsClaim(claims["aud"]), config.Audience) {
+		return nil, errors.New("the token isn't meant for this audience")
+	}
+	expiresAt, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("the token has no expiry")
+	}
+	if now.Add(-jwtLeeway).After(time.Unix(int64(expiresAt), 0)) {
+		return nil, errors.New("the token has expired")
+	}
+	if notBefore, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(notBefore), 0)) {
+		return nil, errors.New("the token isn't valid yet")
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks signature against digest with key, which must suit
+// alg.
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) error {
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("the signing key isn't an RSA key")
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest, signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("the signing key isn't an EC key")
+		}
+		if len(signature) != 64 {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported token algorithm %q", alg)
+}
+
+// role returns the widest role the roles claim maps to, or an empty role.
+func (config JWTConfig) role(claims map[string]any) string {
+	var value any = claims
+	for _, name := range strings.Split(config.RolesClaim, ".") {
+		object, _ := value.(map[string]any)
+		value = object[name]
+	}
+
+	role := ""
+	for _, claimed := range stringsClaim(value) {
+		if mapped, ok := config.Roles[claimed]; ok {
+			claimed = mapped
+		}
+		if roleRanks[claimed] > roleRanks[role] {
+			role = claimed
+		}
+	}
+	return role
+}
+
+// stringsClaim returns the strings held by a claim that is either a string or
+// a list of strings.
+func stringsClaim(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if item, ok := item.(string); ok {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return nil
+}
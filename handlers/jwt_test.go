@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testIssuer   = "https://gateway.example"
+	testAudience = "booksapi"
+)
+
+// testSigner signs tokens with a locally generated RSA and P-256 keypair,
+// published in a key set under the ids rsa and ec.
+type testSigner struct {
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	return &testSigner{rsaKey: rsaKey, ecKey: ecKey}
+}
+
+// jwks returns the public keys as a JSON Web Key Set.
+func (s *testSigner) jwks() []byte {
+	encode := base64.RawURLEncoding.EncodeToString
+	set := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa", "use": "sig",
+			"n": encode(s.rsaKey.N.Bytes()),
+			"e": encode(big.NewInt(int64(s.rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": encode(s.ecKey.X.FillBytes(make([]byte, 32))),
+			"y": encode(s.ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+	}}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+// sign returns a token with claims, signed with the key kid using alg.
+func (s *testSigner) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, s.rsaKey, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case "ES256":
+		r, sig, err := ecdsa.Sign(rand.Reader, s.ecKey, digest[:])
+		assert.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), sig.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testClaims returns valid claims granting roles.
+func testClaims(roles ...string) map[string]any {
+	return map[string]any{
+		"iss":   testIssuer,
+		"aud":   []string{"other", testAudience},
+		"sub":   "user-42",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": roles,
+	}
+}
+
+// setupJWTTestApp serves the book and API key routes behind the JWT and the
+// API key middleware, trusting the keys of signer.
+func setupJWTTestApp(t *testing.T, signer *testSigner, logs *bytes.Buffer) *fiber.App {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, signer.jwks(), 0o600))
+	keys, err := LoadJWKS(path)
+	assert.NoError(t, err)
+
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+	validator.RegisterValidation("isbn", ValidateISBN)
+
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+	config := JWTConfig{
+		Keys:     keys,
+		Issuer:   testIssuer,
+		Audience: testAudience,
+		Roles:    map[string]string{"staff": RoleLibrarian},
+	}
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(AdminAuth(testAdminToken))
+	app.Use(JWTAuth(config, logger))
+	app.Use(APIKeyAuth(NewMockedAPIKeyService(), logger))
+
+	NewAPIKeyHandler(NewMockedAPIKeyService(), validator).SetupRoutes(app)
+	NewBookHandler(NewMockedBookService(), validator, logger).SetupRoutes(app)
+	return app
+}
+
+func TestJWTAuth(t *testing.T) {
+	signer := newTestSigner(t)
+
+	send := func(app *fiber.App, method, path, token string) (*http.Response, problem) {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		return res, p
+	}
+
+	t.Run("RS256 and ES256 tokens are accepted", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		app := setupJWTTestApp(t, signer, logs)
+
+		res, _ := send(app, "GET", "/books", signer.sign(t, "RS256", "rsa", testClaims(RoleReader)))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, logs.String(), "subject=user-42 role=reader")
+
+		res, _ = send(app, "GET", "/books", signer.sign(t, "ES256", "ec", testClaims(RoleReader)))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("invalid tokens are rejected", func(t *testing.T) {
+		app := setupJWTTestApp(t, signer, &bytes.Buffer{})
+
+		wrongIssuer := testClaims(RoleReader)
+		wrongIssuer["iss"] = "https://elsewhere.example"
+		wrongAudience := testClaims(RoleReader)
+		wrongAudience["aud"] = "other"
+		expired := testClaims(RoleReader)
+		expired["exp"] = time.Now().Add(-time.Hour).Unix()
+		notYetValid := testClaims(RoleReader)
+		notYetValid["nbf"] = time.Now().Add(time.Hour).Unix()
+		noExpiry := testClaims(RoleReader)
+		delete(noExpiry, "exp")
+
+		valid := signer.sign(t, "RS256", "rsa", testClaims(RoleReader))
+		header, payload, _ := strings.Cut(valid, ".")
+		payload, _, _ = strings.Cut(payload, ".")
+		tampered := signer.sign(t, "RS256", "rsa", testClaims(RoleAdmin))
+		tampered = header + "." + strings.Split(tampered, ".")[1] + "." + strings.Split(valid, ".")[2]
+
+		tokens := map[string]string{
+			"wrong issuer":     signer.sign(t, "RS256", "rsa", wrongIssuer),
+			"wrong audience":   signer.sign(t, "RS256", "rsa", wrongAudience),
+			"expired":          signer.sign(t, "RS256", "rsa", expired),
+			"not yet valid":    signer.sign(t, "ES256", "ec", notYetValid),
+			"no expiry":        signer.sign(t, "RS256", "rsa", noExpiry),
+			"tampered":         tampered,
+			"unsigned":         header + "." + payload + ".",
+			"alg none":         base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + payload + ".",
+			"key mismatch":     signer.sign(t, "ES256", "rsa", testClaims(RoleReader)),
+			"unknown key":      signer.sign(t, "RS256", "other", testClaims(RoleReader)),
+			"other signer":     newTestSigner(t).sign(t, "RS256", "rsa", testClaims(RoleReader)),
+			"malformed header": "e30K.e30K.e30K",
+		}
+		for name, token := range tokens {
+			res, p := send(app, "GET", "/books", token)
+			assert.Equal(t, http.StatusUnauthorized, res.StatusCode, name)
+			assert.Equal(t, "invalid_token", p.Code, name)
+			assert.Equal(t, `Bearer error="invalid_token"`, res.Header.Get("WWW-Authenticate"), name)
+		}
+	})
+
+	t.Run("routes require roles", func(t *testing.T) {
+		app := setupJWTTestApp(t, signer, &bytes.Buffer{})
+		reader := signer.sign(t, "RS256", "rsa", testClaims(RoleReader))
+		staff := signer.sign(t, "ES256", "ec", testClaims(RoleReader, "staff"))
+		admin := signer.sign(t, "RS256", "rsa", testClaims(RoleAdmin))
+		nobody := signer.sign(t, "RS256", "rsa", testClaims("unknown"))
+
+		res, p := send(app, "DELETE", "/books/1", reader)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Equal(t, "insufficient_role", p.Code)
+
+		res, _ = send(app, "GET", "/books/trash", reader)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", nobody)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/books/1", staff)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/books/1?purge=true", staff)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "/api-keys", staff)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/books/2?purge=true", admin)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/api-keys", admin)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("routes deny callers without a role", func(t *testing.T) {
+		validator := validator.New(validator.WithRequiredStructEnabled())
+		app := fiber.New(fiber.Config{
+			ErrorHandler: ErrorHandler,
+		})
+		app.Use(AdminAuth(testAdminToken))
+		NewBookHandler(NewMockedBookService(), validator, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))).SetupRoutes(app)
+
+		res, p := send(app, "GET", "/books", "")
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, "unauthenticated", p.Code)
+		assert.Equal(t, "Bearer", res.Header.Get("WWW-Authenticate"))
+
+		req := httptest.NewRequest("GET", "/books", nil)
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("API keys still work", func(t *testing.T) {
+		app := setupJWTTestApp(t, signer, &bytes.Buffer{})
+		res, _ := send(app, "GET", "/books", "bk_reader")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, p := send(app, "GET", "/books/trash", "bk_reader")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Equal(t, "insufficient_role", p.Code)
+
+		res, _ = send(app, "GET", "/books/trash", "bk_writer")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("roles can come from a nested claim", func(t *testing.T) {
+		config := JWTConfig{RolesClaim: "realm_access.roles"}
+		claims := map[string]any{"realm_access": map[string]any{"roles": []any{RoleReader, RoleLibrarian}}}
+		assert.Equal(t, RoleLibrarian, config.role(claims))
+		assert.Equal(t, "", config.role(map[string]any{"realm_access": "admin"}))
+	})
+}
+
+func TestLoadJWKS(t *testing.T) {
+	signer := newTestSigner(t)
+
+	t.Run("from a URL", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(signer.jwks())
+		}))
+		defer server.Close()
+
+		keys, err := LoadJWKS(server.URL)
+		assert.NoError(t, err)
+		config := JWTConfig{Keys: keys, Issuer: testIssuer, Audience: testAudience}
+		_, err = config.verify(signer.sign(t, "ES256", "ec", testClaims()), time.Now())
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid sets", func(t *testing.T) {
+		dir := t.TempDir()
+		sets := map[string]string{
+			"empty":   `{"keys": []}`,
+			"invalid": `{"keys": [{"kty": "RSA", "kid": "rsa", "n": "AQAB", "e": "AQAB"}]}`,
+			"other":   `{"keys": [{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}]}`,
+			"garbage": `keys`,
+		}
+		for name, set := range sets {
+			path := filepath.Join(dir, name+".json")
+			assert.NoError(t, os.WriteFile(path, []byte(set), 0o600))
+			_, err := LoadJWKS(path)
+			assert.Error(t, err, name)
+		}
+
+		_, err := LoadJWKS(filepath.Join(dir, "missing.json"))
+		assert.Error(t, err)
+	})
+}
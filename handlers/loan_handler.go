@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type LoanHandler struct {
+	loanService services.ILoanService
+	validate    *validator.Validate
+}
+
+func NewLoanHandler(service services.ILoanService, validator *validator.Validate) *LoanHandler {
+	return &LoanHandler{
+		loanService: service,
+		validate:    validator,
+	}
+}
+
+// loans returns the loan service of the tenant the request is made for.
+func (handler *LoanHandler) loans(c *fiber.Ctx) services.ILoanService {
+	return handler.loanService.ForTenant(requestTenant(c))
+}
+
+func (handler *LoanHandler) SetupRoutes(router fiber.Router) {
+	librarian := requireRole(RoleLibrarian)
+	router.Post("/loans", librarian, handler.checkout)
+	router.Get("/loans/:id", librarian, handler.getLoan)
+	router.Post("/loans/:id/return", librarian, handler.returnLoan)
+	router.Post("/loans/:id/renew", librarian, handler.renewLoan)
+	router.Get("/patrons/:id/loans", librarian, handler.getPatronLoans)
+}
+
+func (handler *LoanHandler) checkout(c *fiber.Ctx) error {
+	var request services.CheckoutRequest
+	if err := parseBody(c, &request); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&request); err != nil {
+		return validationProblem(err)
+	}
+
+	loan, err := handler.loans(c).Checkout(request)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    loan,
+	})
+}
+
+func (handler *LoanHandler) getLoan(c *fiber.Ctx) error {
+	loanId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	loan, err := handler.loans(c).GetLoan(uint(loanId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    loan,
+	})
+}
+
+func (handler *LoanHandler) returnLoan(c *fiber.Ctx) error {
+	loanId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	loan, err := handler.loans(c).ReturnLoan(uint(loanId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    loan,
+	})
+}
+
+func (handler *LoanHandler) renewLoan(c *fiber.Ctx) error {
+	loanId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	loan, err := handler.loans(c).RenewLoan(uint(loanId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    loan,
+	})
+}
+
+func (handler *LoanHandler) getPatronLoans(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var filter services.LoanFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid query parameter")
+	}
+
+	if err := handler.validate.Struct(&filter); err != nil {
+		return validationProblem(err)
+	}
+
+	page, limit := paginationParams(c)
+
+	loans, total, err := handler.loans(c).GetPatronLoans(uint(patronId), filter, page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    loans,
+		Meta:    meta,
+	})
+}
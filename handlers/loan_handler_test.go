@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLoanTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewLoanHandler(NewMockedLoanService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestLoanHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("checkout", func(t *testing.T) {
+		app := setupLoanTestApp(t)
+		res, response := send(app, "POST", "/loans", `{"patron_id": 1, "copy_id": 2}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, float64(2), response.Data.(map[string]any)["copy_id"])
+
+		res, _ = send(app, "POST", "/loans", `{"patron_id": 2, "copy_id": 2}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "POST", "/loans", `{"patron_id": 99, "copy_id": 3}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		req := httptest.NewRequest("POST", "/loans", strings.NewReader(`{"patron_id": 1}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, []fieldError{{Pointer: "/copy_id", Rule: "required_without", Message: "copy_id is required"}}, p.Errors)
+	})
+
+	t.Run("return and renew", func(t *testing.T) {
+		app := setupLoanTestApp(t)
+		res, response := send(app, "POST", "/loans/1/renew", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, float64(1), response.Data.(map[string]any)["renewals"])
+
+		res, _ = send(app, "POST", "/loans/1/renew", "")
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res, response = send(app, "POST", "/loans/1/return", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotNil(t, response.Data.(map[string]any)["returned_at"])
+
+		res, _ = send(app, "POST", "/loans/1/return", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "GET", "/loans/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("patron loans", func(t *testing.T) {
+		app := setupLoanTestApp(t)
+		res, response := send(app, "GET", "/patrons/1/loans?status=active", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+
+		res, _ = send(app, "GET", "/patrons/1/loans?status=lost", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "GET", "/patrons/99/loans", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+// mockedLoanService knows patrons 1 and 2, and has copy 1 lent to patron 1
+// with a renewal limit of one.
+type mockedLoanService struct {
+	loans  []*models.Loan
+	tenant string
+}
+
+var _ services.ILoanService = (*mockedLoanService)(nil)
+
+func NewMockedLoanService() *mockedLoanService {
+	now := time.Now()
+	loan := &models.Loan{CopyID: 1, PatronID: 1, CheckedOutAt: now, DueAt: now.Add(21 * 24 * time.Hour)}
+	loan.ID = 1
+	return &mockedLoanService{loans: []*models.Loan{loan}}
+}
+
+func (m *mockedLoanService) ForTenant(tenant *models.Tenant) services.ILoanService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedLoanService) Checkout(request services.CheckoutRequest) (*models.Loan, error) {
+	if request.PatronID != 1 && request.PatronID != 2 {
+		return nil, services.ErrUnknownPatron
+	}
+	for _, loan := range m.loans {
+		if loan.CopyID == request.CopyID && loan.ReturnedAt == nil {
+			return nil, services.ErrCopyUnavailable
+		}
+	}
+	now := time.Now()
+	loan := &models.Loan{CopyID: request.CopyID, PatronID: request.PatronID, CheckedOutAt: now, DueAt: now.Add(21 * 24 * time.Hour)}
+	loan.ID = uint(len(m.loans) + 1)
+	m.loans = append(m.loans, loan)
+	return loan, nil
+}
+
+func (m *mockedLoanService) GetLoan(id uint) (*models.Loan, error) {
+	for _, loan := range m.loans {
+		if loan.ID == id {
+			return loan, nil
+		}
+	}
+	return nil, services.ErrLoanNotFound
+}
+
+func (m *mockedLoanService) ReturnLoan(id uint) (*models.Loan, error) {
+	loan, err := m.GetLoan(id)
+	if err != nil {
+		return nil, err
+	}
+	if loan.ReturnedAt != nil {
+		return nil, services.ErrLoanReturned
+	}
+	now := time.Now()
+	loan.ReturnedAt = &now
+	return loan, nil
+}
+
+func (m *mockedLoanService) RenewLoan(id uint) (*models.Loan, error) {
+	loan, err := m.GetLoan(id)
+	if err != nil {
+		return nil, err
+	}
+	if loan.ReturnedAt != nil {
+		return nil, services.ErrLoanReturned
+	}
+	if loan.Renewals >= 1 {
+		return nil, services.ErrRenewalLimit
+	}
+	loan.Renewals++
+	return loan, nil
+}
+
+func (m *mockedLoanService) GetPatronLoans(patronID uint, filter services.LoanFilter, page, limit int) ([]*models.Loan, int64, error) {
+	if patronID != 1 && patronID != 2 {
+		return nil, 0, services.ErrPatronNotFound
+	}
+	var loans []*models.Loan
+	for _, loan := range m.loans {
+		if loan.PatronID == patronID {
+			loans = append(loans, loan)
+		}
+	}
+	return loans, int64(len(loans)), nil
+}
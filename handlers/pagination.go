@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type pageMeta struct {
+	Total      int64 `json:"total"`
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	TotalPages int   `json:"total_pages"`
+}
+
+type cursorMeta struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// seriesMeta holds the ids of the books before and after a book in the reading
+// order of its series.
+type seriesMeta struct {
+	Previous *uint `json:"previous,omitempty"`
+	Next     *uint `json:"next,omitempty"`
+}
+
+func newPageMeta(total int64, page, limit int) *pageMeta {
+	return &pageMeta{
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: int((total + int64(limit) - 1) / int64(limit)),
+	}
+}
+
+// paginationLinks builds an RFC 8288 Link header value pointing at the first,
+// previous, next and last pages. The original query string is kept so
+// filters and sort order carry over to the linked pages.
+func paginationLinks(c *fiber.Ctx, meta *pageMeta) string {
+	lastPage := max(meta.TotalPages, 1)
+
+	links := []string{pageLink(c, 1, meta.Limit, "first")}
+	if meta.Page > 1 {
+		links = append(links, pageLink(c, min(meta.Page-1, lastPage), meta.Limit, "prev"))
+	}
+	if meta.Page < lastPage {
+		links = append(links, pageLink(c, meta.Page+1, meta.Limit, "next"))
+	}
+	links = append(links, pageLink(c, lastPage, meta.Limit, "last"))
+
+	return strings.Join(links, ", ")
+}
+
+func pageLink(c *fiber.Ctx, page, limit int, rel string) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	c.Request().URI().QueryArgs().CopyTo(args)
+	args.Set("page", strconv.Itoa(page))
+	args.Set("limit", strconv.Itoa(limit))
+
+	return fmt.Sprintf(`<%s%s?%s>; rel="%s"`, c.BaseURL(), c.Path(), args.String(), rel)
+}
+
+func cursorLink(c *fiber.Ctx, next string) string {
+	args := fiber.AcquireArgs()
+	defer fiber.ReleaseArgs(args)
+
+	c.Request().URI().QueryArgs().CopyTo(args)
+	args.Del("page")
+	args.Set("cursor", next)
+
+	return fmt.Sprintf(`<%s%s?%s>; rel="next"`, c.BaseURL(), c.Path(), args.String())
+}
+
+// bookLink builds an RFC 8288 link to the book with the given id, relative to
+// the book being requested.
+func bookLink(c *fiber.Ctx, id uint, rel string) string {
+	path := strings.TrimSuffix(c.Path(), c.Params("id")) + strconv.FormatUint(uint64(id), 10)
+	return fmt.Sprintf(`<%s%s>; rel="%s"`, c.BaseURL(), path, rel)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"slices"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+)
+
+const (
+	mimeMergePatch = "application/merge-patch+json"
+	mimeJSONPatch  = "application/json-patch+json"
+)
+
+var acceptedPatchTypes = strings.Join([]string{mimeMergePatch, mimeJSONPatch}, ", ")
+
+// patchFunc applies a patch document to a JSON document.
+type patchFunc func(doc []byte) ([]byte, error)
+
+// decodePatch parses body as a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902) depending on contentType.
+func decodePatch(contentType string, body []byte) (patchFunc, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case mimeMergePatch:
+		if !json.Valid(body) {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid patch document")
+		}
+		return func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, body)
+		}, nil
+
+	case mimeJSONPatch:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "invalid patch document")
+		}
+		return patch.Apply, nil
+	}
+
+	return nil, fiber.NewError(fiber.StatusUnsupportedMediaType, "unsupported patch Content-Type")
+}
+
+// sameCredits reports whether a and b credit the same authors, in the same
+// roles and order.
+func sameCredits(a, b []models.BookAuthor) bool {
+	return slices.EqualFunc(a, b, func(x, y models.BookAuthor) bool {
+		return x.AuthorID == y.AuthorID && x.Role == y.Role && x.Position == y.Position
+	})
+}
+
+// patchedPublisher returns the publisher a patch asks for. Merge patching the
+// name of the embedded publisher keeps its id, so a changed name under the same
+// id asks for the publisher with that name.
+func patchedPublisher(original, patched *models.Publisher) *models.Publisher {
+	if original != nil && patched != nil && patched.ID == original.ID && !strings.EqualFold(patched.Name, original.Name) {
+		return &models.Publisher{Name: patched.Name}
+	}
+	return patched
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type PatronHandler struct {
+	patronService services.IPatronService
+	validate      *validator.Validate
+}
+
+func NewPatronHandler(service services.IPatronService, validator *validator.Validate) *PatronHandler {
+	return &PatronHandler{
+		patronService: service,
+		validate:      validator,
+	}
+}
+
+// patrons returns the patron service of the tenant the request is made for.
+func (handler *PatronHandler) patrons(c *fiber.Ctx) services.IPatronService {
+	return handler.patronService.ForTenant(requestTenant(c))
+}
+
+func (handler *PatronHandler) SetupRoutes(router fiber.Router) {
+	librarian := requireRole(RoleLibrarian)
+	router.Get("/patrons", librarian, handler.getAllPatrons)
+	router.Get("/patrons/:id", librarian, handler.getPatron)
+	router.Post("/patrons", librarian, handler.newPatron)
+	router.Put("/patrons/:id", librarian, handler.updatePatron)
+	router.Delete("/patrons/:id", librarian, handler.deletePatron)
+}
+
+func (handler *PatronHandler) getAllPatrons(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	patrons, total, err := handler.patrons(c).GetAllPatrons(strings.TrimSpace(c.Query("search")), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    patrons,
+		Meta:    meta,
+	})
+}
+
+func (handler *PatronHandler) getPatron(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	patron, err := handler.patrons(c).GetPatron(uint(patronId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    patron,
+	})
+}
+
+func (handler *PatronHandler) newPatron(c *fiber.Ctx) error {
+	var patron models.Patron
+	if err := parseBody(c, &patron); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&patron); err != nil {
+		return validationProblem(err)
+	}
+
+	createdPatron, err := handler.patrons(c).CreatePatron(&patron)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdPatron,
+	})
+}
+
+func (handler *PatronHandler) updatePatron(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var patron models.Patron
+	if err := parseBody(c, &patron); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&patron); err != nil {
+		return validationProblem(err)
+	}
+
+	patron.ID = uint(patronId)
+	updatedPatron, err := handler.patrons(c).UpdatePatron(&patron)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedPatron,
+	})
+}
+
+func (handler *PatronHandler) deletePatron(c *fiber.Ctx) error {
+	patronId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	patron, err := handler.patrons(c).DeletePatron(uint(patronId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    patron,
+	})
+}
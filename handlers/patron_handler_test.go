@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupPatronTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewPatronHandler(NewMockedPatronService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestPatronHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("list patrons", func(t *testing.T) {
+		app := setupPatronTestApp(t)
+		res, response := send(app, "GET", "/patrons?search=ada", "")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, float64(1), response.Meta.(map[string]any)["total"])
+	})
+
+	t.Run("create patron", func(t *testing.T) {
+		app := setupPatronTestApp(t)
+		res, response := send(app, "POST", "/patrons", `{"name": "Cy", "email": "cy@example.com"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "cy@example.com", response.Data.(map[string]any)["email"])
+
+		req := httptest.NewRequest("POST", "/patrons", strings.NewReader(`{"name": "Cy", "email": "cy"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, []fieldError{{Pointer: "/email", Rule: "email", Message: "email must be an email address"}}, p.Errors)
+
+		res, _ = send(app, "POST", "/patrons", `{"name": "Ada", "email": "ADA@example.com"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("update patron", func(t *testing.T) {
+		app := setupPatronTestApp(t)
+		res, response := send(app, "PUT", "/patrons/2", `{"name": "Robert", "email": "bob@example.com"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Robert", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "PUT", "/patrons/99", `{"name": "Nobody", "email": "nobody@example.com"}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("delete patron", func(t *testing.T) {
+		app := setupPatronTestApp(t)
+		res, _ := send(app, "DELETE", "/patrons/1", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/patrons/2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/patrons/2", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+type mockedPatronService struct {
+	patrons []*models.Patron
+	tenant  string
+}
+
+var _ services.IPatronService = (*mockedPatronService)(nil)
+
+func NewMockedPatronService() *mockedPatronService {
+	patrons := []*models.Patron{
+		{Name: "Ada", Email: "ada@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+	}
+	for i, patron := range patrons {
+		patron.ID = uint(i + 1)
+	}
+
+	return &mockedPatronService{patrons: patrons}
+}
+
+func (m *mockedPatronService) ForTenant(tenant *models.Tenant) services.IPatronService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedPatronService) GetAllPatrons(search string, page, limit int) ([]*models.Patron, int64, error) {
+	matches := slices.DeleteFunc(slices.Clone(m.patrons), func(p *models.Patron) bool {
+		return !strings.Contains(strings.ToLower(p.Name+" "+p.Email), strings.ToLower(search))
+	})
+	start := min((page-1)*limit, len(matches))
+	end := min(start+limit, len(matches))
+	return matches[start:end], int64(len(matches)), nil
+}
+
+func (m *mockedPatronService) GetPatron(id uint) (*models.Patron, error) {
+	for _, patron := range m.patrons {
+		if patron.ID == id {
+			return patron, nil
+		}
+	}
+	return nil, services.ErrPatronNotFound
+}
+
+func (m *mockedPatronService) CreatePatron(patron *models.Patron) (*models.Patron, error) {
+	for _, existing := range m.patrons {
+		if strings.EqualFold(existing.Email, patron.Email) {
+			return nil, services.ErrDuplicatePatron
+		}
+	}
+	patron.ID = uint(len(m.patrons) + 1)
+	m.patrons = append(m.patrons, patron)
+	return patron, nil
+}
+
+func (m *mockedPatronService) UpdatePatron(payload *models.Patron) (*models.Patron, error) {
+	patron, err := m.GetPatron(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	patron.Name = payload.Name
+	patron.Email = payload.Email
+	return patron, nil
+}
+
+// DeletePatron treats the first patron as having a copy on loan.
+func (m *mockedPatronService) DeletePatron(id uint) (*models.Patron, error) {
+	patron, err := m.GetPatron(id)
+	if err != nil {
+		return nil, err
+	}
+	if id == 1 {
+		return nil, services.ErrPatronInUse
+	}
+	m.patrons = slices.DeleteFunc(m.patrons, func(p *models.Patron) bool { return p.ID == id })
+	return patron, nil
+}
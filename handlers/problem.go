@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+const mimeProblemJSON = "application/problem+json"
+
+// problem is an RFC 7807 problem details object. Message and Error repeat
+// the previous error response format for existing clients.
+type problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []fieldError `json:"errors,omitempty"`
+
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// fieldError points at a single invalid field of the request.
+type fieldError struct {
+	Pointer string `json:"pointer"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// problemError is an error reported with a specific problem code, along with
+// the fields that caused it.
+type problemError struct {
+	status int
+	code   string
+	detail string
+	errors []fieldError
+}
+
+func (e *problemError) Error() string {
+	return e.detail
+}
+
+// domainErrors maps the errors returned by the services to their status code
+// and stable problem code.
+var domainErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{services.ErrNotFound, fiber.StatusNotFound, "book_not_found"},
+	{services.ErrNotInTrash, fiber.StatusNotFound, "book_not_in_trash"},
+	{services.ErrVersionMismatch, fiber.StatusPreconditionFailed, "version_mismatch"},
+	{services.ErrHasLoans, fiber.StatusConflict, "book_has_loans"},
+	{services.ErrInvalidQuery, fiber.StatusBadRequest, "invalid_search_query"},
+	{services.ErrSearchUnavailable, fiber.StatusServiceUnavailable, "search_unavailable"},
+	{services.ErrInvalidSort, fiber.StatusBadRequest, "invalid_sort"},
+	{services.ErrInvalidCursor, fiber.StatusBadRequest, "invalid_cursor"},
+	{services.ErrBulkAborted, fiber.StatusUnprocessableEntity, "bulk_aborted"},
+	{services.ErrInvalidISBN, fiber.StatusBadRequest, "invalid_isbn"},
+	{services.ErrDuplicateISBN, fiber.StatusConflict, "duplicate_isbn"},
+	{services.ErrISBNRequired, fiber.StatusUnprocessableEntity, "isbn_required"},
+	{services.ErrAuthorNotFound, fiber.StatusNotFound, "author_not_found"},
+	{services.ErrAuthorInUse, fiber.StatusConflict, "author_in_use"},
+	{services.ErrDuplicateAuthor, fiber.StatusConflict, "duplicate_author"},
+	{services.ErrUnknownAuthor, fiber.StatusUnprocessableEntity, "unknown_author"},
+	{services.ErrDuplicateCredit, fiber.StatusUnprocessableEntity, "duplicate_credit"},
+	{services.ErrUnknownPublisher, fiber.StatusUnprocessableEntity, "unknown_publisher"},
+	{services.ErrYearMismatch, fiber.StatusUnprocessableEntity, "year_mismatch"},
+	{services.ErrWorkNotFound, fiber.StatusNotFound, "work_not_found"},
+	{services.ErrUnknownWork, fiber.StatusUnprocessableEntity, "unknown_work"},
+	{services.ErrInvalidLanguage, fiber.StatusBadRequest, "invalid_language"},
+	{services.ErrSubjectNotFound, fiber.StatusNotFound, "subject_not_found"},
+	{services.ErrSubjectInUse, fiber.StatusConflict, "subject_in_use"},
+	{services.ErrDuplicateSubject, fiber.StatusConflict, "duplicate_subject"},
+	{services.ErrSubjectCycle, fiber.StatusUnprocessableEntity, "subject_cycle"},
+	{services.ErrUnknownSubject, fiber.StatusUnprocessableEntity, "unknown_subject"},
+	{services.ErrSeriesNotFound, fiber.StatusNotFound, "series_not_found"},
+	{services.ErrSeriesInUse, fiber.StatusConflict, "series_in_use"},
+	{services.ErrDuplicateSeries, fiber.StatusConflict, "duplicate_series"},
+	{services.ErrUnknownSeries, fiber.StatusUnprocessableEntity, "unknown_series"},
+	{services.ErrBranchNotFound, fiber.StatusNotFound, "branch_not_found"},
+	{services.ErrBranchInUse, fiber.StatusConflict, "branch_in_use"},
+	{services.ErrDuplicateBranch, fiber.StatusConflict, "duplicate_branch"},
+	{services.ErrUnknownBranch, fiber.StatusUnprocessableEntity, "unknown_branch"},
+	{services.ErrCopyNotFound, fiber.StatusNotFound, "copy_not_found"},
+	{services.ErrDuplicateBarcode, fiber.StatusConflict, "duplicate_barcode"},
+	{services.ErrUnknownBook, fiber.StatusUnprocessableEntity, "unknown_book"},
+	{services.ErrCopyOnLoan, fiber.StatusConflict, "copy_on_loan"},
+	{services.ErrPatronNotFound, fiber.StatusNotFound, "patron_not_found"},
+	{services.ErrPatronInUse, fiber.StatusConflict, "patron_in_use"},
+	{services.ErrDuplicatePatron, fiber.StatusConflict, "duplicate_patron"},
+	{services.ErrUnknownPatron, fiber.StatusUnprocessableEntity, "unknown_patron"},
+	{services.ErrLoanNotFound, fiber.StatusNotFound, "loan_not_found"},
+	{services.ErrLoanReturned, fiber.StatusConflict, "loan_returned"},
+	{services.ErrRenewalLimit, fiber.StatusUnprocessableEntity, "renewal_limit"},
+	{services.ErrCopyUnavailable, fiber.StatusConflict, "copy_unavailable"},
+	{services.ErrUnknownCopy, fiber.StatusUnprocessableEntity, "unknown_copy"},
+	{services.ErrCopyOnHold, fiber.StatusConflict, "copy_on_hold"},
+	{services.ErrHoldNotFound, fiber.StatusNotFound, "hold_not_found"},
+	{services.ErrDuplicateHold, fiber.StatusConflict, "duplicate_hold"},
+	{services.ErrHoldClosed, fiber.StatusConflict, "hold_closed"},
+	{services.ErrFinePolicyNotFound, fiber.StatusNotFound, "fine_policy_not_found"},
+	{services.ErrDuplicateFinePolicy, fiber.StatusConflict, "duplicate_fine_policy"},
+	{services.ErrClosedDayNotFound, fiber.StatusNotFound, "closed_day_not_found"},
+	{services.ErrDuplicateClosedDay, fiber.StatusConflict, "duplicate_closed_day"},
+	{services.ErrOverpayment, fiber.StatusUnprocessableEntity, "overpayment"},
+	{services.ErrAPIKeyNotFound, fiber.StatusNotFound, "api_key_not_found"},
+	{services.ErrAPIKeyRevoked, fiber.StatusConflict, "api_key_revoked"},
+	{services.ErrInvalidAPIKey, fiber.StatusUnauthorized, "invalid_api_key"},
+	{services.ErrTenantNotFound, fiber.StatusNotFound, "tenant_not_found"},
+	{services.ErrDuplicateTenant, fiber.StatusConflict, "duplicate_tenant"},
+}
+
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	p := newProblem(err)
+	p.Instance = c.OriginalURL()
+
+	return c.Status(p.Status).JSON(p, mimeProblemJSON)
+}
+
+func newProblem(err error) problem {
+	status, code, detail := fiber.StatusInternalServerError, "", err.Error()
+	var fieldErrors []fieldError
+
+	var pe *problemError
+	var fe *fiber.Error
+	switch {
+	case errors.As(err, &pe):
+		status, code, fieldErrors = pe.status, pe.code, pe.errors
+	case errors.As(err, &fe):
+		status, detail = fe.Code, fe.Message
+	default:
+		for _, domainError := range domainErrors {
+			if errors.Is(err, domainError.err) {
+				status, code = domainError.status, domainError.code
+				break
+			}
+		}
+	}
+
+	problemType := "about:blank"
+	if code == "" {
+		code = statusCode(status)
+	} else {
+		problemType = "urn:problem-type:" + code
+	}
+
+	return problem{
+		Type:    problemType,
+		Title:   http.StatusText(status),
+		Status:  status,
+		Detail:  detail,
+		Code:    code,
+		Errors:  fieldErrors,
+		Message: "error",
+		Error:   detail,
+	}
+}
+
+// errorStatus returns the status code an error maps to when it is reported
+// as part of a response instead of through ErrorHandler.
+func errorStatus(err error) int {
+	return newProblem(err).Status
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// statusCode turns a status code into a problem code, eg: "not_found".
+func statusCode(status int) string {
+	text := strings.ToLower(http.StatusText(status))
+	if text == "" {
+		text = "error"
+	}
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(text, "_"), "_")
+}
+
+// FieldName names struct fields after their json tag, or query tag, in
+// validation errors, so they can be reported as they appear in requests.
+func FieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "query"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// validationProblem reports every field that failed validation.
+func validationProblem(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return &problemError{status: fiber.StatusBadRequest, code: "validation_failed", detail: "invalid payload"}
+	}
+
+	fields := make([]fieldError, len(validationErrors))
+	for i, fe := range validationErrors {
+		fields[i] = fieldError{
+			Pointer: jsonPointer(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Message: ruleMessage(fe),
+		}
+	}
+	return &problemError{status: fiber.StatusBadRequest, code: "validation_failed", detail: "invalid payload", errors: fields}
+}
+
+// bodyProblem reports a request body that could not be decoded.
+func bodyProblem(err error) error {
+	field := fieldError{Pointer: "", Rule: "json", Message: "request body is not valid JSON"}
+
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		if typeError.Field != "" {
+			field.Pointer = jsonPointer("." + typeError.Field)
+		}
+		field.Message = fmt.Sprintf("must be of type %s", typeError.Type)
+	}
+	return &problemError{status: fiber.StatusBadRequest, code: "malformed_body", detail: "invalid payload", errors: []fieldError{field}}
+}
+
+var indexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// jsonPointer turns a validator namespace such as "BulkOperation.book.title"
+// or "[0].title" into an RFC 6901 pointer, "/book/title" or "/0/title". The
+// leading struct name is dropped.
+func jsonPointer(namespace string) string {
+	namespace = indexPattern.ReplaceAllString(namespace, ".$1")
+	parts := strings.Split(namespace, ".")[1:]
+	for i, part := range parts {
+		parts[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(part)
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// ruleMessage describes a failed validation rule in plain words.
+func ruleMessage(fe validator.FieldError) string {
+	name := fe.Field()
+	switch fe.Tag() {
+	case "required", "required_unless", "required_without":
+		return fmt.Sprintf("%s is required", name)
+	case "endsnotwith":
+		return fmt.Sprintf("%s must not end with %q", name, fe.Param())
+	case "number":
+		return fmt.Sprintf("%s must be a number", name)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", name, strings.Join(strings.Fields(fe.Param()), ", "))
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", name, fe.Param())
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", name, fe.Param())
+	case "bcp47_language_tag":
+		return fmt.Sprintf("%s must be a BCP 47 language tag, eg: en-GB", name)
+	case "datetime":
+		return fmt.Sprintf("%s must be a date formatted as YYYY-MM-DD", name)
+	case "isbn":
+		return fmt.Sprintf("%s must be a valid ISBN-10 or ISBN-13", name)
+	case "alphanum":
+		return fmt.Sprintf("%s must only contain letters and digits", name)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", name, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must not be less than %s", name, fe.Param())
+	case "excluded_without":
+		return fmt.Sprintf("%s can't be given without %s", name, fe.Param())
+	case "email":
+		return fmt.Sprintf("%s must be an email address", name)
+	case "gtefield":
+		return fmt.Sprintf("%s must not be less than %s", name, fe.Param())
+	case "dns_rfc1035_label":
+		return fmt.Sprintf("%s must be lowercase letters, digits and hyphens, starting with a letter", name)
+	}
+	return fmt.Sprintf("%s failed on the %s rule", name, fe.Tag())
+}
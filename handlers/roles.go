@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Roles callers can hold, each granting what the ones before it grant.
+const (
+	RoleReader    = "reader"
+	RoleLibrarian = "librarian"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleReader:    1,
+	RoleLibrarian: 2,
+	RoleAdmin:     3,
+}
+
+const roleLocalKey = "role"
+
+// setRole records the role the caller authenticated with, admins also getting
+// admin privileges, but never those of the deployment admin. An empty role
+// grants nothing.
+func setRole(c *fiber.Ctx, role string) {
+	c.Locals(roleLocalKey, role)
+	if role == RoleAdmin {
+		c.Locals(adminLocalKey, true)
+	}
+}
+
+// hasRole reports whether role grants at least the required one.
+func hasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
+
+// requireRole rejects requests whose caller doesn't hold role. Route handlers
+// declare it next to each route in SetupRoutes. Requests that no
+// authentication middleware gave a role are rejected with 401, while admin
+// requests made with the admin token always pass.
+func requireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if isAdmin(c) {
+			return c.Next()
+		}
+		callerRole, authenticated := c.Locals(roleLocalKey).(string)
+		if !authenticated {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return &problemError{status: fiber.StatusUnauthorized, code: "unauthenticated", detail: "this route needs an authenticated caller"}
+		}
+		if !hasRole(callerRole, role) {
+			return &problemError{status: fiber.StatusForbidden, code: "insufficient_role", detail: fmt.Sprintf("this route needs the %s role", role)}
+		}
+		return c.Next()
+	}
+}
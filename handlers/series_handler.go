@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type SeriesHandler struct {
+	seriesService services.ISeriesService
+	validate      *validator.Validate
+}
+
+func NewSeriesHandler(service services.ISeriesService, validator *validator.Validate) *SeriesHandler {
+	return &SeriesHandler{
+		seriesService: service,
+		validate:      validator,
+	}
+}
+
+// series returns the series service of the tenant the request is made for.
+func (handler *SeriesHandler) series(c *fiber.Ctx) services.ISeriesService {
+	return handler.seriesService.ForTenant(requestTenant(c))
+}
+
+func (handler *SeriesHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/series", reader, handler.getAllSeries)
+	router.Get("/series/:id", reader, handler.getSeries)
+	router.Get("/series/:id/books", reader, handler.getSeriesBooks)
+	router.Post("/series", librarian, handler.newSeries)
+	router.Put("/series/:id", librarian, handler.updateSeries)
+	router.Delete("/series/:id", librarian, handler.deleteSeries)
+}
+
+func (handler *SeriesHandler) getAllSeries(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	series, total, err := handler.series(c).GetAllSeries(strings.TrimSpace(c.Query("name")), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    series,
+		Meta:    meta,
+	})
+}
+
+func (handler *SeriesHandler) getSeries(c *fiber.Ctx) error {
+	seriesId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	series, err := handler.series(c).GetSeries(uint(seriesId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    series,
+	})
+}
+
+// getSeriesBooks lists the books of a series in reading order.
+func (handler *SeriesHandler) getSeriesBooks(c *fiber.Ctx) error {
+	seriesId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	page, limit := paginationParams(c)
+
+	books, total, err := handler.series(c).GetSeriesBooks(uint(seriesId), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    books,
+		Meta:    meta,
+	})
+}
+
+func (handler *SeriesHandler) newSeries(c *fiber.Ctx) error {
+	var series models.Series
+	if err := parseBody(c, &series); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&series); err != nil {
+		return validationProblem(err)
+	}
+
+	createdSeries, err := handler.series(c).CreateSeries(&series)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdSeries,
+	})
+}
+
+func (handler *SeriesHandler) updateSeries(c *fiber.Ctx) error {
+	seriesId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var series models.Series
+	if err := parseBody(c, &series); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&series); err != nil {
+		return validationProblem(err)
+	}
+
+	series.ID = uint(seriesId)
+	updatedSeries, err := handler.series(c).UpdateSeries(&series)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedSeries,
+	})
+}
+
+func (handler *SeriesHandler) deleteSeries(c *fiber.Ctx) error {
+	seriesId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	series, err := handler.series(c).DeleteSeries(uint(seriesId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    series,
+	})
+}
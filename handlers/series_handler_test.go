@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSeriesTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewSeriesHandler(NewMockedSeriesService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestSeriesHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("list series", func(t *testing.T) {
+		app := setupSeriesTestApp(t)
+		res, response := send(app, "GET", "/series?limit=1", "")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, float64(2), response.Meta.(map[string]any)["total"])
+		assert.NotEmpty(t, res.Header.Get("Link"))
+	})
+
+	t.Run("get series", func(t *testing.T) {
+		app := setupSeriesTestApp(t)
+		res, response := send(app, "GET", "/series/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Robert Langdon", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "GET", "/series/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "GET", "/series/xx", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("books of a series", func(t *testing.T) {
+		app := setupSeriesTestApp(t)
+		res, response := send(app, "GET", "/series/1/books", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 2)
+		assert.Equal(t, "Angels & Demons", response.Data.([]any)[0].(map[string]any)["title"])
+		assert.Equal(t, float64(2), response.Meta.(map[string]any)["total"])
+
+		res, _ = send(app, "GET", "/series/99/books", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("create series", func(t *testing.T) {
+		app := setupSeriesTestApp(t)
+		res, response := send(app, "POST", "/series", `{"name": "Discworld"}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "Discworld", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "POST", "/series", `{"name": ""}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "POST", "/series", `{"name": "robert langdon"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+	})
+
+	t.Run("update series", func(t *testing.T) {
+		app := setupSeriesTestApp(t)
+		res, response := send(app, "PUT", "/series/2", `{"name": "The Dune Chronicles"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "The Dune Chronicles", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "PUT", "/series/99", `{"name": "Nothing"}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("delete series", func(t *testing.T) {
+		app := setupSeriesTestApp(t)
+		res, _ := send(app, "DELETE", "/series/1", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/series/2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/series/2", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("book detail links its neighbors", func(t *testing.T) {
+		app := setupTestApp(t)
+		for id, patch := range map[string]string{
+			"1": `{"series_id": 1, "series_position": 2}`,
+			"2": `{"series_id": 1, "series_position": 1}`,
+			"3": `{"series_id": 1, "series_position": 2.5}`,
+		} {
+			req := httptest.NewRequest("PATCH", "/books/"+id, strings.NewReader(patch))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			res, err := app.Test(req, -1)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+		}
+
+		res, response := send(app, "GET", "/books/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, map[string]any{"previous": float64(2), "next": float64(3)}, response.Meta)
+		assert.Equal(t, `<http://example.com/books/2>; rel="prev", <http://example.com/books/3>; rel="next"`, res.Header.Get("Link"))
+
+		res, response = send(app, "GET", "/books/3", "")
+		assert.Equal(t, map[string]any{"previous": float64(1)}, response.Meta)
+		assert.Equal(t, `<http://example.com/books/1>; rel="prev"`, res.Header.Get("Link"))
+
+		req := httptest.NewRequest("PATCH", "/books/1", strings.NewReader(`{"series_id": null}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		res, _ = app.Test(req, -1)
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, []fieldError{
+			{Pointer: "/series_position", Rule: "excluded_without", Message: "series_position can't be given without SeriesID"},
+		}, p.Errors)
+	})
+
+}
+
+type mockedSeriesService struct {
+	series []*models.Series
+	books  map[uint][]*models.Book
+	tenant string
+}
+
+var _ services.ISeriesService = (*mockedSeriesService)(nil)
+
+// NewMockedSeriesService returns two series, the first one having books.
+func NewMockedSeriesService() *mockedSeriesService {
+	series := []*models.Series{
+		{Name: "Robert Langdon"},
+		{Name: "Dune"},
+	}
+	for i, s := range series {
+		s.ID = uint(i + 1)
+	}
+
+	books := map[uint][]*models.Book{
+		1: {
+			{Title: "Angels & Demons", Author: "Dan Brown", Year: 2000},
+			{Title: "The Da Vinci Code", Author: "Dan Brown", Year: 2003},
+		},
+	}
+	return &mockedSeriesService{series: series, books: books}
+}
+
+func (m *mockedSeriesService) ForTenant(tenant *models.Tenant) services.ISeriesService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedSeriesService) GetAllSeries(name string, page, limit int) ([]*models.Series, int64, error) {
+	start := min((page-1)*limit, len(m.series))
+	end := min(start+limit, len(m.series))
+	return m.series[start:end], int64(len(m.series)), nil
+}
+
+func (m *mockedSeriesService) GetSeries(id uint) (*models.Series, error) {
+	for _, series := range m.series {
+		if series.ID == id {
+			return series, nil
+		}
+	}
+	return nil, services.ErrSeriesNotFound
+}
+
+func (m *mockedSeriesService) GetSeriesBooks(id uint, page, limit int) ([]*models.Book, int64, error) {
+	if _, err := m.GetSeries(id); err != nil {
+		return nil, 0, err
+	}
+	books := m.books[id]
+	start := min((page-1)*limit, len(books))
+	end := min(start+limit, len(books))
+	return books[start:end], int64(len(books)), nil
+}
+
+func (m *mockedSeriesService) CreateSeries(series *models.Series) (*models.Series, error) {
+	for _, existing := range m.series {
+		if strings.EqualFold(existing.Name, series.Name) {
+			return nil, services.ErrDuplicateSeries
+		}
+	}
+	series.ID = uint(len(m.series) + 1)
+	m.series = append(m.series, series)
+	return series, nil
+}
+
+func (m *mockedSeriesService) UpdateSeries(payload *models.Series) (*models.Series, error) {
+	series, err := m.GetSeries(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	series.Name = payload.Name
+	return series, nil
+}
+
+func (m *mockedSeriesService) DeleteSeries(id uint) (*models.Series, error) {
+	series, err := m.GetSeries(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.books[id]) > 0 {
+		return nil, services.ErrSeriesInUse
+	}
+	m.series = slices.DeleteFunc(m.series, func(s *models.Series) bool { return s.ID == id })
+	return series, nil
+}
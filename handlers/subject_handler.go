@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type SubjectHandler struct {
+	subjectService services.ISubjectService
+	validate       *validator.Validate
+}
+
+func NewSubjectHandler(service services.ISubjectService, validator *validator.Validate) *SubjectHandler {
+	return &SubjectHandler{
+		subjectService: service,
+		validate:       validator,
+	}
+}
+
+func (handler *SubjectHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/subjects", reader, handler.getAllSubjects)
+	router.Get("/subjects/:id", reader, handler.getSubject)
+	router.Post("/subjects", librarian, handler.newSubject)
+	router.Put("/subjects/:id", librarian, handler.updateSubject)
+	router.Delete("/subjects/:id", librarian, handler.deleteSubject)
+}
+
+func (handler *SubjectHandler) getAllSubjects(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	subjects, total, err := handler.subjectService.GetAllSubjects(strings.TrimSpace(c.Query("name")), page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    subjects,
+		Meta:    meta,
+	})
+}
+
+func (handler *SubjectHandler) getSubject(c *fiber.Ctx) error {
+	subjectId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	subject, err := handler.subjectService.GetSubject(uint(subjectId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    subject,
+	})
+}
+
+func (handler *SubjectHandler) newSubject(c *fiber.Ctx) error {
+	var subject models.Subject
+	if err := parseBody(c, &subject); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&subject); err != nil {
+		return validationProblem(err)
+	}
+
+	createdSubject, err := handler.subjectService.CreateSubject(&subject)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdSubject,
+	})
+}
+
+func (handler *SubjectHandler) updateSubject(c *fiber.Ctx) error {
+	subjectId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var subject models.Subject
+	if err := parseBody(c, &subject); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&subject); err != nil {
+		return validationProblem(err)
+	}
+
+	subject.ID = uint(subjectId)
+	updatedSubject, err := handler.subjectService.UpdateSubject(&subject)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedSubject,
+	})
+}
+
+func (handler *SubjectHandler) deleteSubject(c *fiber.Ctx) error {
+	subjectId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	subject, err := handler.subjectService.DeleteSubject(uint(subjectId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    subject,
+	})
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSubjectTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewSubjectHandler(NewMockedSubjectService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestSubjectHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("list subjects", func(t *testing.T) {
+		app := setupSubjectTestApp(t)
+		res, response := send(app, "GET", "/subjects?limit=1", "")
+
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, float64(2), response.Meta.(map[string]any)["total"])
+		assert.NotEmpty(t, res.Header.Get("Link"))
+	})
+
+	t.Run("get subject", func(t *testing.T) {
+		app := setupSubjectTestApp(t)
+		res, response := send(app, "GET", "/subjects/2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Mystery", response.Data.(map[string]any)["name"])
+		assert.Equal(t, float64(1), response.Data.(map[string]any)["parent_id"])
+
+		res, _ = send(app, "GET", "/subjects/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "GET", "/subjects/xx", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("create subject", func(t *testing.T) {
+		app := setupSubjectTestApp(t)
+		res, response := send(app, "POST", "/subjects", `{"name": "Cozy", "parent_id": 2}`)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "Cozy", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "POST", "/subjects", `{"name": ""}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "POST", "/subjects", `{"name": "fiction"}`)
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "POST", "/subjects", `{"name": "Cozy", "parent_id": 99}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+	})
+
+	t.Run("update subject", func(t *testing.T) {
+		app := setupSubjectTestApp(t)
+		res, response := send(app, "PUT", "/subjects/2", `{"name": "Crime", "parent_id": 1}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Crime", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "PUT", "/subjects/1", `{"name": "Fiction", "parent_id": 2}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+		res, _ = send(app, "PUT", "/subjects/99", `{"name": "Nothing"}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("delete subject", func(t *testing.T) {
+		app := setupSubjectTestApp(t)
+		res, _ := send(app, "DELETE", "/subjects/1", "")
+		assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+		res, _ = send(app, "DELETE", "/subjects/2", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/subjects/2", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("subject counts", func(t *testing.T) {
+		app := setupTestApp(t)
+		req := httptest.NewRequest("GET", "/books/subjects?author=Author%20A", nil)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var response struct {
+			Data []models.SubjectCount `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&response)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []models.SubjectCount{{Subject: models.Subject{Model: response.Data[0].Model, Name: "Fiction"}, Books: 1}}, response.Data)
+
+		for _, path := range []string{"/books/subjects?year_from=2030&year_to=2000", "/books?subject=fiction"} {
+			res, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+		}
+	})
+
+}
+
+type mockedSubjectService struct {
+	subjects []*models.Subject
+}
+
+var _ services.ISubjectService = (*mockedSubjectService)(nil)
+
+// NewMockedSubjectService returns Fiction with its child Mystery.
+func NewMockedSubjectService() *mockedSubjectService {
+	fiction := &models.Subject{Name: "Fiction"}
+	fiction.ID = 1
+	mystery := &models.Subject{Name: "Mystery", ParentID: &fiction.ID}
+	mystery.ID = 2
+
+	return &mockedSubjectService{subjects: []*models.Subject{fiction, mystery}}
+}
+
+func (m *mockedSubjectService) GetAllSubjects(name string, page, limit int) ([]*models.Subject, int64, error) {
+	start := min((page-1)*limit, len(m.subjects))
+	end := min(start+limit, len(m.subjects))
+	return m.subjects[start:end], int64(len(m.subjects)), nil
+}
+
+func (m *mockedSubjectService) GetSubject(id uint) (*models.Subject, error) {
+	for _, subject := range m.subjects {
+		if subject.ID == id {
+			return subject, nil
+		}
+	}
+	return nil, services.ErrSubjectNotFound
+}
+
+func (m *mockedSubjectService) CreateSubject(subject *models.Subject) (*models.Subject, error) {
+	if err := m.checkSubject(subject); err != nil {
+		return nil, err
+	}
+	subject.ID = uint(len(m.subjects) + 1)
+	m.subjects = append(m.subjects, subject)
+	return subject, nil
+}
+
+func (m *mockedSubjectService) UpdateSubject(payload *models.Subject) (*models.Subject, error) {
+	subject, err := m.GetSubject(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkSubject(payload); err != nil {
+		return nil, err
+	}
+	subject.Name, subject.ParentID = payload.Name, payload.ParentID
+	return subject, nil
+}
+
+// DeleteSubject treats the subjects having children as in use.
+func (m *mockedSubjectService) DeleteSubject(id uint) (*models.Subject, error) {
+	subject, err := m.GetSubject(id)
+	if err != nil {
+		return nil, err
+	}
+	if slices.ContainsFunc(m.subjects, func(s *models.Subject) bool { return s.ParentID != nil && *s.ParentID == id }) {
+		return nil, services.ErrSubjectInUse
+	}
+	m.subjects = slices.DeleteFunc(m.subjects, func(s *models.Subject) bool { return s.ID == id })
+	return subject, nil
+}
+
+func (m *mockedSubjectService) checkSubject(subject *models.Subject) error {
+	if subject.ParentID != nil {
+		parent, err := m.GetSubject(*subject.ParentID)
+		if err != nil {
+			return services.ErrUnknownSubject
+		}
+		if parent.ParentID != nil && *parent.ParentID == subject.ID {
+			return services.ErrSubjectCycle
+		}
+	}
+	for _, existing := range m.subjects {
+		if existing.ID != subject.ID && strings.EqualFold(existing.Name, subject.Name) && (existing.ParentID == nil) == (subject.ParentID == nil) {
+			return services.ErrDuplicateSubject
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+const tenantLocalKey = "tenant"
+
+// TenantConfig configures ResolveTenant.
+type TenantConfig struct {
+	// Header names the header naming the tenant. Defaults to X-Tenant-ID.
+	Header string
+	// Domain is the domain tenants are served under, eg: books.example.com
+	// serving the springfield tenant at springfield.books.example.com.
+	// Subdomains aren't looked at when empty.
+	Domain string
+	// Claim names the JWT claim naming the tenant. Defaults to tenant.
+	Claim string
+}
+
+// ResolveTenant finds the tenant a request is made for: the tenant of the API
+// key it was made with, or the one named by the tenant claim of its JWT, the
+// default tenant when the token has none. A header or the subdomain the
+// request was sent to may only name that same tenant, and name the tenant of
+// the requests made with the admin token. Requests naming no tenant are made
+// for the default one, and requests for unknown tenants are rejected.
+func ResolveTenant(service services.ITenantService, config TenantConfig) fiber.Handler {
+	if config.Header == "" {
+		config.Header = "X-Tenant-ID"
+	}
+	if config.Claim == "" {
+		config.Claim = "tenant"
+	}
+
+	return func(c *fiber.Ctx) error {
+		id, err := config.tenantID(c)
+		if err != nil {
+			return err
+		}
+
+		tenant, err := service.GetTenant(id)
+		if err != nil {
+			if errors.Is(err, services.ErrTenantNotFound) {
+				return &problemError{status: fiber.StatusBadRequest, code: "unknown_tenant", detail: fmt.Sprintf("unknown tenant %q", id)}
+			}
+			return err
+		}
+		c.Locals(tenantLocalKey, tenant)
+		return c.Next()
+	}
+}
+
+func (config TenantConfig) tenantID(c *fiber.Ctx) (string, error) {
+	requested := c.Get(config.Header)
+	if requested == "" && config.Domain != "" {
+		requested = subdomain(c.Hostname(), config.Domain)
+	}
+
+	// Keys and tokens granting admin privileges are bound to their tenant
+	// too, only the admin token isn't.
+	var bound, credential string
+	if apiKey, ok := c.Locals(apiKeyLocalKey).(*models.APIKey); ok {
+		bound, credential = apiKey.TenantID, "API key"
+	} else if claims, ok := c.Locals(jwtClaimsLocalKey).(map[string]any); ok {
+		bound, _ = claims[config.Claim].(string)
+		if bound == "" {
+			bound = models.DefaultTenant
+		}
+		credential = "token"
+	}
+
+	switch {
+	case bound != "" && requested != "" && requested != bound:
+		return "", &problemError{status: fiber.StatusForbidden, code: "tenant_mismatch", detail: fmt.Sprintf("the %s is for the tenant %q", credential, bound)}
+	case bound != "":
+		return bound, nil
+	case requested != "":
+		return requested, nil
+	}
+	return models.DefaultTenant, nil
+}
+
+// subdomain returns the label host has in front of domain, or an empty string
+// when host isn't a direct subdomain of domain.
+func subdomain(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, found := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+	if !found || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// requestTenant returns the tenant the request is made for, the default
+// tenant when ResolveTenant isn't installed.
+func requestTenant(c *fiber.Ctx) *models.Tenant {
+	if tenant, ok := c.Locals(tenantLocalKey).(*models.Tenant); ok {
+		return tenant
+	}
+	return &models.Tenant{ID: models.DefaultTenant}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type TenantHandler struct {
+	tenantService services.ITenantService
+	validate      *validator.Validate
+}
+
+func NewTenantHandler(service services.ITenantService, validator *validator.Validate) *TenantHandler {
+	return &TenantHandler{
+		tenantService: service,
+		validate:      validator,
+	}
+}
+
+// SetupRoutes registers the tenant routes. Tenants are managed with the admin
+// token, the admins of a tenant being bound to it, while callers can read the
+// tenant their requests are made for.
+func (handler *TenantHandler) SetupRoutes(router fiber.Router) {
+	router.Get("/tenant", requireRole(RoleReader), handler.getCurrentTenant)
+	router.Get("/tenants", deploymentAdminOnly, handler.getAllTenants)
+	router.Get("/tenants/:id", deploymentAdminOnly, handler.getTenant)
+	router.Post("/tenants", deploymentAdminOnly, handler.newTenant)
+	router.Put("/tenants/:id", deploymentAdminOnly, handler.updateTenant)
+}
+
+func (handler *TenantHandler) getCurrentTenant(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    requestTenant(c),
+	})
+}
+
+func (handler *TenantHandler) getAllTenants(c *fiber.Ctx) error {
+	page, limit := paginationParams(c)
+
+	tenants, total, err := handler.tenantService.GetAllTenants(page, limit)
+	if err != nil {
+		return err
+	}
+
+	meta := newPageMeta(total, page, limit)
+	c.Set(fiber.HeaderLink, paginationLinks(c, meta))
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    tenants,
+		Meta:    meta,
+	})
+}
+
+func (handler *TenantHandler) getTenant(c *fiber.Ctx) error {
+	tenant, err := handler.tenantService.GetTenant(c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    tenant,
+	})
+}
+
+func (handler *TenantHandler) newTenant(c *fiber.Ctx) error {
+	var tenant models.Tenant
+	if err := parseBody(c, &tenant); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&tenant); err != nil {
+		return validationProblem(err)
+	}
+
+	createdTenant, err := handler.tenantService.CreateTenant(&tenant)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(apiResponse{
+		Message: "success",
+		Data:    createdTenant,
+	})
+}
+
+func (handler *TenantHandler) updateTenant(c *fiber.Ctx) error {
+	var tenant models.Tenant
+	if err := parseBody(c, &tenant); err != nil {
+		return err
+	}
+
+	tenant.ID = c.Params("id")
+	if err := handler.validate.Struct(&tenant); err != nil {
+		return validationProblem(err)
+	}
+
+	updatedTenant, err := handler.tenantService.UpdateTenant(&tenant)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedTenant,
+	})
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTenantTestApp serves the tenant and the book routes for the tenants
+// found by ResolveTenant, served as subdomains of books.example.com and
+// named by the tenant claim of tokens signed by signer.
+func setupTenantTestApp(t *testing.T, signer *testSigner, bookService *mockedBookService) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+	validator.RegisterValidation("isbn", ValidateISBN)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, signer.jwks(), 0o600))
+	keys, err := LoadJWKS(path)
+	assert.NoError(t, err)
+
+	service := NewMockedTenantService()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(AdminAuth(testAdminToken))
+	app.Use(JWTAuth(JWTConfig{Keys: keys, Issuer: testIssuer, Audience: testAudience}, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+	app.Use(testAuth(RoleLibrarian))
+	app.Use(ResolveTenant(service, TenantConfig{Domain: "books.example.com"}))
+
+	NewTenantHandler(service, validator).SetupRoutes(app)
+	NewBookHandler(bookService, validator, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))).SetupRoutes(app)
+	return app
+}
+
+func TestTenantHandler(t *testing.T) {
+	signer := newTestSigner(t)
+
+	send := func(app *fiber.App, method, target, body string, headers ...string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("requests are made for a tenant", func(t *testing.T) {
+		bookService := NewMockedBookService()
+		app := setupTenantTestApp(t, signer, bookService)
+
+		res, _ := send(app, "GET", "/books", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.DefaultTenant, bookService.tenant)
+
+		res, _ = send(app, "GET", "/books", "", "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "springfield", bookService.tenant)
+
+		res, _ = send(app, "GET", "http://shelbyville.books.example.com/books", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "shelbyville", bookService.tenant)
+
+		res, response := send(app, "GET", "http://springfield.books.example.com:3030/tenant", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "springfield", response.Data.(map[string]any)["id"])
+
+		res, _ = send(app, "GET", "http://a.shelbyville.books.example.com/books", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.DefaultTenant, bookService.tenant)
+	})
+
+	t.Run("unknown tenants are rejected", func(t *testing.T) {
+		app := setupTenantTestApp(t, signer, NewMockedBookService())
+		req := httptest.NewRequest("GET", "/books", nil)
+		req.Header.Set("X-Tenant-ID", "ogdenville")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, "unknown_tenant", p.Code)
+	})
+
+	t.Run("tokens are bound to their tenant", func(t *testing.T) {
+		bookService := NewMockedBookService()
+		app := setupTenantTestApp(t, signer, bookService)
+		claims := testClaims(RoleReader)
+		claims["tenant"] = "springfield"
+		token := "Bearer " + signer.sign(t, "RS256", "rsa", claims)
+
+		res, _ := send(app, "GET", "/books", "", "Authorization", token)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "springfield", bookService.tenant)
+
+		res, _ = send(app, "GET", "/books", "", "Authorization", token, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", "", "Authorization", token, "X-Tenant-ID", "shelbyville")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "http://shelbyville.books.example.com/books", "", "Authorization", token)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		token = "Bearer " + signer.sign(t, "RS256", "rsa", testClaims(RoleAdmin))
+		res, _ = send(app, "GET", "/books", "", "Authorization", token)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.DefaultTenant, bookService.tenant)
+
+		res, _ = send(app, "GET", "/books", "", "Authorization", token, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("API keys are bound to their tenant", func(t *testing.T) {
+		validator := validator.New(validator.WithRequiredStructEnabled())
+		bookService, apiKeyService := NewMockedBookService(), NewMockedAPIKeyService()
+		app := fiber.New(fiber.Config{
+			ErrorHandler: ErrorHandler,
+		})
+		app.Use(AdminAuth(testAdminToken))
+		app.Use(APIKeyAuth(apiKeyService, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+		app.Use(ResolveTenant(NewMockedTenantService(), TenantConfig{Domain: "books.example.com"}))
+		NewAPIKeyHandler(apiKeyService, validator).SetupRoutes(app)
+		NewBookHandler(bookService, validator, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))).SetupRoutes(app)
+
+		res, response := send(app, "POST", "/api-keys", `{"name": "springfield", "scopes": ["books:admin"]}`, "X-Admin-Token", testAdminToken, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, "springfield", response.Data.(map[string]any)["tenant_id"])
+		key := response.Data.(map[string]any)["key"].(string)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "springfield", bookService.tenant)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", key, "X-Tenant-ID", "shelbyville")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "http://shelbyville.books.example.com/books", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", "bk_admin", "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", "bk_reader")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, models.DefaultTenant, bookService.tenant)
+
+		res, _ = send(app, "GET", "/books", "", "X-Admin-Token", testAdminToken, "X-Tenant-ID", "shelbyville")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "shelbyville", bookService.tenant)
+	})
+
+	t.Run("API keys are managed for a tenant", func(t *testing.T) {
+		validator := validator.New(validator.WithRequiredStructEnabled())
+		apiKeyService := NewMockedAPIKeyService()
+		app := fiber.New(fiber.Config{
+			ErrorHandler: ErrorHandler,
+		})
+		app.Use(AdminAuth(testAdminToken))
+		app.Use(APIKeyAuth(apiKeyService, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))))
+		app.Use(ResolveTenant(NewMockedTenantService(), TenantConfig{}))
+		NewAPIKeyHandler(apiKeyService, validator).SetupRoutes(app)
+		NewBookHandler(NewMockedBookService(), validator, slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))).SetupRoutes(app)
+
+		res, response := send(app, "POST", "/api-keys", `{"name": "springfield", "scopes": ["books:admin"]}`, "X-Admin-Token", testAdminToken, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		id := int(response.Data.(map[string]any)["ID"].(float64))
+		key := response.Data.(map[string]any)["key"].(string)
+
+		// The admin key of springfield can't reach the keys of another tenant,
+		// nor its own.
+		res, _ = send(app, "POST", "/api-keys/3/rotate", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res, _ = send(app, "GET", "/api-keys", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, response = send(app, "GET", "/api-keys", "", "X-Admin-Token", testAdminToken, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 1)
+
+		res, _ = send(app, "POST", fmt.Sprintf("/api-keys/%d/rotate", id), "", "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		res, _ = send(app, "DELETE", "/api-keys/3", "", "X-Admin-Token", testAdminToken, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res, _ = send(app, "DELETE", fmt.Sprintf("/api-keys/%d", id), "", "X-Admin-Token", testAdminToken, "X-Tenant-ID", "springfield")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res, _ = send(app, "GET", "/books", "", "X-API-Key", key)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("every service is scoped to the tenant", func(t *testing.T) {
+		app := setupTenantTestApp(t, signer, NewMockedBookService())
+		validator := validator.New(validator.WithRequiredStructEnabled())
+		workService, seriesService, copyService := NewMockedWorkService(), NewMockedSeriesService(), NewMockedCopyService()
+		holdService, loanService := NewMockedHoldService(), NewMockedLoanService()
+		patronService, fineService := NewMockedPatronService(), NewMockedFineService()
+		NewWorkHandler(workService, validator).SetupRoutes(app)
+		NewSeriesHandler(seriesService, validator).SetupRoutes(app)
+		NewCopyHandler(copyService, validator).SetupRoutes(app)
+		NewHoldHandler(holdService, validator).SetupRoutes(app)
+		NewLoanHandler(loanService, validator).SetupRoutes(app)
+		NewPatronHandler(patronService, validator).SetupRoutes(app)
+		NewFineHandler(fineService, validator).SetupRoutes(app)
+
+		for target, tenant := range map[string]*string{
+			"/works/1":           &workService.tenant,
+			"/series/1/books":    &seriesService.tenant,
+			"/copies":            &copyService.tenant,
+			"/books/1/holds":     &holdService.tenant,
+			"/loans/1":           &loanService.tenant,
+			"/patrons/1":         &patronService.tenant,
+			"/patrons/1/balance": &fineService.tenant,
+		} {
+			send(app, "GET", target, "", "X-Tenant-ID", "springfield")
+			assert.Equal(t, "springfield", *tenant, target)
+		}
+	})
+
+	t.Run("tenants are managed with the admin token", func(t *testing.T) {
+		app := setupTenantTestApp(t, signer, NewMockedBookService())
+		res, _ := send(app, "POST", "/tenants", `{"id": "ogdenville", "name": "Ogdenville"}`)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		// The admins of a tenant can't read or change the other tenants.
+		claims := testClaims(RoleAdmin)
+		claims["tenant"] = "springfield"
+		admin := signer.sign(t, "RS256", "rsa", claims)
+		res, _ = send(app, "PUT", "/tenants/shelbyville", `{"name": "pwned", "settings": {"require_isbn": true}}`, "Authorization", "Bearer "+admin)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res, _ = send(app, "PUT", "/tenants/springfield", `{"name": "Springfield"}`, "Authorization", "Bearer "+admin)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res, _ = send(app, "GET", "/tenants", "", "Authorization", "Bearer "+admin)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res, response := send(app, "GET", "/tenant", "", "Authorization", "Bearer "+admin)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "springfield", response.Data.(map[string]any)["id"])
+
+		res, response = send(app, "POST", "/tenants", `{"id": "ogdenville", "name": "Ogdenville", "settings": {"default_language": "nb", "require_isbn": true}}`, "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusCreated, res.StatusCode)
+		assert.Equal(t, true, response.Data.(map[string]any)["settings"].(map[string]any)["require_isbn"])
+
+		res, _ = send(app, "GET", "/books", "", "X-Tenant-ID", "ogdenville")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		req := httptest.NewRequest("POST", "/tenants", strings.NewReader(`{"id": "North Haverbrook", "name": "North Haverbrook", "settings": {"default_language": "??"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", testAdminToken)
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var p problem
+		json.NewDecoder(res.Body).Decode(&p)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Len(t, p.Errors, 2)
+		assert.Equal(t, "id must be lowercase letters, digits and hyphens, starting with a letter", p.Errors[0].Message)
+
+		res, response = send(app, "PUT", "/tenants/springfield", `{"name": "Springfield Elementary"}`, "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Springfield Elementary", response.Data.(map[string]any)["name"])
+
+		res, _ = send(app, "PUT", "/tenants/capital-city", `{"name": "Capital City"}`, "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, response = send(app, "GET", "/tenants", "", "X-Admin-Token", testAdminToken)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data, 4)
+	})
+}
+
+// mockedTenantService knows the default, springfield and shelbyville
+// tenants.
+type mockedTenantService struct {
+	tenants []*models.Tenant
+}
+
+var _ services.ITenantService = (*mockedTenantService)(nil)
+
+func NewMockedTenantService() *mockedTenantService {
+	return &mockedTenantService{tenants: []*models.Tenant{
+		{ID: models.DefaultTenant, Name: "Default"},
+		{ID: "springfield", Name: "Springfield"},
+		{ID: "shelbyville", Name: "Shelbyville"},
+	}}
+}
+
+func (m *mockedTenantService) GetAllTenants(page, limit int) ([]*models.Tenant, int64, error) {
+	return m.tenants, int64(len(m.tenants)), nil
+}
+
+func (m *mockedTenantService) GetTenant(id string) (*models.Tenant, error) {
+	for _, tenant := range m.tenants {
+		if tenant.ID == id {
+			return tenant, nil
+		}
+	}
+	return nil, services.ErrTenantNotFound
+}
+
+func (m *mockedTenantService) CreateTenant(tenant *models.Tenant) (*models.Tenant, error) {
+	m.tenants = append(m.tenants, tenant)
+	return tenant, nil
+}
+
+func (m *mockedTenantService) UpdateTenant(payload *models.Tenant) (*models.Tenant, error) {
+	tenant, err := m.GetTenant(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	tenant.Name = payload.Name
+	tenant.Settings = payload.Settings
+	return tenant, nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+)
+
+type WorkHandler struct {
+	workService services.IWorkService
+	validate    *validator.Validate
+}
+
+func NewWorkHandler(service services.IWorkService, validator *validator.Validate) *WorkHandler {
+	return &WorkHandler{
+		workService: service,
+		validate:    validator,
+	}
+}
+
+// works returns the work service of the tenant the request is made for.
+func (handler *WorkHandler) works(c *fiber.Ctx) services.IWorkService {
+	return handler.workService.ForTenant(requestTenant(c))
+}
+
+func (handler *WorkHandler) SetupRoutes(router fiber.Router) {
+	reader, librarian := requireRole(RoleReader), requireRole(RoleLibrarian)
+	router.Get("/works/:id", reader, handler.getWork)
+	router.Put("/works/:id", librarian, handler.updateWork)
+	router.Post("/works/:id/merge", librarian, handler.mergeBooks)
+}
+
+type mergeRequest struct {
+	BookIDs []uint `json:"book_ids" validate:"required,min=1,max=100,dive,required"`
+}
+
+func (handler *WorkHandler) getWork(c *fiber.Ctx) error {
+	workId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	work, err := handler.works(c).GetWork(uint(workId))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    work,
+	})
+}
+
+func (handler *WorkHandler) updateWork(c *fiber.Ctx) error {
+	workId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var work models.Work
+	if err := parseBody(c, &work); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&work); err != nil {
+		return validationProblem(err)
+	}
+
+	work.ID = uint(workId)
+	updatedWork, err := handler.works(c).UpdateWork(&work)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    updatedWork,
+	})
+}
+
+func (handler *WorkHandler) mergeBooks(c *fiber.Ctx) error {
+	workId, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid parameter")
+	}
+
+	var request mergeRequest
+	if err := parseBody(c, &request); err != nil {
+		return err
+	}
+
+	if err := handler.validate.Struct(&request); err != nil {
+		return validationProblem(err)
+	}
+
+	work, err := handler.works(c).MergeBooks(uint(workId), request.BookIDs)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(apiResponse{
+		Message: "success",
+		Data:    work,
+	})
+}
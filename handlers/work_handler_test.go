@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/nsltharaka/booksapi/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWorkTestApp(t *testing.T) *fiber.App {
+	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(FieldName)
+
+	handler := NewWorkHandler(NewMockedWorkService(), validator)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: ErrorHandler,
+	})
+	app.Use(testAuth(RoleLibrarian))
+
+	handler.SetupRoutes(app)
+	return app
+}
+
+func TestWorkHandler(t *testing.T) {
+
+	send := func(app *fiber.App, method, path, body string) (*http.Response, apiResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := app.Test(req, -1)
+		assert.NoError(t, err)
+
+		var apiResponse apiResponse
+		json.NewDecoder(res.Body).Decode(&apiResponse)
+		return res, apiResponse
+	}
+
+	t.Run("get work", func(t *testing.T) {
+		app := setupWorkTestApp(t)
+		res, response := send(app, "GET", "/works/1", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "Inferno", response.Data.(map[string]any)["title"])
+		assert.Len(t, response.Data.(map[string]any)["editions"], 1)
+
+		res, _ = send(app, "GET", "/works/99", "")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "GET", "/works/xx", "")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("update work", func(t *testing.T) {
+		app := setupWorkTestApp(t)
+		res, response := send(app, "PUT", "/works/1", `{"title": "The Inferno"}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "The Inferno", response.Data.(map[string]any)["title"])
+
+		res, _ = send(app, "PUT", "/works/1", `{"title": ""}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "PUT", "/works/99", `{"title": "Nothing"}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("merge books", func(t *testing.T) {
+		app := setupWorkTestApp(t)
+		res, response := send(app, "POST", "/works/1/merge", `{"book_ids": [2]}`)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, response.Data.(map[string]any)["editions"], 2)
+
+		res, _ = send(app, "POST", "/works/1/merge", `{"book_ids": []}`)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		res, _ = send(app, "POST", "/works/1/merge", `{"book_ids": [99]}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		res, _ = send(app, "POST", "/works/99/merge", `{"book_ids": [2]}`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+}
+
+type mockedWorkService struct {
+	works  []*models.Work
+	books  []*models.Book
+	tenant string
+}
+
+var _ services.IWorkService = (*mockedWorkService)(nil)
+
+func NewMockedWorkService() *mockedWorkService {
+	books := []*models.Book{
+		{Title: "Inferno", Author: "Dan Brown", Year: 2013},
+		{Title: "Inferno (Deluxe)", Author: "Dan Brown", Year: 2014},
+	}
+	var works []*models.Work
+	for i, book := range books {
+		book.ID = uint(i + 1)
+		work := &models.Work{Title: book.Title, Editions: []models.Book{*book}}
+		work.ID = book.ID
+		works = append(works, work)
+	}
+
+	return &mockedWorkService{works: works, books: books}
+}
+
+func (m *mockedWorkService) ForTenant(tenant *models.Tenant) services.IWorkService {
+	m.tenant = tenant.ID
+	return m
+}
+
+func (m *mockedWorkService) GetWork(id uint) (*models.Work, error) {
+	for _, work := range m.works {
+		if work.ID == id {
+			return work, nil
+		}
+	}
+	return nil, services.ErrWorkNotFound
+}
+
+func (m *mockedWorkService) UpdateWork(payload *models.Work) (*models.Work, error) {
+	work, err := m.GetWork(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	work.Title = payload.Title
+	return work, nil
+}
+
+func (m *mockedWorkService) MergeBooks(id uint, bookIDs []uint) (*models.Work, error) {
+	work, err := m.GetWork(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, bookID := range bookIDs {
+		if bookID == 0 || int(bookID) > len(m.books) {
+			return nil, services.ErrNotFound
+		}
+		work.Editions = append(work.Editions, *m.books[bookID-1])
+	}
+	return work, nil
+}
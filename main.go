@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"log/slog"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -34,13 +39,78 @@ func envConfig() string {
 
 }
 
+// trashRetention returns how long deleted books are kept in the trash before
+// they are purged, read from TRASH_RETENTION_DAYS. Zero keeps them forever.
+func trashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return 0
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// loanPolicy returns the loan policy, read from LOAN_DAYS, LOAN_RENEWALS and
+// HOLD_PICKUP_DAYS. Unset or invalid values keep the defaults.
+func loanPolicy() services.LoanPolicy {
+	policy := services.DefaultLoanPolicy
+	if days, err := strconv.Atoi(os.Getenv("LOAN_DAYS")); err == nil && days > 0 {
+		policy.Period = time.Duration(days) * 24 * time.Hour
+	}
+	if renewals, err := strconv.Atoi(os.Getenv("LOAN_RENEWALS")); err == nil && renewals >= 0 {
+		policy.RenewalLimit = renewals
+	}
+	if days, err := strconv.Atoi(os.Getenv("HOLD_PICKUP_DAYS")); err == nil && days > 0 {
+		policy.HoldPickup = time.Duration(days) * 24 * time.Hour
+	}
+	return policy
+}
+
+// jwtConfig returns the JWT configuration, read from JWT_JWKS, JWT_ISSUER,
+// JWT_AUDIENCE, JWT_ROLES_CLAIM and JWT_ROLES. It returns false when JWT_JWKS
+// is unset, which disables JWT authentication.
+func jwtConfig() (handlers.JWTConfig, bool, error) {
+	source := os.Getenv("JWT_JWKS")
+	if source == "" {
+		return handlers.JWTConfig{}, false, nil
+	}
+
+	config := handlers.JWTConfig{
+		Issuer:     os.Getenv("JWT_ISSUER"),
+		Audience:   os.Getenv("JWT_AUDIENCE"),
+		RolesClaim: os.Getenv("JWT_ROLES_CLAIM"),
+		Roles:      map[string]string{},
+	}
+	if config.Issuer == "" || config.Audience == "" {
+		return config, false, errors.New("JWT_ISSUER and JWT_AUDIENCE are required with JWT_JWKS")
+	}
+	for _, mapping := range strings.Split(os.Getenv("JWT_ROLES"), ",") {
+		if value, role, found := strings.Cut(strings.TrimSpace(mapping), "="); found {
+			config.Roles[value] = role
+		}
+	}
+
+	keys, err := handlers.LoadJWKS(source)
+	if err != nil {
+		return config, false, err
+	}
+	config.Keys = keys
+	return config, true, nil
+}
+
 func main() {
 
 	serverAddr := envConfig()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	db, err := database.Connect()
 	if err != nil {
-		panic("error in creating database connection")
+		log.Fatalf("error in creating database connection: %v", err)
 	}
 
 	config := fiber.Config{
@@ -50,15 +120,91 @@ func main() {
 	app := fiber.New(config)
 	app.Use(cors.New())
 
-	apiV1 := app.Group("/api").Group("/v1")
-
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 	validator := validator.New(validator.WithRequiredStructEnabled())
+	validator.RegisterTagNameFunc(handlers.FieldName)
+	if err := validator.RegisterValidation("isbn", handlers.ValidateISBN); err != nil {
+		log.Fatal(err)
+	}
+
+	apiKeyService := services.NewAPIKeyService(db, logger)
+
+	apiV1 := app.Group("/api").Group("/v1")
+	apiV1.Use(handlers.AdminAuth(os.Getenv("ADMIN_TOKEN")))
+	jwt, enabled, err := jwtConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if enabled {
+		apiV1.Use(handlers.JWTAuth(jwt, logger))
+	}
+	apiV1.Use(handlers.APIKeyAuth(apiKeyService, logger))
+
+	tenantService := services.NewTenantService(db, logger)
+	apiV1.Use(handlers.ResolveTenant(tenantService, handlers.TenantConfig{
+		Header: os.Getenv("TENANT_HEADER"),
+		Domain: os.Getenv("TENANT_DOMAIN"),
+		Claim:  os.Getenv("JWT_TENANT_CLAIM"),
+	}))
+
+	tenantHandler := handlers.NewTenantHandler(tenantService, validator)
+	tenantHandler.SetupRoutes(apiV1)
+
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, validator)
+	apiKeyHandler.SetupRoutes(apiV1)
 
 	bookService := services.NewBookService(db, logger)
-	bookHandler := handlers.NewBookHandler(bookService, validator)
+	bookHandler := handlers.NewBookHandler(bookService, validator, logger)
 	bookHandler.SetupRoutes(apiV1)
 
+	authorService := services.NewAuthorService(db, logger)
+	authorHandler := handlers.NewAuthorHandler(authorService, validator)
+	authorHandler.SetupRoutes(apiV1)
+
+	workService := services.NewWorkService(db, logger)
+	workHandler := handlers.NewWorkHandler(workService, validator)
+	workHandler.SetupRoutes(apiV1)
+
+	subjectService := services.NewSubjectService(db, logger)
+	subjectHandler := handlers.NewSubjectHandler(subjectService, validator)
+	subjectHandler.SetupRoutes(apiV1)
+
+	seriesService := services.NewSeriesService(db, logger)
+	seriesHandler := handlers.NewSeriesHandler(seriesService, validator)
+	seriesHandler.SetupRoutes(apiV1)
+
+	branchService := services.NewBranchService(db, logger)
+	branchHandler := handlers.NewBranchHandler(branchService, validator)
+	branchHandler.SetupRoutes(apiV1)
+
+	copyService := services.NewCopyService(db, logger)
+	copyHandler := handlers.NewCopyHandler(copyService, validator)
+	copyHandler.SetupRoutes(apiV1)
+
+	patronService := services.NewPatronService(db, logger)
+	patronHandler := handlers.NewPatronHandler(patronService, validator)
+	patronHandler.SetupRoutes(apiV1)
+
+	policy := loanPolicy()
+
+	loanService := services.NewLoanService(db, logger, policy)
+	loanHandler := handlers.NewLoanHandler(loanService, validator)
+	loanHandler.SetupRoutes(apiV1)
+
+	holdService := services.NewHoldService(db, logger, policy)
+	holdHandler := handlers.NewHoldHandler(holdService, validator)
+	holdHandler.SetupRoutes(apiV1)
+
+	fineService := services.NewFineService(db, logger)
+	fineHandler := handlers.NewFineHandler(fineService, validator)
+	fineHandler.SetupRoutes(apiV1)
+
+	if retention := trashRetention(); retention > 0 {
+		go bookService.RunTrashRetention(context.Background(), retention, time.Hour)
+	}
+	go holdService.RunHoldExpiry(context.Background(), time.Hour)
+	go fineService.RunFineAccrual(context.Background())
+
 	app.Hooks().OnListen(func(listenData fiber.ListenData) error {
 		logger.Info("Server started", slog.String("address", serverAddr))
 		return nil
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nsltharaka/booksapi/database"
+	"gorm.io/gorm"
+)
+
+const migrateUsage = "usage: migrate up|down|status|to N"
+
+// migrate runs the migrate command on the database: up applies the pending
+// migrations, down reverts the latest one, to N applies or reverts
+// migrations until the schema is at version N, and status lists them.
+func migrate(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.Open()
+	if err != nil {
+		return err
+	}
+
+	target := -1
+	var migrations []database.Migration
+	switch {
+	case args[0] == "status" && len(args) == 1:
+		return migrationStatus(db, out)
+	case args[0] == "up" && len(args) == 1:
+		migrations, err = database.MigrateUp(db)
+	case args[0] == "down" && len(args) == 1:
+		migrations, err = database.MigrateDown(db)
+	case args[0] == "to" && len(args) == 2:
+		if target, err = strconv.Atoi(args[1]); err != nil || target < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		migrations, err = database.MigrateTo(db, target)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	for _, m := range migrations {
+		// Migrations after the target version were reverted.
+		if args[0] == "down" || (target >= 0 && m.Version > target) {
+			fmt.Fprintf(out, "reverted %s\n", m)
+		} else {
+			fmt.Fprintf(out, "applied %s\n", m)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		fmt.Fprintln(out, "nothing to migrate")
+	}
+	if args[0] == "down" {
+		return nil
+	}
+	return skippedMigrations(db, target, out)
+}
+
+// skippedMigrations lists the migrations up to the target version, every one
+// when negative, that were left pending as SQLite was built without the
+// module they need.
+func skippedMigrations(db *gorm.DB, target int, out io.Writer) error {
+	states, err := database.MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if state.Unavailable && (target < 0 || state.Version <= target) {
+			fmt.Fprintf(out, "skipped %s, SQLite was built without %s\n", state.Migration, state.Requires)
+		}
+	}
+	return nil
+}
+
+// migrationStatus lists the migrations and when they were applied, followed
+// by the version of the schema.
+func migrationStatus(db *gorm.DB, out io.Writer) error {
+	states, err := database.MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+	version := 0
+	for _, state := range states {
+		applied := "pending"
+		if state.AppliedAt != nil {
+			applied = state.AppliedAt.Local().Format(time.DateTime)
+			version = max(version, state.Version)
+		}
+		if state.Unknown {
+			applied += " (unknown to this build)"
+		}
+		if state.Unavailable {
+			applied += fmt.Sprintf(" (SQLite was built without %s)", state.Requires)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", state.Migration, applied)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nthe schema is at version %d\n", version)
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes an API key can be granted. Each scope includes the ones before it,
+// eg: a key granted books:write can read too.
+const (
+	ScopeBooksRead  = "books:read"
+	ScopeBooksWrite = "books:write"
+	ScopeBooksAdmin = "books:admin"
+)
+
+var scopeLevels = []string{ScopeBooksRead, ScopeBooksWrite, ScopeBooksAdmin}
+
+// APIKey grants access to the API. Only a hash of the key is stored, the key
+// itself being shown once, when it is issued or rotated.
+type APIKey struct {
+	gorm.Model
+	Name       string     `json:"name" gorm:"not null" validate:"required,max=255,endsnotwith= "`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	Hash       string     `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json;not null" validate:"required,min=1,dive,oneof=books:read books:write books:admin"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// TenantID is the tenant the key was issued for. Requests made with the
+	// key are made for that tenant only.
+	TenantID string `json:"tenant_id" gorm:"not null;default:default;index" validate:"-"`
+	Key      string `json:"key,omitempty" gorm:"-" validate:"-"`
+}
+
+// HasScope reports whether the key was granted scope, or a scope including
+// it.
+func (k *APIKey) HasScope(scope string) bool {
+	level := slices.Index(scopeLevels, scope)
+	if level < 0 {
+		return false
+	}
+	return slices.ContainsFunc(k.Scopes, func(granted string) bool {
+		return slices.Index(scopeLevels, granted) >= level
+	})
+}
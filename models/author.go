@@ -0,0 +1,81 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Roles an author can have on a book.
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+)
+
+type Author struct {
+	gorm.Model
+	Name string `json:"name" gorm:"not null;index" validate:"required,max=255,endsnotwith= "`
+}
+
+// BookAuthor credits an author on a book, in the given role. Position orders
+// the credits of a book.
+type BookAuthor struct {
+	BookID   uint    `json:"-" gorm:"primaryKey"`
+	AuthorID uint    `json:"author_id" gorm:"primaryKey;index" validate:"required"`
+	Role     string  `json:"role" gorm:"primaryKey;default:author" validate:"omitempty,oneof=author editor translator illustrator"`
+	Position int     `json:"position" validate:"min=0"`
+	Author   *Author `json:"author,omitempty" validate:"-"`
+}
+
+var (
+	authorSeparators = regexp.MustCompile(`(?i)\s*(?:;|&|\band\b)\s*`)
+	spaces           = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeName trims name and collapses the spaces within it.
+func NormalizeName(name string) string {
+	return spaces.ReplaceAllString(strings.TrimSpace(name), " ")
+}
+
+// SplitAuthors splits a free text author line such as "Dan Brown and Tom
+// Smith" into the names it lists. Names are separated by "and", "&", ";" or
+// commas, except for a single "Last, First" name, which is turned around.
+// Names listed more than once, ignoring case, are only returned once.
+func SplitAuthors(line string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, part := range authorSeparators.Split(line, -1) {
+		fields := strings.Split(part, ",")
+		if len(fields) == 2 && isGivenName(fields[1]) && NormalizeName(fields[0]) != "" {
+			fields = []string{fields[1] + " " + fields[0]}
+		}
+
+		for _, field := range fields {
+			name := NormalizeName(field)
+			if name == "" || seen[strings.ToLower(name)] {
+				continue
+			}
+			seen[strings.ToLower(name)] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isGivenName reports whether s looks like the given name part of an inverted
+// name: a single word, or initials such as "J. R. R.".
+func isGivenName(s string) bool {
+	words := strings.Fields(s)
+	if len(words) == 1 {
+		return true
+	}
+	for _, word := range words {
+		if !strings.HasSuffix(word, ".") || len([]rune(word)) > 3 {
+			return false
+		}
+	}
+	return len(words) > 0
+}
@@ -2,9 +2,71 @@ package models
 
 import "gorm.io/gorm"
 
+// Formats a book can be published in.
+const (
+	FormatHardcover = "hardcover"
+	FormatPaperback = "paperback"
+	FormatEbook     = "ebook"
+	FormatAudiobook = "audiobook"
+)
+
 type Book struct {
 	gorm.Model
+
+	// TenantID is the catalog the book belongs to. Books are only visible to
+	// requests made for their tenant.
+	TenantID string `json:"-" gorm:"not null;default:default;uniqueIndex:idx_books_tenant_isbn,priority:1"`
+
 	Title  string `json:"title" validate:"required,endsnotwith= "`
 	Author string `json:"author" validate:"required,endsnotwith= "`
 	Year   int    `json:"year" validate:"required,number"`
+
+	// ISBN is stored as an ISBN-13, whichever form it was given in. It is
+	// unique within the tenant.
+	ISBN *string `json:"isbn,omitempty" gorm:"uniqueIndex:idx_books_tenant_isbn" validate:"omitempty,isbn"`
+
+	// Publisher is given by id, or by name to create it if needed.
+	PublisherID *uint      `json:"-" gorm:"index"`
+	Publisher   *Publisher `json:"publisher,omitempty"`
+	Edition     string     `json:"edition,omitempty" validate:"omitempty,max=255"`
+	Format      string     `json:"format,omitempty" validate:"omitempty,oneof=hardcover paperback ebook audiobook"`
+	PageCount   int        `json:"page_count,omitempty" validate:"omitempty,min=1"`
+
+	// Language is a BCP 47 language tag, eg: "en" or "pt-BR".
+	Language    string `json:"language,omitempty" validate:"omitempty,bcp47_language_tag"`
+	PublishedOn *Date  `json:"published_on,omitempty"`
+
+	// WorkID is the work the book is an edition of. A book created without
+	// one gets a work of its own.
+	WorkID *uint `json:"work_id,omitempty" gorm:"index"`
+
+	// SeriesPosition orders the books of a series, fractional positions such
+	// as 2.5 fitting novellas between two novels.
+	SeriesID       *uint    `json:"series_id,omitempty" gorm:"index"`
+	SeriesPosition *float64 `json:"series_position,omitempty" validate:"omitempty,gt=0,excluded_without=SeriesID"`
+
+	// Authors credits the authors of the book. Author is kept as the author
+	// line of the book, and the credits are derived from it when none are
+	// given.
+	Authors []BookAuthor `json:"authors,omitempty" validate:"omitempty,dive"`
+
+	// Subjects lists the subjects the book is assigned to.
+	Subjects []BookSubject `json:"subjects,omitempty" validate:"omitempty,dive"`
+
+	// Availability sums up the copies of the book. It is only filled in when
+	// a single book is read.
+	Availability *Availability `json:"availability,omitempty" gorm:"-" validate:"-"`
+
+	// Version is bumped on every update and is used as the ETag of the book.
+	Version uint `json:"version" gorm:"not null;default:1"`
+}
+
+type BookSearchResult struct {
+	Book
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+
+	// Editions is the number of editions of the work that matched, the
+	// best matching one being the result.
+	Editions int `json:"editions"`
 }
@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// Branch is a location of the library holding copies of books.
+type Branch struct {
+	gorm.Model
+	Name    string `json:"name" gorm:"not null;index" validate:"required,max=255,endsnotwith= "`
+	Address string `json:"address,omitempty" validate:"omitempty,max=255"`
+}
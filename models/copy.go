@@ -0,0 +1,52 @@
+package models
+
+import "gorm.io/gorm"
+
+// Statuses a copy can be in.
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyLost      = "lost"
+	CopyInRepair  = "in_repair"
+	CopyOnHold    = "on_hold"
+)
+
+// Conditions a copy can be in.
+const (
+	ConditionNew     = "new"
+	ConditionGood    = "good"
+	ConditionFair    = "fair"
+	ConditionPoor    = "poor"
+	ConditionDamaged = "damaged"
+)
+
+// Copy is a physical copy of a book, shelved at a branch.
+type Copy struct {
+	gorm.Model
+	BookID    uint    `json:"book_id" gorm:"not null;index" validate:"required"`
+	Barcode   string  `json:"barcode" gorm:"not null;uniqueIndex" validate:"required,max=64,alphanum"`
+	BranchID  uint    `json:"branch_id" gorm:"not null;index" validate:"required"`
+	Branch    *Branch `json:"branch,omitempty" validate:"-"`
+	Shelf     string  `json:"shelf,omitempty" validate:"omitempty,max=64"`
+	Condition string  `json:"condition,omitempty" validate:"omitempty,oneof=new good fair poor damaged"`
+	Status    string  `json:"status" gorm:"not null;default:available;index" validate:"omitempty,oneof=available on_loan lost in_repair on_hold"`
+}
+
+// Availability sums up the copies of a book, in total and by branch.
+type Availability struct {
+	Total     int64                `json:"total"`
+	Available int64                `json:"available"`
+	Branches  []BranchAvailability `json:"branches"`
+}
+
+// BranchAvailability counts the copies of a book at a branch by status.
+type BranchAvailability struct {
+	BranchID  uint   `json:"branch_id"`
+	Branch    string `json:"branch"`
+	Total     int64  `json:"total"`
+	Available int64  `json:"available"`
+	OnLoan    int64  `json:"on_loan"`
+	Lost      int64  `json:"lost"`
+	InRepair  int64  `json:"in_repair"`
+	OnHold    int64  `json:"on_hold"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const dateLayout = time.DateOnly
+
+// Date is a calendar date without a time of day, written as YYYY-MM-DD in
+// JSON and in the database.
+type Date struct {
+	time.Time
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	return Date{t}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	date, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Date) Scan(value any) error {
+	switch value := value.(type) {
+	case time.Time:
+		*d = Date{time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)}
+		return nil
+	case string:
+		return d.parse(value)
+	case []byte:
+		return d.parse(string(value))
+	}
+	return fmt.Errorf("cannot scan %T into a date", value)
+}
+
+func (d *Date) parse(s string) error {
+	if len(s) > len(dateLayout) {
+		s = s[:len(dateLayout)]
+	}
+	date, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
+
+func (Date) GormDataType() string {
+	return "date"
+}
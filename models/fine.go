@@ -0,0 +1,49 @@
+package models
+
+import "gorm.io/gorm"
+
+// FinePolicy sets the overdue fines of the books of a format, their material
+// type. The policy without a format applies to the formats without a policy
+// of their own. Amounts are in cents.
+type FinePolicy struct {
+	gorm.Model
+	Format    string `json:"format" gorm:"uniqueIndex" validate:"omitempty,oneof=hardcover paperback ebook audiobook"`
+	DailyRate int64  `json:"daily_rate" validate:"gte=0"`
+	GraceDays int    `json:"grace_days" validate:"gte=0"`
+	MaxFine   int64  `json:"max_fine" validate:"gte=0"`
+}
+
+// ClosedDay is a date a branch is closed, on which no fines accrue on the
+// copies of the branch.
+type ClosedDay struct {
+	ID       uint   `json:"id" gorm:"primarykey"`
+	BranchID uint   `json:"branch_id" gorm:"not null;uniqueIndex:idx_closed_days_branch_date"`
+	Date     string `json:"date" gorm:"not null;uniqueIndex:idx_closed_days_branch_date" validate:"required,datetime=2006-01-02"`
+}
+
+// Kinds of ledger entries.
+const (
+	EntryCharge  = "charge"
+	EntryPayment = "payment"
+	EntryWaiver  = "waiver"
+)
+
+// LedgerEntry is a charge to a patron, or a payment or a waiver reducing what
+// they owe. Amounts are in cents.
+type LedgerEntry struct {
+	gorm.Model
+	PatronID uint   `json:"patron_id" gorm:"not null;index"`
+	LoanID   *uint  `json:"loan_id,omitempty" gorm:"index"`
+	Kind     string `json:"kind" gorm:"not null"`
+	Amount   int64  `json:"amount" gorm:"not null" validate:"required,gt=0"`
+	Note     string `json:"note,omitempty" validate:"omitempty,max=255"`
+}
+
+// Balance sums up the ledger of a patron. Balance is what the patron owes.
+type Balance struct {
+	PatronID uint  `json:"patron_id"`
+	Charges  int64 `json:"charges"`
+	Payments int64 `json:"payments"`
+	Waivers  int64 `json:"waivers"`
+	Balance  int64 `json:"balance"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Statuses a hold can be in. A hold waits in the queue of its book until a
+// copy is set aside for it, then it is ready for pickup until it is fulfilled
+// by a checkout or expires.
+const (
+	HoldWaiting   = "waiting"
+	HoldReady     = "ready"
+	HoldFulfilled = "fulfilled"
+	HoldExpired   = "expired"
+	HoldCancelled = "cancelled"
+)
+
+// Hold is a patron's place in the queue for a book, to be picked up at a
+// branch.
+type Hold struct {
+	gorm.Model
+	BookID         uint       `json:"book_id" gorm:"not null;index"`
+	PatronID       uint       `json:"patron_id" gorm:"not null;index" validate:"required"`
+	PickupBranchID uint       `json:"pickup_branch_id" gorm:"not null" validate:"required"`
+	PickupBranch   *Branch    `json:"pickup_branch,omitempty" validate:"-"`
+	Status         string     `json:"status" gorm:"not null;default:waiting;index"`
+	CopyID         *uint      `json:"copy_id,omitempty" gorm:"index"`
+	ReadyAt        *time.Time `json:"ready_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	Position       int        `json:"position,omitempty" gorm:"-"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "strings"
+
+// NormalizeISBN returns the ISBN-13 form of an ISBN-10 or ISBN-13. Hyphens and
+// spaces are ignored. ok is false when isbn is not a valid ISBN, including
+// when its check digit is wrong.
+func NormalizeISBN(isbn string) (normalized string, ok bool) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+
+	switch len(digits) {
+	case 10:
+		if !validISBN10(digits) {
+			return "", false
+		}
+		return withISBN13CheckDigit("978" + digits[:9]), true
+	case 13:
+		if !validISBN13(digits) {
+			return "", false
+		}
+		return digits, true
+	}
+	return "", false
+}
+
+func validISBN10(digits string) bool {
+	sum := 0
+	for i, r := range digits {
+		var value int
+		switch {
+		case r >= '0' && r <= '9':
+			value = int(r - '0')
+		case r == 'X' && i == 9:
+			value = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * value
+	}
+	return sum%11 == 0
+}
+
+func validISBN13(digits string) bool {
+	if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return withISBN13CheckDigit(digits[:12]) == digits
+}
+
+// withISBN13CheckDigit appends the check digit to the first 12 digits of an
+// ISBN-13.
+func withISBN13CheckDigit(digits string) string {
+	sum := 0
+	for i, r := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(r-'0')
+	}
+	return digits + string(rune('0'+(10-sum%10)%10))
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Loan lends a copy to a patron until it is returned. A copy has at most one
+// loan that hasn't been returned.
+type Loan struct {
+	gorm.Model
+	CopyID       uint       `json:"copy_id" gorm:"not null;index;uniqueIndex:idx_loans_active_copy,where:returned_at IS NULL"`
+	Copy         *Copy      `json:"copy,omitempty"`
+	PatronID     uint       `json:"patron_id" gorm:"not null;index"`
+	CheckedOutAt time.Time  `json:"checked_out_at" gorm:"not null"`
+	DueAt        time.Time  `json:"due_at" gorm:"not null;index"`
+	ReturnedAt   *time.Time `json:"returned_at,omitempty"`
+	Renewals     int        `json:"renewals" gorm:"not null;default:0"`
+}
+
+// Overdue reports whether the loan is still out past its due date at now.
+func (l *Loan) Overdue(now time.Time) bool {
+	return l.ReturnedAt == nil && now.After(l.DueAt)
+}
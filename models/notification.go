@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+// Events patrons are notified of.
+const (
+	EventHoldPlaced    = "hold_placed"
+	EventHoldReady     = "hold_ready"
+	EventHoldExpired   = "hold_expired"
+	EventHoldFulfilled = "hold_fulfilled"
+	EventHoldCancelled = "hold_cancelled"
+)
+
+// Notification is an event to let a patron know about, eg: a hold being ready
+// for pickup. Notifications are recorded along with the change that caused
+// them, to be delivered to the patron.
+type Notification struct {
+	gorm.Model
+	PatronID uint   `json:"patron_id" gorm:"not null;index"`
+	Event    string `json:"event" gorm:"not null"`
+	HoldID   uint   `json:"hold_id" gorm:"not null;index"`
+	BookID   uint   `json:"book_id" gorm:"not null"`
+}
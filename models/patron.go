@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+// Patron is a member of the library who can borrow copies.
+type Patron struct {
+	gorm.Model
+
+	// TenantID is the tenant the patron is a member of. Patrons, their loans,
+	// holds and ledger are only visible to requests made for their tenant.
+	TenantID string `json:"-" gorm:"not null;default:default;index"`
+
+	Name  string `json:"name" gorm:"not null" validate:"required,max=255,endsnotwith= "`
+	Email string `json:"email" gorm:"not null;index" validate:"required,max=255,email"`
+}
@@ -0,0 +1,8 @@
+package models
+
+import "gorm.io/gorm"
+
+type Publisher struct {
+	gorm.Model
+	Name string `json:"name" gorm:"not null;index" validate:"omitempty,max=255,endsnotwith= "`
+}
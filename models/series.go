@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// Series groups books meant to be read in order, such as the novels of a
+// saga.
+type Series struct {
+	gorm.Model
+	Name string `json:"name" gorm:"not null;index" validate:"required,max=255,endsnotwith= "`
+}
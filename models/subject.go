@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+// Subject categorizes books. Subjects form a hierarchy, eg: Fiction > Mystery
+// > Cozy, a subject without a parent being at the top of it.
+type Subject struct {
+	gorm.Model
+	Name     string    `json:"name" gorm:"not null;index" validate:"required,max=255,endsnotwith= "`
+	ParentID *uint     `json:"parent_id,omitempty" gorm:"index"`
+	Children []Subject `json:"children,omitempty" gorm:"foreignKey:ParentID" validate:"-"`
+}
+
+// BookSubject assigns a subject to a book.
+type BookSubject struct {
+	BookID    uint     `json:"-" gorm:"primaryKey"`
+	SubjectID uint     `json:"subject_id" gorm:"primaryKey;index" validate:"required"`
+	Subject   *Subject `json:"subject,omitempty" validate:"-"`
+}
+
+// SubjectCount is a subject along with the number of books it was counted
+// on, books of its descendant subjects included.
+type SubjectCount struct {
+	Subject
+	Books int64 `json:"books"`
+}
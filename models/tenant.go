@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// DefaultTenant holds the books of requests that name no tenant, including
+// the books created before tenants were introduced.
+const DefaultTenant = "default"
+
+// Tenant is a catalog hosted by the deployment, eg: the one of a school. Its
+// books are only visible to requests made for it. The id doubles as the
+// subdomain the catalog is served under.
+type Tenant struct {
+	ID        string         `json:"id" gorm:"primaryKey" validate:"required,max=63,dns_rfc1035_label"`
+	Name      string         `json:"name" validate:"required,max=255"`
+	Settings  TenantSettings `json:"settings" gorm:"serializer:json"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// TenantSettings tune how the books of a tenant are catalogued.
+type TenantSettings struct {
+	// DefaultLanguage is given to books created without a language.
+	DefaultLanguage string `json:"default_language,omitempty" validate:"omitempty,bcp47_language_tag"`
+
+	// RequireISBN rejects books without an ISBN.
+	RequireISBN bool `json:"require_isbn"`
+}
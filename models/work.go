@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// Work groups the books that are editions or translations of the same work.
+type Work struct {
+	gorm.Model
+	Title    string `json:"title" gorm:"not null" validate:"required,max=255,endsnotwith= "`
+	Editions []Book `json:"editions,omitempty" validate:"-"`
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key is revoked")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// apiKeyPrefix starts every API key, to tell them apart from other secrets.
+const apiKeyPrefix = "bk_"
+
+type IAPIKeyService interface {
+	GetAllAPIKeys(page, limit int) ([]*models.APIKey, int64, error)
+	IssueAPIKey(apiKey *models.APIKey) (*models.APIKey, error)
+	RotateAPIKey(id uint) (*models.APIKey, error)
+	RevokeAPIKey(id uint) (*models.APIKey, error)
+	Authenticate(key string) (*models.APIKey, error)
+	ForTenant(tenant *models.Tenant) IAPIKeyService
+}
+
+var _ IAPIKeyService = (*APIKeyService)(nil)
+
+type APIKeyService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewAPIKeyService(db *gorm.DB, logger *slog.Logger) *APIKeyService {
+	return &APIKeyService{db: db, logger: logger, now: time.Now}
+}
+
+// ForTenant returns a service that only lists, rotates and revokes the keys
+// bound to tenant, the keys of other tenants being not found.
+func (s *APIKeyService) ForTenant(tenant *models.Tenant) IAPIKeyService {
+	return &APIKeyService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID), now: s.now}
+}
+
+// GetAllAPIKeys returns a page of the API keys, revoked ones included, the
+// most recently issued first.
+func (s *APIKeyService) GetAllAPIKeys(page, limit int) ([]*models.APIKey, int64, error) {
+	db := s.db.Model(&models.APIKey{})
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting api keys", "error", err)
+		return nil, 0, fmt.Errorf("error while counting api keys : %w", err)
+	}
+
+	var apiKeys []*models.APIKey
+	offset := (page - 1) * limit
+	if err := db.Order("id DESC").Limit(limit).Offset(offset).Find(&apiKeys).Error; err != nil {
+		s.logger.Error("error fetching api keys", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching api keys : %w", err)
+	}
+	s.logger.Info("fetched api keys", "count", len(apiKeys), "total", total, "page", page, "limit", limit)
+	return apiKeys, total, nil
+}
+
+// IssueAPIKey generates a new key with the name, scopes and tenant of apiKey,
+// the default tenant when it has none. The returned key is the only place the
+// key itself can be read from.
+func (s *APIKeyService) IssueAPIKey(apiKey *models.APIKey) (*models.APIKey, error) {
+	apiKey.Scopes = slices.Compact(slices.Sorted(slices.Values(apiKey.Scopes)))
+	if apiKey.TenantID == "" {
+		apiKey.TenantID = models.DefaultTenant
+	}
+	apiKey.LastUsedAt, apiKey.RevokedAt = nil, nil
+	if err := generateKey(apiKey); err != nil {
+		s.logger.Error("failed to generate api key", "error", err)
+		return nil, fmt.Errorf("failed to issue api key : %w", err)
+	}
+	if err := s.db.Create(apiKey).Error; err != nil {
+		s.logger.Error("failed to issue api key", "error", err)
+		return nil, fmt.Errorf("failed to issue api key : %w", err)
+	}
+	s.logger.Info("issued api key", "id", apiKey.ID, "prefix", apiKey.Prefix, "scopes", apiKey.Scopes, "tenant", apiKey.TenantID)
+	return apiKey, nil
+}
+
+// RotateAPIKey replaces the key of an API key, which keeps its name, scopes
+// and tenant. The previous key stops working right away.
+func (s *APIKeyService) RotateAPIKey(id uint) (*models.APIKey, error) {
+	apiKey, err := s.getActiveAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := generateKey(apiKey); err != nil {
+		s.logger.Error("failed to generate api key", "id", id, "error", err)
+		return nil, fmt.Errorf("failed to rotate api key : %w", err)
+	}
+	if err := s.db.Model(apiKey).Select("prefix", "hash").Updates(apiKey).Error; err != nil {
+		s.logger.Error("error saving rotated api key", "id", id, "error", err)
+		return nil, fmt.Errorf("error while rotating the api key : %w", err)
+	}
+	s.logger.Info("rotated api key", "id", id, "prefix", apiKey.Prefix)
+	return apiKey, nil
+}
+
+// RevokeAPIKey stops an API key from working. The key is kept, for the
+// record.
+func (s *APIKeyService) RevokeAPIKey(id uint) (*models.APIKey, error) {
+	apiKey, err := s.getActiveAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	apiKey.RevokedAt = &now
+	if err := s.db.Model(apiKey).Select("revoked_at").Updates(apiKey).Error; err != nil {
+		s.logger.Error("error revoking api key", "id", id, "error", err)
+		return nil, fmt.Errorf("error while revoking the api key : %w", err)
+	}
+	s.logger.Info("revoked api key", "id", id, "prefix", apiKey.Prefix)
+	return apiKey, nil
+}
+
+// Authenticate returns the API key key belongs to, unless it was revoked.
+func (s *APIKeyService) Authenticate(key string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := s.db.Where("hash = ? AND revoked_at IS NULL", hashKey(key)).First(&apiKey).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("unknown api key", "prefix", keyPrefix(key))
+			return nil, ErrInvalidAPIKey
+		}
+		s.logger.Error("error authenticating api key", "error", err)
+		return nil, fmt.Errorf("error while authenticating the api key : %w", err)
+	}
+
+	now := s.now()
+	err = s.db.Model(&apiKey).
+		Where("last_used_at IS NULL OR last_used_at < ?", now.Add(-time.Minute)).
+		UpdateColumn("last_used_at", now).Error
+	if err != nil {
+		s.logger.Error("error recording api key use", "id", apiKey.ID, "error", err)
+	}
+	return &apiKey, nil
+}
+
+func (s *APIKeyService) getActiveAPIKey(id uint) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.db.First(&apiKey, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("api key not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrAPIKeyNotFound, id)
+		}
+		s.logger.Error("error fetching api key", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the api key : %w", err)
+	}
+	if apiKey.RevokedAt != nil {
+		s.logger.Warn("api key is revoked", "id", id)
+		return nil, fmt.Errorf("%w: id %d", ErrAPIKeyRevoked, id)
+	}
+	return &apiKey, nil
+}
+
+// generateKey sets a new random key on apiKey, along with its hash and the
+// prefix it can be recognized by.
+func generateKey(apiKey *models.APIKey) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	apiKey.Key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	apiKey.Hash = hashKey(apiKey.Key)
+	apiKey.Prefix = keyPrefix(apiKey.Key)
+	return nil
+}
+
+// hashKey hashes an API key for storage. Keys are random and long enough for
+// a fast hash to be safe, and lookups need it to be deterministic.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// keyPrefix returns the start of key, enough to recognize it in logs.
+func keyPrefix(key string) string {
+	return key[:min(len(key), len(apiKeyPrefix)+8)]
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeys(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	apiKeyService := NewAPIKeyService(service.db, service.logger)
+
+	issued, err := apiKeyService.IssueAPIKey(&models.APIKey{Name: "catalogue", Scopes: []string{models.ScopeBooksWrite, models.ScopeBooksRead, models.ScopeBooksRead}})
+	assert.NoError(t, err)
+	key := issued.Key
+
+	t.Run("keys are stored hashed", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(key, "bk_"))
+		assert.Equal(t, key[:11], issued.Prefix)
+		assert.Equal(t, []string{models.ScopeBooksRead, models.ScopeBooksWrite}, issued.Scopes)
+
+		var stored models.APIKey
+		assert.NoError(t, service.db.First(&stored, issued.ID).Error)
+		assert.NotContains(t, stored.Hash, key)
+		assert.Equal(t, hashKey(key), stored.Hash)
+		assert.Empty(t, stored.Key)
+	})
+
+	t.Run("keys authenticate", func(t *testing.T) {
+		apiKey, err := apiKeyService.Authenticate(key)
+		assert.NoError(t, err)
+		assert.Equal(t, issued.ID, apiKey.ID)
+		assert.True(t, apiKey.HasScope(models.ScopeBooksRead))
+		assert.False(t, apiKey.HasScope(models.ScopeBooksAdmin))
+
+		_, err = apiKeyService.Authenticate("bk_unknown")
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+		keys, total, err := apiKeyService.GetAllAPIKeys(1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.NotNil(t, keys[0].LastUsedAt)
+	})
+
+	t.Run("rotating replaces the key", func(t *testing.T) {
+		rotated, err := apiKeyService.RotateAPIKey(issued.ID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, key, rotated.Key)
+
+		_, err = apiKeyService.Authenticate(key)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+		_, err = apiKeyService.Authenticate(rotated.Key)
+		assert.NoError(t, err)
+		key = rotated.Key
+	})
+
+	t.Run("revoked keys stop working", func(t *testing.T) {
+		_, err := apiKeyService.RevokeAPIKey(issued.ID)
+		assert.NoError(t, err)
+
+		_, err = apiKeyService.Authenticate(key)
+		assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+		_, err = apiKeyService.RevokeAPIKey(issued.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyRevoked)
+		_, err = apiKeyService.RotateAPIKey(issued.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyRevoked)
+		_, err = apiKeyService.RotateAPIKey(99)
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	})
+
+	t.Run("keys are bound to a tenant", func(t *testing.T) {
+		assert.Equal(t, models.DefaultTenant, issued.TenantID)
+
+		springfield, err := apiKeyService.IssueAPIKey(&models.APIKey{Name: "springfield", Scopes: []string{models.ScopeBooksRead}, TenantID: "springfield"})
+		assert.NoError(t, err)
+		apiKey, err := apiKeyService.Authenticate(springfield.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, "springfield", apiKey.TenantID)
+
+		rotated, err := apiKeyService.RotateAPIKey(springfield.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "springfield", rotated.TenantID)
+	})
+	t.Run("keys of other tenants are out of reach", func(t *testing.T) {
+		ours, theirs := apiKeyService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), apiKeyService.ForTenant(&models.Tenant{ID: "springfield"})
+		springfield, err := theirs.IssueAPIKey(&models.APIKey{Name: "springfield admin", Scopes: []string{models.ScopeBooksAdmin}, TenantID: "springfield"})
+		assert.NoError(t, err)
+
+		apiKeys, total, err := ours.GetAllAPIKeys(1, 10)
+		assert.NoError(t, err)
+		for _, apiKey := range apiKeys {
+			assert.Equal(t, models.DefaultTenant, apiKey.TenantID)
+		}
+		_, all, _ := apiKeyService.GetAllAPIKeys(1, 10)
+		assert.Equal(t, all-2, total)
+
+		_, err = ours.RotateAPIKey(springfield.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+		_, err = ours.RevokeAPIKey(springfield.ID)
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+		_, err = apiKeyService.Authenticate(springfield.Key)
+		assert.NoError(t, err)
+		_, err = theirs.RevokeAPIKey(springfield.ID)
+		assert.NoError(t, err)
+	})
+}
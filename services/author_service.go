@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuthorNotFound  = errors.New("author not found")
+	ErrAuthorInUse     = errors.New("author is credited on books")
+	ErrDuplicateAuthor = errors.New("author already exists")
+	ErrUnknownAuthor   = errors.New("unknown author")
+)
+
+type IAuthorService interface {
+	GetAllAuthors(name string, page, limit int) ([]*models.Author, int64, error)
+	GetAuthor(id uint) (*models.Author, error)
+	CreateAuthor(author *models.Author) (*models.Author, error)
+	UpdateAuthor(payload *models.Author) (*models.Author, error)
+	DeleteAuthor(id uint) (*models.Author, error)
+}
+
+var _ IAuthorService = (*AuthorService)(nil)
+
+type AuthorService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewAuthorService(db *gorm.DB, logger *slog.Logger) *AuthorService {
+	return &AuthorService{db: db, logger: logger}
+}
+
+// GetAllAuthors returns a page of authors sorted by name. A non empty name
+// only returns the authors whose name contains it.
+func (s *AuthorService) GetAllAuthors(name string, page, limit int) ([]*models.Author, int64, error) {
+	db := s.db.Model(&models.Author{})
+	if name != "" {
+		db = db.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(name)+"%")
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting authors", "error", err)
+		return nil, 0, fmt.Errorf("error while counting authors : %w", err)
+	}
+
+	var authors []*models.Author
+	offset := (page - 1) * limit
+	if err := db.Order("name COLLATE NOCASE, id").Limit(limit).Offset(offset).Find(&authors).Error; err != nil {
+		s.logger.Error("error fetching authors", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching authors : %w", err)
+	}
+	s.logger.Info("fetched authors", "count", len(authors), "total", total, "page", page, "limit", limit)
+	return authors, total, nil
+}
+
+func (s *AuthorService) GetAuthor(id uint) (*models.Author, error) {
+	var author models.Author
+	if err := s.db.First(&author, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("author not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrAuthorNotFound, id)
+		}
+		s.logger.Error("error fetching author", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the author : %w", err)
+	}
+	s.logger.Info("fetched author", "author", author)
+	return &author, nil
+}
+
+func (s *AuthorService) CreateAuthor(author *models.Author) (*models.Author, error) {
+	author.Name = models.NormalizeName(author.Name)
+	if err := s.checkName(author); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(author).Error; err != nil {
+		s.logger.Error("failed to create new author", "error", err)
+		return nil, fmt.Errorf("failed to create new author : %w", err)
+	}
+	s.logger.Info("created new author", "author", author)
+	return author, nil
+}
+
+// UpdateAuthor renames the author identified by payload.ID.
+func (s *AuthorService) UpdateAuthor(payload *models.Author) (*models.Author, error) {
+	author, err := s.GetAuthor(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	author.Name = models.NormalizeName(payload.Name)
+	if err := s.checkName(author); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(author).Error; err != nil {
+		s.logger.Error("error saving updated author", "author", author, "error", err)
+		return nil, fmt.Errorf("error while saving the author : %w", err)
+	}
+	s.logger.Info("updated author", "author", author)
+	return author, nil
+}
+
+// DeleteAuthor deletes an author that isn't credited on any book of any
+// tenant, trashed books included.
+func (s *AuthorService) DeleteAuthor(id uint) (*models.Author, error) {
+	author, err := s.GetAuthor(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := allTenants(s.db).Model(&models.BookAuthor{}).Where("author_id = ?", id).Count(&count).Error; err != nil {
+		s.logger.Error("error counting author credits", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the author : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("author to delete is credited on books", "id", id, "books", count)
+		return nil, fmt.Errorf("%w: %d books", ErrAuthorInUse, count)
+	}
+
+	if err := s.db.Delete(author).Error; err != nil {
+		s.logger.Error("error deleting author", "author", author, "error", err)
+		return nil, fmt.Errorf("error while deleting the author : %w", err)
+	}
+	s.logger.Info("deleted author", "author", author)
+	return author, nil
+}
+
+// checkName makes sure no other author has the name of author, ignoring case.
+func (s *AuthorService) checkName(author *models.Author) error {
+	var count int64
+	err := s.db.Model(&models.Author{}).
+		Where("name = ? COLLATE NOCASE AND id <> ?", author.Name, author.ID).
+		Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking author name", "name", author.Name, "error", err)
+		return fmt.Errorf("error while checking the author name : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("author already exists", "name", author.Name)
+		return fmt.Errorf("%w: %s", ErrDuplicateAuthor, author.Name)
+	}
+	return nil
+}
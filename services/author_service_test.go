@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestSplitAuthors(t *testing.T) {
+	for line, want := range map[string][]string{
+		"Dan Brown":                      {"Dan Brown"},
+		"  Dan   Brown ":                 {"Dan Brown"},
+		"Brown, Dan":                     {"Dan Brown"},
+		"Tolkien, J. R. R.":              {"J. R. R. Tolkien"},
+		"Dan Brown and Tom Smith":        {"Dan Brown", "Tom Smith"},
+		"Dan Brown, Tom Smith & Ann Lee": {"Dan Brown", "Tom Smith", "Ann Lee"},
+		"Brown, Dan; Smith, Tom":         {"Dan Brown", "Tom Smith"},
+		"Dan Brown AND dan brown":        {"Dan Brown"},
+		"":                               nil,
+	} {
+		assert.Equal(t, want, models.SplitAuthors(line), line)
+	}
+}
+
+func TestBookCredits(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	authorService := NewAuthorService(service.db, service.logger)
+
+	t.Run("derived from the author line", func(t *testing.T) {
+		book, err := service.GetBook(1)
+		assert.NoError(t, err)
+		assert.Len(t, book.Authors, 1)
+		assert.Equal(t, "Author A", book.Authors[0].Author.Name)
+		assert.Equal(t, models.RoleAuthor, book.Authors[0].Role)
+
+		book, err = service.CreateBook(&models.Book{Title: "Book Four", Author: "A, Author and Author D", Year: 2024})
+		assert.NoError(t, err)
+		assert.Len(t, book.Authors, 2)
+		assert.Equal(t, book.Authors[0].AuthorID, uint(1))
+		assert.Equal(t, "Author D", book.Authors[1].Author.Name)
+		assert.Equal(t, 2, book.Authors[1].Position)
+	})
+
+	t.Run("given explicitly", func(t *testing.T) {
+		translator, _ := authorService.CreateAuthor(&models.Author{Name: "Ann Lee"})
+
+		book, err := service.CreateBook(&models.Book{Title: "Book Five", Author: "Author B", Year: 2024, Authors: []models.BookAuthor{
+			{AuthorID: 2},
+			{AuthorID: translator.ID, Role: models.RoleTranslator},
+		}})
+		assert.NoError(t, err)
+
+		fetched, _ := service.GetBook(book.ID)
+		assert.Len(t, fetched.Authors, 2)
+		assert.Equal(t, "Author B", fetched.Authors[0].Author.Name)
+		assert.Equal(t, models.RoleAuthor, fetched.Authors[0].Role)
+		assert.Equal(t, "Ann Lee", fetched.Authors[1].Author.Name)
+		assert.Equal(t, models.RoleTranslator, fetched.Authors[1].Role)
+
+		books, total, err := service.GetAllBooks(BookQuery{Page: 1, Limit: 10, AuthorID: translator.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, "Book Five", books[0].Title)
+		assert.Len(t, books[0].Authors, 2)
+	})
+
+	t.Run("replaced on update", func(t *testing.T) {
+		book, err := service.UpdateBook(&models.Book{Model: gorm.Model{ID: 2}, Title: "Book Two", Author: "Author B", Year: 2022, Authors: []models.BookAuthor{
+			{AuthorID: 2, Role: models.RoleEditor},
+		}})
+		assert.NoError(t, err)
+		assert.Len(t, book.Authors, 1)
+
+		fetched, _ := service.GetBook(2)
+		assert.Len(t, fetched.Authors, 1)
+		assert.Equal(t, models.RoleEditor, fetched.Authors[0].Role)
+	})
+
+	t.Run("must be valid", func(t *testing.T) {
+		_, err := service.CreateBook(&models.Book{Title: "Book Six", Author: "Nobody", Year: 2024, Authors: []models.BookAuthor{{AuthorID: 99}}})
+		assert.ErrorIs(t, err, ErrUnknownAuthor)
+
+		_, err = service.CreateBook(&models.Book{Title: "Book Six", Author: "Author A", Year: 2024, Authors: []models.BookAuthor{{AuthorID: 1}, {AuthorID: 1}}})
+		assert.ErrorIs(t, err, ErrDuplicateCredit)
+
+		_, total, _ := service.GetAllBooks(BookQuery{Page: 1, Limit: 10, TitleContains: "Book Six"})
+		assert.Equal(t, int64(0), total)
+	})
+
+}
+
+func TestAuthors(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	authorService := NewAuthorService(service.db, service.logger)
+
+	t.Run("listing", func(t *testing.T) {
+		authors, total, err := authorService.GetAllAuthors("", 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, "Author A", authors[0].Name)
+
+		authors, total, _ = authorService.GetAllAuthors("or c", 1, 10)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, "Author C", authors[0].Name)
+	})
+
+	t.Run("names are unique", func(t *testing.T) {
+		_, err := authorService.CreateAuthor(&models.Author{Name: " author  a"})
+		assert.ErrorIs(t, err, ErrDuplicateAuthor)
+
+		_, err = authorService.UpdateAuthor(&models.Author{Model: gorm.Model{ID: 2}, Name: "AUTHOR C"})
+		assert.ErrorIs(t, err, ErrDuplicateAuthor)
+
+		author, err := authorService.UpdateAuthor(&models.Author{Model: gorm.Model{ID: 2}, Name: "Author Bee"})
+		assert.NoError(t, err)
+		assert.Equal(t, "Author Bee", author.Name)
+
+		book, _ := service.GetBook(2)
+		assert.Equal(t, "Author Bee", book.Authors[0].Author.Name)
+	})
+
+	t.Run("credited authors can't be deleted", func(t *testing.T) {
+		_, err := authorService.DeleteAuthor(1)
+		assert.ErrorIs(t, err, ErrAuthorInUse)
+
+		_, err = service.PurgeBook(1, 0)
+		assert.NoError(t, err)
+
+		_, err = authorService.DeleteAuthor(1)
+		assert.NoError(t, err)
+
+		_, err = authorService.GetAuthor(1)
+		assert.ErrorIs(t, err, ErrAuthorNotFound)
+	})
+
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nsltharaka/booksapi/models"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSort = errors.New("invalid sort field")
+)
+
+// sortableColumns maps the sort keys accepted by the API to book columns.
+var sortableColumns = map[string]string{
+	"id":         "id",
+	"title":      "title",
+	"author":     "author",
+	"year":       "year",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// BookQuery describes a page of the book list along with the filters and
+// sort order applied to it.
+type BookQuery struct {
+	Page          int         `query:"-"`
+	Limit         int         `query:"-"`
+	Author        string      `query:"author" validate:"omitempty,max=255"`
+	AuthorID      uint        `query:"author_id"`
+	TitleContains string      `query:"title_contains" validate:"omitempty,max=255"`
+	YearFrom      int         `query:"year_from" validate:"omitempty,min=0"`
+	YearTo        int         `query:"year_to" validate:"omitempty,min=0,gtefield=YearFrom"`
+	Publisher     string      `query:"publisher" validate:"omitempty,max=255"`
+	PublisherID   uint        `query:"publisher_id"`
+	Format        string      `query:"book_format" validate:"omitempty,oneof=hardcover paperback ebook audiobook"`
+	Language      string      `query:"language" validate:"omitempty,bcp47_language_tag"`
+	PublishedFrom string      `query:"published_from" validate:"omitempty,datetime=2006-01-02"`
+	PublishedTo   string      `query:"published_to" validate:"omitempty,datetime=2006-01-02"`
+	PagesMin      int         `query:"pages_min" validate:"omitempty,min=1"`
+	PagesMax      int         `query:"pages_max" validate:"omitempty,min=1,gtefield=PagesMin"`
+	Subject       uint        `query:"subject"`
+	Sort          []SortField `query:"-"`
+}
+
+// ParseSort parses a comma separated sort expression such as "-year,title",
+// where a leading "-" sorts that field in descending order.
+func ParseSort(sort string) ([]SortField, error) {
+	var fields []SortField
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		field := SortField{Field: key}
+		if strings.HasPrefix(key, "-") {
+			field = SortField{Field: key[1:], Desc: true}
+		}
+
+		if _, ok := sortableColumns[field.Field]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSort, field.Field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// filters applies the filter part of the query.
+func (q BookQuery) filters(db *gorm.DB) *gorm.DB {
+	if q.Author != "" {
+		db = db.Where("author = ? COLLATE NOCASE", q.Author)
+	}
+	if q.AuthorID != 0 {
+		db = db.Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.BookAuthor{}).Select("book_id").Where("author_id = ?", q.AuthorID))
+	}
+	if q.TitleContains != "" {
+		db = db.Where(`title LIKE ? ESCAPE '\'`, "%"+escapeLike(q.TitleContains)+"%")
+	}
+	if q.YearFrom > 0 {
+		db = db.Where("year >= ?", q.YearFrom)
+	}
+	if q.YearTo > 0 {
+		db = db.Where("year <= ?", q.YearTo)
+	}
+	if q.Publisher != "" {
+		db = db.Where("publisher_id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.Publisher{}).Select("id").Where("name = ? COLLATE NOCASE", q.Publisher))
+	}
+	if q.PublisherID != 0 {
+		db = db.Where("publisher_id = ?", q.PublisherID)
+	}
+	if q.Format != "" {
+		db = db.Where("format = ?", q.Format)
+	}
+	if q.Language != "" {
+		// A language matches itself and the more specific tags it prefixes,
+		// so "en" matches "en-GB".
+		tag := q.Language
+		if parsed, err := language.Parse(tag); err == nil {
+			tag = parsed.String()
+		}
+		db = db.Where(`(language = ? COLLATE NOCASE OR language LIKE ? ESCAPE '\')`, tag, escapeLike(tag)+"-%")
+	}
+	if q.PublishedFrom != "" {
+		db = db.Where("published_on >= ?", q.PublishedFrom)
+	}
+	if q.PublishedTo != "" {
+		db = db.Where("published_on <= ?", q.PublishedTo)
+	}
+	if q.PagesMin > 0 {
+		db = db.Where("page_count >= ?", q.PagesMin)
+	}
+	if q.PagesMax > 0 {
+		db = db.Where("page_count BETWEEN 1 AND ?", q.PagesMax)
+	}
+	if q.Subject != 0 {
+		// A subject matches the books of its descendant subjects too.
+		newDB := db.Session(&gorm.Session{NewDB: true})
+		db = db.Where("id IN (?)", newDB.Model(&models.BookSubject{}).Select("book_id").Where("subject_id IN (?)", subjectTree(newDB, q.Subject)))
+	}
+	return db
+}
+
+// sortKeys returns the effective sort order of the query. The id is always
+// used as the final tie breaker so pages are stable, and since it is unique
+// any field listed after it is dropped.
+func (q BookQuery) sortKeys() []SortField {
+	var keys []SortField
+	for _, field := range q.Sort {
+		if _, ok := sortableColumns[field.Field]; !ok {
+			continue
+		}
+		keys = append(keys, field)
+		if field.Field == "id" {
+			return keys
+		}
+	}
+	return append(keys, SortField{Field: "id"})
+}
+
+// order applies the sort order of the query.
+func (q BookQuery) order(db *gorm.DB) *gorm.DB {
+	var columns []clause.OrderByColumn
+	for _, key := range q.sortKeys() {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: sortableColumns[key.Field]}, Desc: key.Desc})
+	}
+	return db.Order(clause.OrderBy{Columns: columns})
+}
+
+// orderSQL returns the sort order of the query as an ORDER BY list, for
+// places a clause can't be used, such as a window definition.
+func (q BookQuery) orderSQL() string {
+	var columns []string
+	for _, key := range q.sortKeys() {
+		column := sortableColumns[key.Field]
+		if key.Desc {
+			column += " DESC"
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -1,39 +1,126 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/nsltharaka/booksapi/models"
 	"gorm.io/gorm"
 )
 
 var (
-	ErrNotFound = errors.New("book not found")
+	ErrNotFound          = errors.New("book not found")
+	ErrNotInTrash        = errors.New("book not found in trash")
+	ErrVersionMismatch   = errors.New("book has been modified")
+	ErrInvalidQuery      = errors.New("invalid search query")
+	ErrSearchUnavailable = errors.New("full-text search is not available")
+	ErrInvalidISBN       = errors.New("invalid isbn")
+	ErrDuplicateISBN     = errors.New("isbn is already in use")
+	ErrISBNRequired      = errors.New("isbn is required")
+	ErrHasLoans          = errors.New("book has loan history")
 )
 
 type IBookService interface {
-	GetAllBooks(page, limit int) ([]*models.Book, error)
+	ForTenant(tenant *models.Tenant) IBookService
+	GetAllBooks(query BookQuery) ([]*models.Book, int64, error)
+	GetBooksAfter(query BookQuery, cursor string) ([]*models.Book, string, error)
+	GetBooksByWork(query BookQuery) ([]*models.Work, int64, error)
+	GetSubjectCounts(query BookQuery) ([]*models.SubjectCount, error)
+	ExportBooks(query BookQuery, fn func(book *models.Book) error) error
+	SearchBooks(query string, page, limit int) ([]*models.BookSearchResult, error)
 	GetBook(id uint) (*models.Book, error)
+	GetBookByISBN(isbn string) (*models.Book, error)
+	GetSeriesNeighbors(book *models.Book) (previous, next uint, err error)
 	CreateBook(book *models.Book) (*models.Book, error)
 	UpdateBook(payload *models.Book) (*models.Book, error)
-	DeleteBook(id uint) (*models.Book, error)
+	PatchBook(id, version uint, patch func(book *models.Book) error) (*models.Book, error)
+	DeleteBook(id, version uint) (*models.Book, error)
+	GetTrashedBooks(page, limit int) ([]*models.Book, int64, error)
+	RestoreBook(id uint) (*models.Book, error)
+	PurgeBook(id, version uint) (*models.Book, error)
+	BulkWrite(ops []BulkOperation, atomic bool) ([]BulkResult, error)
+	ImportBooks(books []*models.Book, dryRun bool) ([]ImportResult, error)
 }
 
 var _ IBookService = (*BookService)(nil)
 
+// BookService manages the books of every tenant, unless it was returned by
+// ForTenant.
 type BookService struct {
 	db     *gorm.DB
 	logger *slog.Logger
+	tenant *models.Tenant
 }
 
 func NewBookService(db *gorm.DB, logger *slog.Logger) *BookService {
 	return &BookService{db: db, logger: logger}
 }
 
+// ForTenant returns a service that only reads and writes the books of tenant,
+// every query on the books table being scoped to it, and that applies the
+// settings of tenant to the books it creates and updates.
+func (s *BookService) ForTenant(tenant *models.Tenant) IBookService {
+	return &BookService{
+		db:     scopeTenant(s.db, tenant),
+		logger: s.logger.With("tenant", tenant.ID),
+		tenant: tenant,
+	}
+}
+
+// withDB returns a service for the same tenant working with db, eg: within a
+// transaction.
+func (s *BookService) withDB(db *gorm.DB) *BookService {
+	return &BookService{db: db, logger: s.logger, tenant: s.tenant}
+}
+
 func (s *BookService) CreateBook(book *models.Book) (*models.Book, error) {
-	if err := s.db.Create(book).Error; err != nil {
+	book.Version = 1
+	if s.tenant != nil {
+		book.TenantID = s.tenant.ID
+		if book.Language == "" {
+			book.Language = s.tenant.Settings.DefaultLanguage
+		}
+	}
+	if err := s.checkISBN(book); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := describeBook(book); err != nil {
+			return err
+		}
+		if err := resolvePublisher(tx, book); err != nil {
+			return err
+		}
+		if err := creditAuthors(tx, book); err != nil {
+			return err
+		}
+		if err := assignSubjects(tx, book); err != nil {
+			return err
+		}
+		if err := checkSeries(tx, book); err != nil {
+			return err
+		}
+		if err := assignWork(tx, book); err != nil {
+			return err
+		}
+		if err := tx.Omit("Authors", "Subjects", "Publisher").Create(book).Error; err != nil {
+			return err
+		}
+		if err := saveCredits(tx, book); err != nil {
+			return err
+		}
+		return saveSubjects(tx, book)
+	})
+	if err != nil {
+		if isInvalidBook(err) {
+			s.logger.Warn("book rejected", "book", book, "error", err)
+			return nil, err
+		}
 		s.logger.Error("failed to create new book", "error", err)
 		return nil, fmt.Errorf("failed to create new book : %w", err)
 	}
@@ -43,7 +130,7 @@ func (s *BookService) CreateBook(book *models.Book) (*models.Book, error) {
 
 func (s *BookService) GetBook(id uint) (*models.Book, error) {
 	var book models.Book
-	if err := s.db.First(&book, id).Error; err != nil {
+	if err := s.db.Scopes(withDetails).First(&book, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logger.Warn("book not found", "id", id)
 			return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
@@ -51,24 +138,257 @@ func (s *BookService) GetBook(id uint) (*models.Book, error) {
 		s.logger.Error("error fetching book", "id", id, "error", err)
 		return nil, fmt.Errorf("error while fetching the book : %w", err)
 	}
+
+	availability, err := bookAvailability(s.db, book.ID)
+	if err != nil {
+		s.logger.Error("error counting book copies", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the book : %w", err)
+	}
+	book.Availability = availability
+	s.logger.Info("fetched book", "book", book)
+	return &book, nil
+}
+
+// GetBookByISBN returns the book with the given ISBN, which may be given as an
+// ISBN-10 or an ISBN-13.
+func (s *BookService) GetBookByISBN(isbn string) (*models.Book, error) {
+	normalized, ok := models.NormalizeISBN(isbn)
+	if !ok {
+		s.logger.Warn("invalid isbn", "isbn", isbn)
+		return nil, fmt.Errorf("%w: %q", ErrInvalidISBN, isbn)
+	}
+
+	var book models.Book
+	if err := s.db.Scopes(withDetails).Where("isbn = ?", normalized).First(&book).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("book not found", "isbn", normalized)
+			return nil, fmt.Errorf("%w: isbn %s", ErrNotFound, normalized)
+		}
+		s.logger.Error("error fetching book", "isbn", normalized, "error", err)
+		return nil, fmt.Errorf("error while fetching the book : %w", err)
+	}
+
+	availability, err := bookAvailability(s.db, book.ID)
+	if err != nil {
+		s.logger.Error("error counting book copies", "isbn", normalized, "error", err)
+		return nil, fmt.Errorf("error while fetching the book : %w", err)
+	}
+	book.Availability = availability
 	s.logger.Info("fetched book", "book", book)
 	return &book, nil
 }
 
-func (s *BookService) GetAllBooks(page, limit int) ([]*models.Book, error) {
+// checkISBN stores the ISBN of book as an ISBN-13, or clears it when empty,
+// and makes sure no other book of the tenant, trashed ones included, already
+// has it. Tenants can require every book to have one.
+func (s *BookService) checkISBN(book *models.Book) error {
+	if book.ISBN == nil || *book.ISBN == "" {
+		book.ISBN = nil
+		if s.tenant != nil && s.tenant.Settings.RequireISBN {
+			s.logger.Warn("isbn is required", "id", book.ID)
+			return ErrISBNRequired
+		}
+		return nil
+	}
+
+	normalized, ok := models.NormalizeISBN(*book.ISBN)
+	if !ok {
+		s.logger.Warn("invalid isbn", "isbn", *book.ISBN)
+		return fmt.Errorf("%w: %q", ErrInvalidISBN, *book.ISBN)
+	}
+	book.ISBN = &normalized
+
+	var count int64
+	err := s.db.Unscoped().Model(&models.Book{}).
+		Where("isbn = ? AND id <> ?", normalized, book.ID).
+		Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking isbn", "isbn", normalized, "error", err)
+		return fmt.Errorf("error while checking the isbn : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("isbn is already in use", "isbn", normalized)
+		return fmt.Errorf("%w: %s", ErrDuplicateISBN, normalized)
+	}
+	return nil
+}
+
+func (s *BookService) GetAllBooks(query BookQuery) ([]*models.Book, int64, error) {
+	var total int64
+	if err := s.db.Model(&models.Book{}).Scopes(query.filters).Count(&total).Error; err != nil {
+		s.logger.Error("error counting books", "error", err)
+		return nil, 0, fmt.Errorf("error while counting books : %w", err)
+	}
+
 	var books []*models.Book
-	offset := (page - 1) * limit
-	if err := s.db.Limit(limit).Offset(offset).Find(&books).Error; err != nil {
+	offset := (query.Page - 1) * query.Limit
+	if err := s.db.Scopes(query.filters, query.order, withDetails).Limit(query.Limit).Offset(offset).Find(&books).Error; err != nil {
 		s.logger.Error("error fetching paginated books", "error", err)
-		return nil, fmt.Errorf("error while fetching books : %w", err)
+		return nil, 0, fmt.Errorf("error while fetching books : %w", err)
 	}
-	s.logger.Info("fetched paginated books", "count", len(books), "page", page, "limit", limit)
-	return books, nil
+	s.logger.Info("fetched paginated books", "count", len(books), "total", total, "page", query.Page, "limit", query.Limit)
+	return books, total, nil
 }
 
+// GetBooksAfter returns the page of books that follows the position encoded in
+// cursor, or the first page when cursor is empty, along with the cursor of the
+// next page. The next cursor is empty on the last page. Unlike GetAllBooks the
+// page is located with a keyset condition instead of an offset.
+func (s *BookService) GetBooksAfter(query BookQuery, cursor string) ([]*models.Book, string, error) {
+	keys := query.sortKeys()
+
+	db := s.db.Scopes(query.filters, query.order, withDetails)
+	if cursor != "" {
+		values, err := decodeCursor(keys, cursor)
+		if err != nil {
+			s.logger.Warn("rejected cursor", "cursor", cursor, "error", err)
+			return nil, "", err
+		}
+		db = db.Scopes(after(keys, values))
+	}
+
+	var books []*models.Book
+	if err := db.Limit(query.Limit + 1).Find(&books).Error; err != nil {
+		s.logger.Error("error fetching books after cursor", "error", err)
+		return nil, "", fmt.Errorf("error while fetching books : %w", err)
+	}
+
+	var next string
+	if len(books) > query.Limit {
+		books = books[:query.Limit]
+		next = encodeCursor(keys, books[len(books)-1])
+	}
+	s.logger.Info("fetched books after cursor", "count", len(books), "limit", query.Limit)
+	return books, next, nil
+}
+
+// exportBatchSize is how many exported books have their details loaded at
+// once.
+const exportBatchSize = 500
+
+// ExportBooks calls fn for every book matching the filters of query, in its
+// sort order, ignoring the page. Books are read in batches of exportBatchSize
+// rows, the details of a batch being loaded with a query per kind of detail,
+// so the catalog is never held in memory. An error returned by fn stops the
+// export and is returned.
+func (s *BookService) ExportBooks(query BookQuery, fn func(book *models.Book) error) error {
+	rows, err := s.db.Model(&models.Book{}).Scopes(query.filters, query.order).Rows()
+	if err != nil {
+		s.logger.Error("error exporting books", "error", err)
+		return fmt.Errorf("error while exporting books : %w", err)
+	}
+	defer rows.Close()
+
+	count := 0
+	batch := make([]*models.Book, 0, exportBatchSize)
+	// flush loads the details of the batch at once, then hands its books to fn
+	flush := func() error {
+		if err := loadDetails(s.db, batch); err != nil {
+			s.logger.Error("error reading exported books", "count", count, "error", err)
+			return fmt.Errorf("error while exporting books : %w", err)
+		}
+		for _, book := range batch {
+			if err := fn(book); err != nil {
+				s.logger.Warn("export stopped", "count", count, "error", err)
+				return err
+			}
+			count++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for rows.Next() {
+		var book models.Book
+		if err := s.db.ScanRows(rows, &book); err != nil {
+			s.logger.Error("error reading exported book", "error", err)
+			return fmt.Errorf("error while exporting books : %w", err)
+		}
+		batch = append(batch, &book)
+		if len(batch) == exportBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("error exporting books", "count", count, "error", err)
+		return fmt.Errorf("error while exporting books : %w", err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	s.logger.Info("exported books", "count", count)
+	return nil
+}
+
+func (s *BookService) SearchBooks(query string, page, limit int) ([]*models.BookSearchResult, error) {
+	match := ftsMatchExpression(query)
+	if match == "" {
+		return nil, ErrInvalidQuery
+	}
+
+	matches := s.db.Table("books_fts").
+		Select("books.*, -bm25(books_fts) AS score, snippet(books_fts, -1, '<mark>', '</mark>', '…', 12) AS snippet").
+		Joins("JOIN books ON books.id = books_fts.rowid").
+		Where("books_fts MATCH ?", match).
+		Where("books.deleted_at IS NULL")
+	if s.tenant != nil {
+		// the scope only applies to queries on the books table itself
+		matches = matches.Where("books.tenant_id = ?", s.tenant.ID)
+	}
+
+	// results are collapsed by work, keeping the best matching edition
+	ranked := s.db.Table("(?) AS matches", matches).
+		Select("*, ROW_NUMBER() OVER (PARTITION BY work_id ORDER BY score DESC, id) AS work_rank, COUNT(*) OVER (PARTITION BY work_id) AS editions")
+
+	var results []*models.BookSearchResult
+	offset := (page - 1) * limit
+	err := s.db.Table("(?) AS ranked", ranked).
+		Where("work_rank = 1").
+		Order("score DESC, id").
+		Limit(limit).Offset(offset).
+		Scan(&results).Error
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: books_fts") {
+			s.logger.Error("search index is missing", "error", err)
+			return nil, ErrSearchUnavailable
+		}
+		s.logger.Error("error searching books", "query", query, "error", err)
+		return nil, fmt.Errorf("error while searching books : %w", err)
+	}
+
+	books := make([]*models.Book, len(results))
+	for i, result := range results {
+		books[i] = &result.Book
+	}
+	if err := loadDetails(s.db, books); err != nil {
+		s.logger.Error("error loading details of search results", "query", query, "error", err)
+		return nil, fmt.Errorf("error while searching books : %w", err)
+	}
+	s.logger.Info("searched books", "query", query, "count", len(results), "page", page, "limit", limit)
+	return results, nil
+}
+
+// ftsMatchExpression turns free text into an FTS5 query where every term is
+// quoted, so user input can't inject FTS5 operators or cause syntax errors.
+// The last term is matched as a prefix to support search-as-you-type.
+func ftsMatchExpression(query string) string {
+	terms := strings.Fields(query)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// UpdateBook replaces the book identified by payload.ID. When payload.Version
+// is set the update only happens if the stored book still has that version.
 func (s *BookService) UpdateBook(payload *models.Book) (*models.Book, error) {
 	var book models.Book
-	if err := s.db.First(&book, payload.ID).Error; err != nil {
+	if err := s.db.Scopes(withDetails).First(&book, payload.ID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logger.Warn("book to update not found", "id", payload.ID)
 			return nil, fmt.Errorf("%w: id %d", ErrNotFound, payload.ID)
@@ -80,8 +400,70 @@ func (s *BookService) UpdateBook(payload *models.Book) (*models.Book, error) {
 	book.Title = payload.Title
 	book.Author = payload.Author
 	book.Year = payload.Year
+	book.ISBN = payload.ISBN
+	book.PublisherID = payload.PublisherID
+	book.Publisher = payload.Publisher
+	book.Edition = payload.Edition
+	book.Format = payload.Format
+	book.PageCount = payload.PageCount
+	book.Language = payload.Language
+	book.PublishedOn = payload.PublishedOn
+	book.Authors = payload.Authors
+	book.Subjects = payload.Subjects
+	book.SeriesID = payload.SeriesID
+	book.SeriesPosition = payload.SeriesPosition
+	if payload.WorkID != nil {
+		book.WorkID = payload.WorkID
+	}
+
+	if payload.Version != 0 && payload.Version != book.Version {
+		s.logger.Warn("book to update has been modified", "id", book.ID, "version", book.Version, "expected", payload.Version)
+		return nil, ErrVersionMismatch
+	}
 
-	if err := s.db.Save(&book).Error; err != nil {
+	if err := s.checkISBN(&book); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := describeBook(&book); err != nil {
+			return err
+		}
+		if err := resolvePublisher(tx, &book); err != nil {
+			return err
+		}
+		if err := creditAuthors(tx, &book); err != nil {
+			return err
+		}
+		if err := assignSubjects(tx, &book); err != nil {
+			return err
+		}
+		if err := checkSeries(tx, &book); err != nil {
+			return err
+		}
+		if err := assignWork(tx, &book); err != nil {
+			return err
+		}
+		if err := saveVersioned(tx, &book); err != nil {
+			return err
+		}
+		if err := saveCredits(tx, &book); err != nil {
+			return err
+		}
+		if err := saveSubjects(tx, &book); err != nil {
+			return err
+		}
+		return pruneWorks(tx)
+	})
+	if err != nil {
+		if errors.Is(err, ErrVersionMismatch) {
+			s.logger.Warn("book to update has been modified", "id", book.ID, "version", book.Version)
+			return nil, err
+		}
+		if isInvalidBook(err) {
+			s.logger.Warn("book rejected", "book", book, "error", err)
+			return nil, err
+		}
 		s.logger.Error("error saving updated book", "book", book, "error", err)
 		return nil, fmt.Errorf("error while saving the book : %w", err)
 	}
@@ -89,9 +471,81 @@ func (s *BookService) UpdateBook(payload *models.Book) (*models.Book, error) {
 	return &book, nil
 }
 
-func (s *BookService) DeleteBook(id uint) (*models.Book, error) {
+// PatchBook loads the book, lets patch modify it and saves the result within a
+// single transaction. An error returned by patch aborts the update and is
+// returned unchanged. A non zero version must match the stored version.
+func (s *BookService) PatchBook(id, version uint, patch func(book *models.Book) error) (*models.Book, error) {
 	var book models.Book
-	if err := s.db.First(&book, id).Error; err != nil {
+	var patchErr error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(withDetails).First(&book, id).Error; err != nil {
+			return err
+		}
+		if version != 0 && version != book.Version {
+			return ErrVersionMismatch
+		}
+		if patchErr = patch(&book); patchErr != nil {
+			return patchErr
+		}
+		if patchErr = s.withDB(tx).checkISBN(&book); patchErr != nil {
+			return patchErr
+		}
+		if err := describeBook(&book); err != nil {
+			return err
+		}
+		if err := resolvePublisher(tx, &book); err != nil {
+			return err
+		}
+		if err := creditAuthors(tx, &book); err != nil {
+			return err
+		}
+		if err := assignSubjects(tx, &book); err != nil {
+			return err
+		}
+		if err := checkSeries(tx, &book); err != nil {
+			return err
+		}
+		if err := assignWork(tx, &book); err != nil {
+			return err
+		}
+		if err := saveVersioned(tx, &book); err != nil {
+			return err
+		}
+		if err := saveCredits(tx, &book); err != nil {
+			return err
+		}
+		if err := saveSubjects(tx, &book); err != nil {
+			return err
+		}
+		return pruneWorks(tx)
+	})
+	if err != nil {
+		switch {
+		case patchErr != nil:
+			s.logger.Warn("patch rejected", "id", id, "error", patchErr)
+			return nil, patchErr
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			s.logger.Warn("book to patch not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
+		case errors.Is(err, ErrVersionMismatch):
+			s.logger.Warn("book to patch has been modified", "id", id, "version", book.Version, "expected", version)
+			return nil, err
+		case isInvalidBook(err):
+			s.logger.Warn("book rejected", "id", id, "error", err)
+			return nil, err
+		}
+		s.logger.Error("error patching book", "id", id, "error", err)
+		return nil, fmt.Errorf("error while patching the book : %w", err)
+	}
+	s.logger.Info("patched book", "book", book)
+	return &book, nil
+}
+
+// DeleteBook deletes the book. A non zero version must match the stored
+// version.
+func (s *BookService) DeleteBook(id, version uint) (*models.Book, error) {
+	var book models.Book
+	if err := s.db.Scopes(withDetails).First(&book, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			s.logger.Warn("book to delete not found", "id", id)
 			return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
@@ -100,10 +554,269 @@ func (s *BookService) DeleteBook(id uint) (*models.Book, error) {
 		return nil, fmt.Errorf("error while fetching the book : %w", err)
 	}
 
-	if err := s.db.Delete(&book).Error; err != nil {
-		s.logger.Error("error deleting book", "book", book, "error", err)
-		return nil, fmt.Errorf("error while deleting the book : %w", err)
+	db := s.db
+	if version != 0 {
+		db = db.Where("version = ?", version)
+	}
+
+	result := db.Delete(&book)
+	if result.Error != nil {
+		s.logger.Error("error deleting book", "book", book, "error", result.Error)
+		return nil, fmt.Errorf("error while deleting the book : %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		s.logger.Warn("book to delete has been modified", "id", id, "version", book.Version, "expected", version)
+		return nil, ErrVersionMismatch
 	}
 	s.logger.Info("deleted book", "book", book)
 	return &book, nil
 }
+
+// isInvalidBook reports whether err rejects the details of a book, as opposed
+// to failing to save it.
+func isInvalidBook(err error) bool {
+	for _, target := range []error{ErrUnknownAuthor, ErrDuplicateCredit, ErrUnknownPublisher, ErrYearMismatch, ErrInvalidLanguage, ErrUnknownWork, ErrUnknownSubject, ErrUnknownSeries} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDetails loads the credits, the subjects and the publisher of the books.
+func withDetails(db *gorm.DB) *gorm.DB {
+	return db.Scopes(withAuthors, withSubjects).Preload("Publisher")
+}
+
+// loadDetails loads the credits, the subjects and the publisher of books that
+// were read without withDetails.
+func loadDetails(db *gorm.DB, books []*models.Book) error {
+	if err := loadAuthors(db, books); err != nil {
+		return err
+	}
+	if err := loadSubjects(db, books); err != nil {
+		return err
+	}
+
+	var ids []uint
+	for _, book := range books {
+		if book.PublisherID != nil {
+			ids = append(ids, *book.PublisherID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var found []*models.Publisher
+	if err := db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return err
+	}
+	publishers := make(map[uint]*models.Publisher, len(found))
+	for _, publisher := range found {
+		publishers[publisher.ID] = publisher
+	}
+	for _, book := range books {
+		if book.PublisherID != nil {
+			book.Publisher = publishers[*book.PublisherID]
+		}
+	}
+	return nil
+}
+
+// saveVersioned writes every field of book and bumps its version, as long as
+// the stored version is still the one book was read with. The version check
+// is part of the UPDATE statement so concurrent writers can't both succeed.
+func saveVersioned(db *gorm.DB, book *models.Book) error {
+	current := book.Version
+	book.Version++
+
+	result := db.Model(book).
+		Where("version = ?", current).
+		Select("*").Omit("id", "created_at", "deleted_at", "Authors", "Subjects", "Publisher").
+		Updates(book)
+	if result.Error != nil {
+		book.Version = current
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		book.Version = current
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func (s *BookService) GetTrashedBooks(page, limit int) ([]*models.Book, int64, error) {
+	trash := s.db.Unscoped().Model(&models.Book{}).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := trash.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting trashed books", "error", err)
+		return nil, 0, fmt.Errorf("error while counting trashed books : %w", err)
+	}
+
+	var books []*models.Book
+	offset := (page - 1) * limit
+	if err := trash.Scopes(withDetails).Order("deleted_at DESC, id").Limit(limit).Offset(offset).Find(&books).Error; err != nil {
+		s.logger.Error("error fetching trashed books", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching trashed books : %w", err)
+	}
+	s.logger.Info("fetched trashed books", "count", len(books), "total", total, "page", page, "limit", limit)
+	return books, total, nil
+}
+
+// RestoreBook moves a deleted book out of the trash and bumps its version.
+func (s *BookService) RestoreBook(id uint) (*models.Book, error) {
+	var book models.Book
+	if err := s.db.Unscoped().Scopes(withDetails).Where("deleted_at IS NOT NULL").First(&book, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("book to restore not found in trash", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrNotInTrash, id)
+		}
+		s.logger.Error("error fetching book for restore", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the book : %w", err)
+	}
+
+	err := s.db.Unscoped().Model(&book).Updates(map[string]any{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		s.logger.Error("error restoring book", "book", book, "error", err)
+		return nil, fmt.Errorf("error while restoring the book : %w", err)
+	}
+
+	book.DeletedAt = gorm.DeletedAt{}
+	book.Version++
+	s.logger.Info("restored book", "book", book)
+	return &book, nil
+}
+
+// PurgeBook permanently deletes a book, whether it is in the trash or not. A
+// non zero version must match the stored version.
+func (s *BookService) PurgeBook(id, version uint) (*models.Book, error) {
+	var book models.Book
+	if err := s.db.Unscoped().Scopes(withDetails).First(&book, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("book to purge not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrNotFound, id)
+		}
+		s.logger.Error("error fetching book for purge", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the book : %w", err)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var loans int64
+		if err := tx.Unscoped().Model(&models.Loan{}).Where("copy_id IN (?)", bookCopies(tx, book.ID)).Count(&loans).Error; err != nil {
+			return err
+		}
+		if loans > 0 {
+			return ErrHasLoans
+		}
+
+		db := tx.Unscoped()
+		if version != 0 {
+			db = db.Where("version = ?", version)
+		}
+
+		result := db.Delete(&book)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionMismatch
+		}
+		if err := tx.Where("book_id = ?", book.ID).Delete(&models.BookAuthor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("book_id = ?", book.ID).Delete(&models.BookSubject{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&models.Copy{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&models.Hold{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+		return pruneWorks(tx)
+	})
+	if err != nil {
+		if errors.Is(err, ErrVersionMismatch) {
+			s.logger.Warn("book to purge has been modified", "id", id, "version", book.Version, "expected", version)
+			return nil, err
+		}
+		if errors.Is(err, ErrHasLoans) {
+			s.logger.Warn("book to purge has loan history", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrHasLoans, id)
+		}
+		s.logger.Error("error purging book", "book", book, "error", err)
+		return nil, fmt.Errorf("error while purging the book : %w", err)
+	}
+	s.logger.Info("purged book", "book", book)
+	return &book, nil
+}
+
+// PurgeTrash permanently deletes the books that were moved to the trash
+// before the given time and returns how many were removed. Books with loan
+// history are kept, along with the loans and fines of their copies.
+func (s *BookService) PurgeTrash(before time.Time) (int64, error) {
+	var count int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		lent := tx.Unscoped().Model(&models.Copy{}).Select("book_id").Where("id IN (?)", tx.Unscoped().Model(&models.Loan{}).Select("copy_id"))
+		expired := tx.Unscoped().Model(&models.Book{}).Select("id").Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Where("id NOT IN (?)", lent)
+		if err := tx.Where("book_id IN (?)", expired).Delete(&models.BookAuthor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("book_id IN (?)", expired).Delete(&models.BookSubject{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("book_id IN (?)", expired).Delete(&models.Copy{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("book_id IN (?)", expired).Delete(&models.Hold{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("book_id IN (?)", expired).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("id IN (?)", expired).Delete(&models.Book{})
+		if result.Error != nil {
+			return result.Error
+		}
+		count = result.RowsAffected
+		return pruneWorks(tx)
+	})
+	if err != nil {
+		s.logger.Error("error purging trash", "before", before, "error", err)
+		return 0, fmt.Errorf("error while purging the trash : %w", err)
+	}
+	s.logger.Info("purged trash", "before", before, "count", count)
+	return count, nil
+}
+
+// bookCopies selects the IDs of the copies of a book, including the removed
+// ones.
+func bookCopies(db *gorm.DB, bookID uint) *gorm.DB {
+	return db.Unscoped().Model(&models.Copy{}).Select("id").Where("book_id = ?", bookID)
+}
+
+// RunTrashRetention purges books that have been in the trash for longer than
+// retention, once right away and then on every interval, until ctx is done.
+func (s *BookService) RunTrashRetention(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.PurgeTrash(time.Now().Add(-retention))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/nsltharaka/booksapi/database"
 	"github.com/nsltharaka/booksapi/models"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func setupTestDB(t *testing.T) (*BookService, func()) {
@@ -82,11 +86,210 @@ func TestGetBooks(t *testing.T) {
 	})
 
 	t.Run("fetching all books", func(t *testing.T) {
-		fetchedBooks, err := service.GetAllBooks(1, 10)
+		fetchedBooks, total, err := service.GetAllBooks(BookQuery{Page: 1, Limit: 10})
 		assert.NoError(t, err)
 		assert.Equal(t, 3, len(fetchedBooks))
+		assert.Equal(t, int64(3), total)
 	})
 
+	t.Run("fetching filtered books", func(t *testing.T) {
+		fetchedBooks, total, err := service.GetAllBooks(BookQuery{Page: 1, Limit: 10, Author: "author b"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Len(t, fetchedBooks, 1)
+		assert.Equal(t, "Book Two", fetchedBooks[0].Title)
+
+		fetchedBooks, _, err = service.GetAllBooks(BookQuery{Page: 1, Limit: 10, TitleContains: "t", YearFrom: 2022})
+		assert.NoError(t, err)
+		assert.Len(t, fetchedBooks, 2)
+
+		fetchedBooks, _, err = service.GetAllBooks(BookQuery{Page: 1, Limit: 10, TitleContains: "%"})
+		assert.NoError(t, err)
+		assert.Empty(t, fetchedBooks)
+	})
+
+	t.Run("counting every match, not just the page", func(t *testing.T) {
+		fetchedBooks, total, err := service.GetAllBooks(BookQuery{Page: 2, Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, fetchedBooks, 1)
+		assert.Equal(t, int64(3), total)
+	})
+
+	t.Run("fetching sorted books", func(t *testing.T) {
+		sort, err := ParseSort("-year")
+		assert.NoError(t, err)
+
+		fetchedBooks, _, err := service.GetAllBooks(BookQuery{Page: 1, Limit: 10, Sort: sort})
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Three", fetchedBooks[0].Title)
+		assert.Equal(t, "Book One", fetchedBooks[2].Title)
+	})
+
+}
+
+func TestSearchBooks(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	if _, err := service.SearchBooks("book", 1, 10); errors.Is(err, ErrSearchUnavailable) {
+		t.Skip("sqlite built without fts5, run with -tags sqlite_fts5")
+	}
+
+	t.Run("matching on title", func(t *testing.T) {
+		results, err := service.SearchBooks("two", 1, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+		assert.Equal(t, "Book Two", results[0].Title)
+		assert.Contains(t, results[0].Snippet, "<mark>Two</mark>")
+	})
+
+	t.Run("matching on author prefix", func(t *testing.T) {
+		results, err := service.SearchBooks("auth", 1, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+	})
+
+	t.Run("index follows updates and deletes", func(t *testing.T) {
+		book, _ := service.GetBook(1)
+		book.Title = "Renamed"
+		_, err := service.UpdateBook(book)
+		assert.NoError(t, err)
+
+		results, err := service.SearchBooks("renamed", 1, 10)
+		assert.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		_, err = service.DeleteBook(1, 0)
+		assert.NoError(t, err)
+
+		results, err = service.SearchBooks("renamed", 1, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("query with fts5 syntax", func(t *testing.T) {
+		_, err := service.SearchBooks(`"unbalanced AND (`, 1, 10)
+		assert.NoError(t, err)
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		_, err := service.SearchBooks("   ", 1, 10)
+		assert.ErrorIs(t, err, ErrInvalidQuery)
+	})
+
+}
+
+func TestGetBooksAfter(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	service.CreateBook(&models.Book{Title: "Book Four", Author: "Author D", Year: 2022})
+
+	sort, _ := ParseSort("-year,title")
+	query := BookQuery{Limit: 2, Sort: sort}
+
+	t.Run("walking every page", func(t *testing.T) {
+		var titles []string
+		cursor := ""
+		for {
+			books, next, err := service.GetBooksAfter(query, cursor)
+			assert.NoError(t, err)
+			for _, book := range books {
+				titles = append(titles, book.Title)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		assert.Equal(t, []string{"Book Three", "Book Four", "Book Two", "Book One"}, titles)
+	})
+
+	t.Run("rows inserted before the cursor don't shift the next page", func(t *testing.T) {
+		_, next, err := service.GetBooksAfter(query, "")
+		assert.NoError(t, err)
+
+		service.CreateBook(&models.Book{Title: "Book Zero", Author: "Author Z", Year: 2030})
+
+		books, _, err := service.GetBooksAfter(query, next)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Two", books[0].Title)
+	})
+
+	t.Run("rejecting tampered cursors", func(t *testing.T) {
+		_, next, _ := service.GetBooksAfter(query, "")
+
+		_, _, err := service.GetBooksAfter(query, next+"x")
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		_, _, err = service.GetBooksAfter(BookQuery{Limit: 2}, next)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("sorting by timestamps", func(t *testing.T) {
+		sort, _ := ParseSort("-created_at")
+		books, next, err := service.GetBooksAfter(BookQuery{Limit: 2, Sort: sort}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Zero", books[0].Title)
+
+		books, _, err = service.GetBooksAfter(BookQuery{Limit: 2, Sort: sort}, next)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Three", books[0].Title)
+	})
+}
+
+func TestExportBooks(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	service.DeleteBook(1, 0)
+	sort, _ := ParseSort("-year")
+
+	var titles []string
+	err := service.ExportBooks(BookQuery{Page: 1, Limit: 1, Sort: sort}, func(book *models.Book) error {
+		titles = append(titles, book.Title)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Book Three", "Book Two"}, titles)
+
+	stop := errors.New("stop")
+	err = service.ExportBooks(BookQuery{}, func(book *models.Book) error { return stop })
+	assert.Equal(t, stop, err)
+
+	t.Run("details are loaded a batch at a time", func(t *testing.T) {
+		for i := range 20 {
+			_, err := service.CreateBook(&models.Book{Title: fmt.Sprintf("Book %d", i+4), Author: "Author D & Author E", Year: 2024, Publisher: &models.Publisher{Name: "Penguin"}})
+			assert.NoError(t, err)
+		}
+
+		queries := 0
+		service.db.Callback().Query().After("gorm:query").Register("count_export_queries", func(*gorm.DB) { queries++ })
+		t.Cleanup(func() { service.db.Callback().Query().Remove("count_export_queries") })
+
+		exported := 0
+		err := service.ExportBooks(BookQuery{}, func(book *models.Book) error {
+			if book.Year == 2024 {
+				assert.Len(t, book.Authors, 2)
+				assert.Equal(t, "Penguin", book.Publisher.Name)
+			}
+			exported++
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 22, exported)
+		assert.NotZero(t, queries)
+		assert.Less(t, queries, 10)
+	})
+}
+
+func TestParseSort(t *testing.T) {
+	fields, err := ParseSort("-year, title")
+	assert.NoError(t, err)
+	assert.Equal(t, []SortField{{Field: "year", Desc: true}, {Field: "title"}}, fields)
+
+	_, err = ParseSort("year;drop table books")
+	assert.ErrorIs(t, err, ErrInvalidSort)
 }
 
 func TestUpdateBook(t *testing.T) {
@@ -112,6 +315,323 @@ func TestUpdateBook(t *testing.T) {
 
 }
 
+func TestPatchBook(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	t.Run("patching an existing book", func(t *testing.T) {
+		patchedBook, err := service.PatchBook(2, 0, func(book *models.Book) error {
+			book.Year = 1999
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1999, patchedBook.Year)
+
+		fetchedBook, _ := service.GetBook(2)
+		assert.Equal(t, "Book Two", fetchedBook.Title)
+		assert.Equal(t, 1999, fetchedBook.Year)
+	})
+
+	t.Run("rejected patch leaves the book untouched", func(t *testing.T) {
+		rejected := errors.New("rejected")
+		_, err := service.PatchBook(1, 0, func(book *models.Book) error {
+			book.Title = "Changed"
+			return rejected
+		})
+		assert.Equal(t, rejected, err)
+
+		fetchedBook, _ := service.GetBook(1)
+		assert.Equal(t, "Book One", fetchedBook.Title)
+	})
+
+	t.Run("patching non-existing book", func(t *testing.T) {
+		patchedBook, err := service.PatchBook(99, 0, func(book *models.Book) error { return nil })
+		assert.Nil(t, patchedBook)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+}
+
+func TestVersioning(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	t.Run("new books start at version 1", func(t *testing.T) {
+		createdBook, err := service.CreateBook(&models.Book{Title: "Versioned", Author: "Author", Year: 2020, Version: 9})
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), createdBook.Version)
+	})
+
+	t.Run("every update bumps the version", func(t *testing.T) {
+		updatedBook, err := service.UpdateBook(&models.Book{Model: gorm.Model{ID: 1}, Title: "One", Author: "A", Year: 2001})
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), updatedBook.Version)
+
+		patchedBook, err := service.PatchBook(1, 2, func(book *models.Book) error { return nil })
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), patchedBook.Version)
+
+		fetchedBook, _ := service.GetBook(1)
+		assert.Equal(t, uint(3), fetchedBook.Version)
+	})
+
+	t.Run("stale versions are rejected", func(t *testing.T) {
+		_, err := service.UpdateBook(&models.Book{Model: gorm.Model{ID: 1}, Title: "Stale", Author: "A", Year: 2001, Version: 2})
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		_, err = service.PatchBook(1, 2, func(book *models.Book) error { return nil })
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		_, err = service.DeleteBook(1, 2)
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		fetchedBook, _ := service.GetBook(1)
+		assert.Equal(t, "One", fetchedBook.Title)
+	})
+
+	t.Run("a concurrent writer loses the race", func(t *testing.T) {
+		first, _ := service.GetBook(2)
+		second, _ := service.GetBook(2)
+
+		first.Title = "First"
+		assert.NoError(t, saveVersioned(service.db, first))
+
+		second.Title = "Second"
+		assert.ErrorIs(t, saveVersioned(service.db, second), ErrVersionMismatch)
+
+		fetchedBook, _ := service.GetBook(2)
+		assert.Equal(t, "First", fetchedBook.Title)
+	})
+
+}
+
+func TestTrash(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	service.DeleteBook(1, 0)
+	service.DeleteBook(2, 0)
+
+	t.Run("listing trashed books", func(t *testing.T) {
+		books, total, err := service.GetTrashedBooks(1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Len(t, books, 2)
+		for _, book := range books {
+			assert.True(t, book.DeletedAt.Valid)
+		}
+	})
+
+	t.Run("restoring a trashed book", func(t *testing.T) {
+		restoredBook, err := service.RestoreBook(1)
+		assert.NoError(t, err)
+		assert.False(t, restoredBook.DeletedAt.Valid)
+		assert.Equal(t, uint(2), restoredBook.Version)
+
+		fetchedBook, err := service.GetBook(1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book One", fetchedBook.Title)
+	})
+
+	t.Run("restoring a book that is not in the trash", func(t *testing.T) {
+		_, err := service.RestoreBook(3)
+		assert.ErrorIs(t, err, ErrNotInTrash)
+
+		_, err = service.RestoreBook(99)
+		assert.ErrorIs(t, err, ErrNotInTrash)
+	})
+
+	t.Run("purging a book", func(t *testing.T) {
+		_, err := service.PurgeBook(3, 5)
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		purgedBook, err := service.PurgeBook(3, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Three", purgedBook.Title)
+
+		_, err = service.RestoreBook(3)
+		assert.ErrorIs(t, err, ErrNotInTrash)
+	})
+
+	t.Run("purging books trashed before a given time", func(t *testing.T) {
+		count, err := service.PurgeTrash(time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+
+		count, err = service.PurgeTrash(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		_, total, _ := service.GetTrashedBooks(1, 10)
+		assert.Zero(t, total)
+
+		_, total, _ = service.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.Equal(t, int64(1), total)
+	})
+
+}
+
+func TestBulkWrite(t *testing.T) {
+
+	ops := []BulkOperation{
+		{Op: BulkCreate, Book: &models.Book{Title: "Book Four", Author: "Author D", Year: 2024}},
+		{Op: BulkUpdate, ID: 1, Book: &models.Book{Title: "Updated", Author: "Author A", Year: 2021}},
+		{Op: BulkDelete, ID: 99},
+		{Op: BulkDelete, ID: 2, Version: 1},
+	}
+
+	t.Run("atomic mode rolls back every operation", func(t *testing.T) {
+		service, cleanup := setupTestDB(t)
+		t.Cleanup(cleanup)
+
+		results, err := service.BulkWrite(ops, true)
+		assert.ErrorIs(t, err, ErrBulkAborted)
+		assert.Len(t, results, 4)
+		assert.NoError(t, results[0].Err)
+		assert.ErrorIs(t, results[2].Err, ErrNotFound)
+
+		_, total, _ := service.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.Equal(t, int64(3), total)
+		book, _ := service.GetBook(1)
+		assert.Equal(t, "Book One", book.Title)
+	})
+
+	t.Run("partial mode only rolls back failed operations", func(t *testing.T) {
+		service, cleanup := setupTestDB(t)
+		t.Cleanup(cleanup)
+
+		results, err := service.BulkWrite(ops, false)
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.NotZero(t, results[0].Book.ID)
+		assert.NoError(t, results[1].Err)
+		assert.ErrorIs(t, results[2].Err, ErrNotFound)
+		assert.NoError(t, results[3].Err)
+
+		_, total, _ := service.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.Equal(t, int64(3), total)
+		book, _ := service.GetBook(1)
+		assert.Equal(t, "Updated", book.Title)
+		_, err = service.GetBook(2)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+}
+
+func TestImportBooks(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	books := func() []*models.Book {
+		return []*models.Book{
+			{Title: "Book Four", Author: "Author D", Year: 2024},
+			{Title: "book one", Author: "AUTHOR A", Year: 2021},
+			{Title: "Book Four", Author: "Author D", Year: 2024},
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		results, err := service.ImportBooks(books(), true)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportCreated, results[0].Status)
+		assert.Equal(t, ImportDuplicate, results[1].Status)
+		assert.Equal(t, ImportDuplicate, results[2].Status)
+
+		_, total, _ := service.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.Equal(t, int64(3), total)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		results, err := service.ImportBooks(books(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportCreated, results[0].Status)
+		assert.NotZero(t, results[0].Book.ID)
+
+		_, total, _ := service.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.Equal(t, int64(4), total)
+
+		results, err = service.ImportBooks(books(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportDuplicate, results[0].Status)
+	})
+
+}
+
+func TestISBN(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	isbn := func(s string) *string { return &s }
+
+	t.Run("normalization", func(t *testing.T) {
+		for input, want := range map[string]string{
+			"0-306-40615-2":     "9780306406157",
+			"0 8044 2957 x":     "9780804429573",
+			"978-0-306-40615-7": "9780306406157",
+			"979 10 90636 07 1": "9791090636071",
+		} {
+			got, ok := models.NormalizeISBN(input)
+			assert.True(t, ok, input)
+			assert.Equal(t, want, got, input)
+		}
+
+		for _, input := range []string{"0-306-40615-3", "978-0-306-40615-8", "977-0-306-40615-7", "X306406152", "12345", ""} {
+			_, ok := models.NormalizeISBN(input)
+			assert.False(t, ok, input)
+		}
+	})
+
+	t.Run("stored as isbn-13", func(t *testing.T) {
+		book, err := service.CreateBook(&models.Book{Title: "Book Four", Author: "Author D", Year: 2024, ISBN: isbn("0-306-40615-2")})
+		assert.NoError(t, err)
+		assert.Equal(t, "9780306406157", *book.ISBN)
+
+		fetched, err := service.GetBookByISBN("0306406152")
+		assert.NoError(t, err)
+		assert.Equal(t, book.ID, fetched.ID)
+
+		_, err = service.GetBookByISBN("9780804429573")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = service.GetBookByISBN("0306406153")
+		assert.ErrorIs(t, err, ErrInvalidISBN)
+	})
+
+	t.Run("must be unique", func(t *testing.T) {
+		_, err := service.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2024, ISBN: isbn("978-0-306-40615-7")})
+		assert.ErrorIs(t, err, ErrDuplicateISBN)
+
+		_, err = service.UpdateBook(&models.Book{Model: gorm.Model{ID: 1}, Title: "Book One", Author: "Author A", Year: 2021, ISBN: isbn("9780306406157")})
+		assert.ErrorIs(t, err, ErrDuplicateISBN)
+
+		book, err := service.GetBookByISBN("9780306406157")
+		assert.NoError(t, err)
+		_, err = service.UpdateBook(&models.Book{Model: gorm.Model{ID: book.ID}, Title: "Book Four", Author: "Author D", Year: 2025, ISBN: isbn("0306406152")})
+		assert.NoError(t, err)
+	})
+
+	t.Run("empty isbns are not stored", func(t *testing.T) {
+		for range 2 {
+			book, err := service.CreateBook(&models.Book{Title: "Untitled", Author: "Anonymous", Year: 2024, ISBN: isbn("")})
+			assert.NoError(t, err)
+			assert.Nil(t, book.ISBN)
+		}
+	})
+
+	t.Run("import duplicates", func(t *testing.T) {
+		results, err := service.ImportBooks([]*models.Book{
+			{Title: "Another Title", Author: "Author D", Year: 2024, ISBN: isbn("0-306-40615-2")},
+			{Title: "Book Six", Author: "Author F", Year: 2024, ISBN: isbn("0-8044-2957-X")},
+			{Title: "Book Seven", Author: "Author G", Year: 2024, ISBN: isbn("9780804429573")},
+		}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportDuplicate, results[0].Status)
+		assert.Equal(t, ImportCreated, results[1].Status)
+		assert.Equal(t, ImportDuplicate, results[2].Status)
+	})
+
+}
+
 func TestDeleteBook(t *testing.T) {
 	service, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -120,7 +640,7 @@ func TestDeleteBook(t *testing.T) {
 	createdBook, _ := service.CreateBook(book)
 
 	t.Run("deleting an existing book", func(t *testing.T) {
-		deletedBook, err := service.DeleteBook(createdBook.ID)
+		deletedBook, err := service.DeleteBook(createdBook.ID, 0)
 		assert.NoError(t, err)
 		assert.Equal(t, createdBook.ID, deletedBook.ID)
 
@@ -130,7 +650,7 @@ func TestDeleteBook(t *testing.T) {
 
 	t.Run("updating non-existing book", func(t *testing.T) {
 		createdBook.ID = 99
-		deletedBook, err := service.DeleteBook(createdBook.ID)
+		deletedBook, err := service.DeleteBook(createdBook.ID, 0)
 		assert.Nil(t, deletedBook)
 		assert.Error(t, err)
 	})
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBranchNotFound  = errors.New("branch not found")
+	ErrBranchInUse     = errors.New("branch has copies")
+	ErrDuplicateBranch = errors.New("branch already exists")
+	ErrUnknownBranch   = errors.New("unknown branch")
+)
+
+type IBranchService interface {
+	GetAllBranches(page, limit int) ([]*models.Branch, int64, error)
+	GetBranch(id uint) (*models.Branch, error)
+	CreateBranch(branch *models.Branch) (*models.Branch, error)
+	UpdateBranch(payload *models.Branch) (*models.Branch, error)
+	DeleteBranch(id uint) (*models.Branch, error)
+}
+
+var _ IBranchService = (*BranchService)(nil)
+
+type BranchService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewBranchService(db *gorm.DB, logger *slog.Logger) *BranchService {
+	return &BranchService{db: db, logger: logger}
+}
+
+// GetAllBranches returns a page of branches sorted by name.
+func (s *BranchService) GetAllBranches(page, limit int) ([]*models.Branch, int64, error) {
+	db := s.db.Model(&models.Branch{})
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting branches", "error", err)
+		return nil, 0, fmt.Errorf("error while counting branches : %w", err)
+	}
+
+	var branches []*models.Branch
+	offset := (page - 1) * limit
+	if err := db.Order("name COLLATE NOCASE, id").Limit(limit).Offset(offset).Find(&branches).Error; err != nil {
+		s.logger.Error("error fetching branches", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching branches : %w", err)
+	}
+	s.logger.Info("fetched branches", "count", len(branches), "total", total, "page", page, "limit", limit)
+	return branches, total, nil
+}
+
+func (s *BranchService) GetBranch(id uint) (*models.Branch, error) {
+	var branch models.Branch
+	if err := s.db.First(&branch, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("branch not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrBranchNotFound, id)
+		}
+		s.logger.Error("error fetching branch", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the branch : %w", err)
+	}
+	s.logger.Info("fetched branch", "branch", branch)
+	return &branch, nil
+}
+
+func (s *BranchService) CreateBranch(branch *models.Branch) (*models.Branch, error) {
+	branch.Name = models.NormalizeName(branch.Name)
+	if err := s.checkName(branch); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(branch).Error; err != nil {
+		s.logger.Error("failed to create new branch", "error", err)
+		return nil, fmt.Errorf("failed to create new branch : %w", err)
+	}
+	s.logger.Info("created new branch", "branch", branch)
+	return branch, nil
+}
+
+func (s *BranchService) UpdateBranch(payload *models.Branch) (*models.Branch, error) {
+	branch, err := s.GetBranch(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	branch.Name = models.NormalizeName(payload.Name)
+	branch.Address = payload.Address
+	if err := s.checkName(branch); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(branch).Error; err != nil {
+		s.logger.Error("error saving updated branch", "branch", branch, "error", err)
+		return nil, fmt.Errorf("error while saving the branch : %w", err)
+	}
+	s.logger.Info("updated branch", "branch", branch)
+	return branch, nil
+}
+
+// DeleteBranch deletes a branch that holds no copies.
+func (s *BranchService) DeleteBranch(id uint) (*models.Branch, error) {
+	branch, err := s.GetBranch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Copy{}).Where("branch_id = ?", id).Count(&count).Error; err != nil {
+		s.logger.Error("error counting branch copies", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the branch : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("branch to delete has copies", "id", id, "copies", count)
+		return nil, fmt.Errorf("%w: %d copies", ErrBranchInUse, count)
+	}
+
+	if err := s.db.Delete(branch).Error; err != nil {
+		s.logger.Error("error deleting branch", "branch", branch, "error", err)
+		return nil, fmt.Errorf("error while deleting the branch : %w", err)
+	}
+	s.logger.Info("deleted branch", "branch", branch)
+	return branch, nil
+}
+
+// checkName makes sure no other branch has the name of branch, ignoring case.
+func (s *BranchService) checkName(branch *models.Branch) error {
+	var count int64
+	err := s.db.Model(&models.Branch{}).
+		Where("name = ? COLLATE NOCASE AND id <> ?", branch.Name, branch.ID).
+		Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking branch name", "name", branch.Name, "error", err)
+		return fmt.Errorf("error while checking the branch name : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("branch already exists", "name", branch.Name)
+		return fmt.Errorf("%w: %s", ErrDuplicateBranch, branch.Name)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBulkAborted = errors.New("bulk operation aborted")
+)
+
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOperation is a single create, update or delete in a bulk request.
+// Version is the expected version of the book for updates and deletes, zero
+// applies the operation unconditionally.
+type BulkOperation struct {
+	Op      string       `json:"op" validate:"oneof=create update delete"`
+	ID      uint         `json:"id" validate:"required_unless=Op create"`
+	Version uint         `json:"version"`
+	Book    *models.Book `json:"book" validate:"required_unless=Op delete"`
+}
+
+// BulkResult is the outcome of the operation at the same position.
+type BulkResult struct {
+	Book *models.Book
+	Err  error
+}
+
+// BulkWrite runs every operation within a single transaction, each one in its
+// own savepoint. When atomic is set the first failure rolls back the whole
+// batch and ErrBulkAborted is returned, otherwise failed operations are rolled
+// back on their own and the rest are committed. The returned results are in
+// the same order as ops.
+func (s *BookService) BulkWrite(ops []BulkOperation, atomic bool) ([]BulkResult, error) {
+	results := make([]BulkResult, len(ops))
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i, op := range ops {
+			var book *models.Book
+			err := tx.Transaction(func(savepoint *gorm.DB) error {
+				var err error
+				book, err = s.withDB(savepoint).apply(op)
+				return err
+			})
+			results[i] = BulkResult{Book: book, Err: err}
+
+			if err != nil && atomic {
+				return fmt.Errorf("%w: operation %d failed", ErrBulkAborted, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrBulkAborted) {
+			s.logger.Warn("bulk operation aborted", "count", len(ops), "error", err)
+			return results, err
+		}
+		s.logger.Error("error running bulk operation", "count", len(ops), "error", err)
+		return nil, fmt.Errorf("error while running bulk operation : %w", err)
+	}
+	s.logger.Info("ran bulk operation", "count", len(ops), "atomic", atomic)
+	return results, nil
+}
+
+func (s *BookService) apply(op BulkOperation) (*models.Book, error) {
+	switch op.Op {
+	case BulkCreate:
+		book := *op.Book
+		book.ID = 0
+		return s.CreateBook(&book)
+	case BulkUpdate:
+		book := *op.Book
+		book.ID = op.ID
+		book.Version = op.Version
+		return s.UpdateBook(&book)
+	case BulkDelete:
+		return s.DeleteBook(op.ID, op.Version)
+	}
+	return nil, fmt.Errorf("unknown bulk operation %q", op.Op)
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+// bookAvailability counts the copies of the book with the given id, in total
+// and by branch, the branches sorted by name.
+func bookAvailability(db *gorm.DB, bookID uint) (*models.Availability, error) {
+	var counts []struct {
+		BranchID uint
+		Branch   string
+		Status   string
+		Copies   int64
+	}
+	err := db.Model(&models.Copy{}).
+		Select("copies.branch_id, branches.name AS branch, copies.status, COUNT(*) AS copies").
+		Joins("JOIN branches ON branches.id = copies.branch_id").
+		Where("copies.book_id = ?", bookID).
+		Group("copies.branch_id, branches.name, copies.status").
+		Order("branches.name COLLATE NOCASE, copies.branch_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	availability := &models.Availability{Branches: []models.BranchAvailability{}}
+	for _, count := range counts {
+		last := len(availability.Branches) - 1
+		if last < 0 || availability.Branches[last].BranchID != count.BranchID {
+			availability.Branches = append(availability.Branches, models.BranchAvailability{BranchID: count.BranchID, Branch: count.Branch})
+			last++
+		}
+
+		branch := &availability.Branches[last]
+		branch.Total += count.Copies
+		switch count.Status {
+		case models.CopyAvailable:
+			branch.Available += count.Copies
+		case models.CopyOnLoan:
+			branch.OnLoan += count.Copies
+		case models.CopyLost:
+			branch.Lost += count.Copies
+		case models.CopyInRepair:
+			branch.InRepair += count.Copies
+		case models.CopyOnHold:
+			branch.OnHold += count.Copies
+		}
+
+		availability.Total += count.Copies
+		if count.Status == models.CopyAvailable {
+			availability.Available += count.Copies
+		}
+	}
+	return availability, nil
+}
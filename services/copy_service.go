@@ -0,0 +1,269 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCopyNotFound     = errors.New("copy not found")
+	ErrDuplicateBarcode = errors.New("barcode already in use")
+	ErrUnknownBook      = errors.New("unknown book")
+	ErrCopyOnLoan       = errors.New("copy is on loan")
+)
+
+// CopyFilter narrows down the copy list. Zero fields don't filter.
+type CopyFilter struct {
+	BookID   uint   `query:"book_id"`
+	BranchID uint   `query:"branch_id"`
+	Status   string `query:"status" validate:"omitempty,oneof=available on_loan lost in_repair on_hold"`
+}
+
+type ICopyService interface {
+	GetAllCopies(filter CopyFilter, page, limit int) ([]*models.Copy, int64, error)
+	GetCopy(id uint) (*models.Copy, error)
+	GetCopyByBarcode(barcode string) (*models.Copy, error)
+	CreateCopy(bookCopy *models.Copy) (*models.Copy, error)
+	UpdateCopy(payload *models.Copy) (*models.Copy, error)
+	DeleteCopy(id uint) (*models.Copy, error)
+	ForTenant(tenant *models.Tenant) ICopyService
+}
+
+var _ ICopyService = (*CopyService)(nil)
+
+type CopyService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewCopyService(db *gorm.DB, logger *slog.Logger) *CopyService {
+	return &CopyService{db: db, logger: logger}
+}
+
+// ForTenant returns a service that only reads and writes the copies of the
+// books of tenant.
+func (s *CopyService) ForTenant(tenant *models.Tenant) ICopyService {
+	return &CopyService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID)}
+}
+
+// GetAllCopies returns a page of the copies matching filter, sorted by
+// barcode.
+func (s *CopyService) GetAllCopies(filter CopyFilter, page, limit int) ([]*models.Copy, int64, error) {
+	db := s.db.Model(&models.Copy{})
+	if filter.BookID != 0 {
+		db = db.Where("book_id = ?", filter.BookID)
+	}
+	if filter.BranchID != 0 {
+		db = db.Where("branch_id = ?", filter.BranchID)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting copies", "error", err)
+		return nil, 0, fmt.Errorf("error while counting copies : %w", err)
+	}
+
+	var copies []*models.Copy
+	offset := (page - 1) * limit
+	if err := db.Preload("Branch").Order("barcode").Limit(limit).Offset(offset).Find(&copies).Error; err != nil {
+		s.logger.Error("error fetching copies", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching copies : %w", err)
+	}
+	s.logger.Info("fetched copies", "count", len(copies), "total", total, "page", page, "limit", limit)
+	return copies, total, nil
+}
+
+func (s *CopyService) GetCopy(id uint) (*models.Copy, error) {
+	var bookCopy models.Copy
+	if err := s.db.Preload("Branch").First(&bookCopy, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("copy not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrCopyNotFound, id)
+		}
+		s.logger.Error("error fetching copy", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the copy : %w", err)
+	}
+	s.logger.Info("fetched copy", "copy", bookCopy)
+	return &bookCopy, nil
+}
+
+// GetCopyByBarcode returns the copy with the given barcode, ignoring case.
+func (s *CopyService) GetCopyByBarcode(barcode string) (*models.Copy, error) {
+	barcode = normalizeBarcode(barcode)
+
+	var bookCopy models.Copy
+	if err := s.db.Preload("Branch").Where("barcode = ?", barcode).First(&bookCopy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("copy not found", "barcode", barcode)
+			return nil, fmt.Errorf("%w: barcode %s", ErrCopyNotFound, barcode)
+		}
+		s.logger.Error("error fetching copy", "barcode", barcode, "error", err)
+		return nil, fmt.Errorf("error while fetching the copy : %w", err)
+	}
+	s.logger.Info("fetched copy", "copy", bookCopy)
+	return &bookCopy, nil
+}
+
+func (s *CopyService) CreateCopy(bookCopy *models.Copy) (*models.Copy, error) {
+	if bookCopy.Status == models.CopyOnHold {
+		s.logger.Warn("new copy created on hold", "barcode", bookCopy.Barcode)
+		return nil, fmt.Errorf("%w: only a hold sets a copy aside", ErrCopyOnHold)
+	}
+	if err := s.checkCopy(bookCopy); err != nil {
+		return nil, err
+	}
+	if err := s.db.Omit("Branch").Create(bookCopy).Error; err != nil {
+		s.logger.Error("failed to create new copy", "error", err)
+		return nil, fmt.Errorf("failed to create new copy : %w", err)
+	}
+	s.logger.Info("created new copy", "copy", bookCopy)
+	return bookCopy, nil
+}
+
+// UpdateCopy replaces the details of the copy identified by payload.ID. Only
+// a checkout puts a copy on loan, and only a hold sets it aside. The status of
+// a copy lent to a patron or set aside for one only changes through the loan
+// or the hold.
+func (s *CopyService) UpdateCopy(payload *models.Copy) (*models.Copy, error) {
+	bookCopy, err := s.GetCopy(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Status != bookCopy.Status {
+		if payload.Status == models.CopyOnLoan {
+			s.logger.Warn("copy put on loan without a checkout", "id", bookCopy.ID)
+			return nil, fmt.Errorf("%w: only a checkout puts copy %d on loan", ErrCopyOnLoan, bookCopy.ID)
+		}
+		if payload.Status == models.CopyOnHold {
+			s.logger.Warn("copy put on hold without a hold", "id", bookCopy.ID)
+			return nil, fmt.Errorf("%w: only a hold sets copy %d aside", ErrCopyOnHold, bookCopy.ID)
+		}
+		if err := s.checkNotLent(bookCopy.ID); err != nil {
+			return nil, err
+		}
+		if err := s.checkNotHeld(bookCopy.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	bookCopy.BookID = payload.BookID
+	bookCopy.Barcode = payload.Barcode
+	bookCopy.BranchID = payload.BranchID
+	bookCopy.Shelf = payload.Shelf
+	bookCopy.Condition = payload.Condition
+	bookCopy.Status = payload.Status
+	if err := s.checkCopy(bookCopy); err != nil {
+		return nil, err
+	}
+	if err := s.db.Omit("Branch").Save(bookCopy).Error; err != nil {
+		s.logger.Error("error saving updated copy", "copy", bookCopy, "error", err)
+		return nil, fmt.Errorf("error while saving the copy : %w", err)
+	}
+	s.logger.Info("updated copy", "copy", bookCopy)
+	return bookCopy, nil
+}
+
+// DeleteCopy deletes a copy that isn't lent to a patron nor set aside for one.
+func (s *CopyService) DeleteCopy(id uint) (*models.Copy, error) {
+	bookCopy, err := s.GetCopy(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotLent(id); err != nil {
+		return nil, err
+	}
+	if err := s.checkNotHeld(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Delete(bookCopy).Error; err != nil {
+		s.logger.Error("error deleting copy", "copy", bookCopy, "error", err)
+		return nil, fmt.Errorf("error while deleting the copy : %w", err)
+	}
+	s.logger.Info("deleted copy", "copy", bookCopy)
+	return bookCopy, nil
+}
+
+// checkNotLent makes sure the copy with the given id has no active loan.
+func (s *CopyService) checkNotLent(id uint) error {
+	var count int64
+	if err := s.db.Model(&models.Loan{}).Where("copy_id = ? AND returned_at IS NULL", id).Count(&count).Error; err != nil {
+		s.logger.Error("error checking copy loans", "id", id, "error", err)
+		return fmt.Errorf("error while checking the copy loans : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("copy is lent to a patron", "id", id)
+		return fmt.Errorf("%w: id %d", ErrCopyOnLoan, id)
+	}
+	return nil
+}
+
+// checkNotHeld makes sure the copy with the given id isn't set aside for a
+// hold.
+func (s *CopyService) checkNotHeld(id uint) error {
+	var count int64
+	if err := s.db.Model(&models.Hold{}).Where("copy_id = ? AND status = ?", id, models.HoldReady).Count(&count).Error; err != nil {
+		s.logger.Error("error checking copy holds", "id", id, "error", err)
+		return fmt.Errorf("error while checking the copy holds : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("copy is set aside for a hold", "id", id)
+		return fmt.Errorf("%w: id %d", ErrCopyOnHold, id)
+	}
+	return nil
+}
+
+// checkCopy makes sure the book and the branch of bookCopy exist and that no
+// other copy of any tenant, deleted ones included, has its barcode. A copy without a status
+// is available.
+func (s *CopyService) checkCopy(bookCopy *models.Copy) error {
+	bookCopy.Barcode = normalizeBarcode(bookCopy.Barcode)
+	if bookCopy.Status == "" {
+		bookCopy.Status = models.CopyAvailable
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Book{}).Where("id = ?", bookCopy.BookID).Count(&count).Error; err != nil {
+		s.logger.Error("error checking copy book", "book_id", bookCopy.BookID, "error", err)
+		return fmt.Errorf("error while checking the copy : %w", err)
+	}
+	if count == 0 {
+		s.logger.Warn("unknown copy book", "book_id", bookCopy.BookID)
+		return fmt.Errorf("%w: id %d", ErrUnknownBook, bookCopy.BookID)
+	}
+
+	var branch models.Branch
+	if err := s.db.First(&branch, bookCopy.BranchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("unknown copy branch", "branch_id", bookCopy.BranchID)
+			return fmt.Errorf("%w: id %d", ErrUnknownBranch, bookCopy.BranchID)
+		}
+		s.logger.Error("error checking copy branch", "branch_id", bookCopy.BranchID, "error", err)
+		return fmt.Errorf("error while checking the copy : %w", err)
+	}
+	bookCopy.Branch = &branch
+
+	err := allTenants(s.db).Unscoped().Model(&models.Copy{}).Where("barcode = ? AND id <> ?", bookCopy.Barcode, bookCopy.ID).Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking copy barcode", "barcode", bookCopy.Barcode, "error", err)
+		return fmt.Errorf("error while checking the copy : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("barcode already in use", "barcode", bookCopy.Barcode)
+		return fmt.Errorf("%w: %s", ErrDuplicateBarcode, bookCopy.Barcode)
+	}
+	return nil
+}
+
+// normalizeBarcode stores barcodes in upper case, as scanners read them.
+func normalizeBarcode(barcode string) string {
+	return strings.ToUpper(strings.TrimSpace(barcode))
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopies(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	branchService := NewBranchService(service.db, service.logger)
+	copyService := NewCopyService(service.db, service.logger)
+
+	central, err := branchService.CreateBranch(&models.Branch{Name: "Central", Address: "1 Main Street"})
+	assert.NoError(t, err)
+	east, err := branchService.CreateBranch(&models.Branch{Name: "East"})
+	assert.NoError(t, err)
+
+	create := func(barcode string, bookID, branchID uint, status string) *models.Copy {
+		bookCopy, err := copyService.CreateCopy(&models.Copy{Barcode: barcode, BookID: bookID, BranchID: branchID, Status: status})
+		assert.NoError(t, err)
+		return bookCopy
+	}
+	first := create("c001", 1, central.ID, "")
+	create("C002", 1, central.ID, models.CopyOnLoan)
+	create("C003", 1, east.ID, models.CopyAvailable)
+	create("C004", 1, east.ID, models.CopyInRepair)
+	create("C005", 2, east.ID, models.CopyLost)
+
+	t.Run("branch names are unique", func(t *testing.T) {
+		_, err := branchService.CreateBranch(&models.Branch{Name: "central"})
+		assert.ErrorIs(t, err, ErrDuplicateBranch)
+
+		branches, total, err := branchService.GetAllBranches(1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, "Central", branches[0].Name)
+	})
+
+	t.Run("copies are checked", func(t *testing.T) {
+		assert.Equal(t, "C001", first.Barcode)
+		assert.Equal(t, models.CopyAvailable, first.Status)
+		assert.Equal(t, "Central", first.Branch.Name)
+
+		_, err := copyService.CreateCopy(&models.Copy{Barcode: "c002", BookID: 2, BranchID: central.ID})
+		assert.ErrorIs(t, err, ErrDuplicateBarcode)
+
+		_, err = copyService.CreateCopy(&models.Copy{Barcode: "C006", BookID: 99, BranchID: central.ID})
+		assert.ErrorIs(t, err, ErrUnknownBook)
+
+		_, err = copyService.CreateCopy(&models.Copy{Barcode: "C006", BookID: 2, BranchID: 99})
+		assert.ErrorIs(t, err, ErrUnknownBranch)
+
+		bookCopy, err := copyService.GetCopyByBarcode(" c001 ")
+		assert.NoError(t, err)
+		assert.Equal(t, first.ID, bookCopy.ID)
+	})
+
+	t.Run("copies are listed by barcode", func(t *testing.T) {
+		copies, total, err := copyService.GetAllCopies(CopyFilter{BookID: 1}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+		assert.Equal(t, "C001", copies[0].Barcode)
+
+		_, total, _ = copyService.GetAllCopies(CopyFilter{BranchID: east.ID, Status: models.CopyAvailable}, 1, 10)
+		assert.Equal(t, int64(1), total)
+	})
+
+	t.Run("books sum up their copies", func(t *testing.T) {
+		book, err := service.GetBook(1)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Availability{
+			Total:     4,
+			Available: 2,
+			Branches: []models.BranchAvailability{
+				{BranchID: central.ID, Branch: "Central", Total: 2, Available: 1, OnLoan: 1},
+				{BranchID: east.ID, Branch: "East", Total: 2, Available: 1, InRepair: 1},
+			},
+		}, book.Availability)
+
+		book, _ = service.GetBook(3)
+		assert.Equal(t, &models.Availability{Branches: []models.BranchAvailability{}}, book.Availability)
+	})
+
+	t.Run("copies are updated and deleted", func(t *testing.T) {
+		bookCopy, err := copyService.UpdateCopy(&models.Copy{Model: first.Model, Barcode: "C001", BookID: 1, BranchID: east.ID, Shelf: "FIC BRO", Condition: models.ConditionGood})
+		assert.NoError(t, err)
+		assert.Equal(t, "East", bookCopy.Branch.Name)
+		assert.Equal(t, models.CopyAvailable, bookCopy.Status)
+
+		_, err = branchService.DeleteBranch(east.ID)
+		assert.ErrorIs(t, err, ErrBranchInUse)
+
+		_, err = copyService.DeleteCopy(first.ID)
+		assert.NoError(t, err)
+
+		_, err = copyService.GetCopy(first.ID)
+		assert.ErrorIs(t, err, ErrCopyNotFound)
+
+		_, err = copyService.CreateCopy(&models.Copy{Barcode: "C001", BookID: 1, BranchID: central.ID})
+		assert.ErrorIs(t, err, ErrDuplicateBarcode)
+	})
+
+	t.Run("purging a book removes its copies", func(t *testing.T) {
+		_, err := service.PurgeBook(2, 0)
+		assert.NoError(t, err)
+
+		_, err = copyService.GetCopyByBarcode("C005")
+		assert.ErrorIs(t, err, ErrCopyNotFound)
+	})
+
+	t.Run("copies of other tenants are out of reach", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		theirs := copyService.ForTenant(springfield)
+		ourCopy, err := copyService.GetCopyByBarcode("C003")
+		assert.NoError(t, err)
+		theirBook, err := service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Twelve", Author: "Author L", Year: 2024})
+		assert.NoError(t, err)
+
+		_, err = theirs.CreateCopy(&models.Copy{Barcode: "S001", BookID: 1, BranchID: central.ID})
+		assert.ErrorIs(t, err, ErrUnknownBook)
+		theirCopy, err := theirs.CreateCopy(&models.Copy{Barcode: "S001", BookID: theirBook.ID, BranchID: central.ID})
+		assert.NoError(t, err)
+		_, err = theirs.CreateCopy(&models.Copy{Barcode: "C003", BookID: theirBook.ID, BranchID: central.ID})
+		assert.ErrorIs(t, err, ErrDuplicateBarcode)
+
+		copies, total, err := theirs.GetAllCopies(CopyFilter{}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, theirCopy.ID, copies[0].ID)
+
+		_, err = theirs.GetCopy(ourCopy.ID)
+		assert.ErrorIs(t, err, ErrCopyNotFound)
+		_, err = theirs.GetCopyByBarcode("C003")
+		assert.ErrorIs(t, err, ErrCopyNotFound)
+		_, err = theirs.UpdateCopy(&models.Copy{Model: ourCopy.Model, Barcode: "C003", BookID: theirBook.ID, BranchID: central.ID})
+		assert.ErrorIs(t, err, ErrCopyNotFound)
+		_, err = theirs.DeleteCopy(ourCopy.ID)
+		assert.ErrorIs(t, err, ErrCopyNotFound)
+
+		ours := copyService.ForTenant(&models.Tenant{ID: models.DefaultTenant})
+		_, err = ours.UpdateCopy(&models.Copy{Model: ourCopy.Model, Barcode: "C003", BookID: theirBook.ID, BranchID: central.ID, Status: models.CopyAvailable})
+		assert.ErrorIs(t, err, ErrUnknownBook)
+		_, total, _ = ours.GetAllCopies(CopyFilter{Status: models.CopyAvailable}, 1, 10)
+		assert.NotZero(t, total)
+		_, total, _ = ours.GetAllCopies(CopyFilter{BookID: theirBook.ID}, 1, 10)
+		assert.Zero(t, total)
+	})
+}
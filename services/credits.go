@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("author is credited twice in the same role")
+)
+
+// withAuthors loads the credits of the books, in order, along with their
+// authors.
+func withAuthors(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Authors", func(db *gorm.DB) *gorm.DB { return db.Order("position, role") }).
+		Preload("Authors.Author")
+}
+
+// loadAuthors loads the credits of books that were read without withAuthors.
+func loadAuthors(db *gorm.DB, books []*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+
+	var credits []models.BookAuthor
+	if err := db.Preload("Author").Where("book_id IN ?", ids).Order("position, role").Find(&credits).Error; err != nil {
+		return err
+	}
+
+	byBook := make(map[uint][]models.BookAuthor)
+	for _, credit := range credits {
+		byBook[credit.BookID] = append(byBook[credit.BookID], credit)
+	}
+	for _, book := range books {
+		book.Authors = byBook[book.ID]
+	}
+	return nil
+}
+
+// creditAuthors resolves the credits of book before it is saved. Without any
+// credits, every name of its author line is credited as an author, creating
+// the authors that don't exist yet. Otherwise every credited author must
+// exist, and the credits default to the author role in the order given.
+func creditAuthors(db *gorm.DB, book *models.Book) error {
+	if book.Authors == nil {
+		for i, name := range models.SplitAuthors(book.Author) {
+			author, err := findOrCreateAuthor(db, name)
+			if err != nil {
+				return err
+			}
+			book.Authors = append(book.Authors, models.BookAuthor{
+				AuthorID: author.ID,
+				Role:     models.RoleAuthor,
+				Position: i + 1,
+				Author:   author,
+			})
+		}
+		return nil
+	}
+
+	seen := make(map[models.BookAuthor]bool)
+	for i := range book.Authors {
+		credit := &book.Authors[i]
+		if credit.Role == "" {
+			credit.Role = models.RoleAuthor
+		}
+		if credit.Position == 0 {
+			credit.Position = i + 1
+		}
+
+		key := models.BookAuthor{AuthorID: credit.AuthorID, Role: credit.Role}
+		if seen[key] {
+			return fmt.Errorf("%w: author %d as %s", ErrDuplicateCredit, credit.AuthorID, credit.Role)
+		}
+		seen[key] = true
+
+		var author models.Author
+		if err := db.First(&author, credit.AuthorID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id %d", ErrUnknownAuthor, credit.AuthorID)
+			}
+			return err
+		}
+		credit.Author = &author
+	}
+	return nil
+}
+
+// findOrCreateAuthor returns the author with the given name, ignoring case,
+// creating it when there is none.
+func findOrCreateAuthor(db *gorm.DB, name string) (*models.Author, error) {
+	var author models.Author
+	err := db.Where("name = ? COLLATE NOCASE", name).Order("id").First(&author).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		author = models.Author{Name: name}
+		err = db.Create(&author).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &author, nil
+}
+
+// saveCredits replaces the stored credits of book with its current ones.
+func saveCredits(db *gorm.DB, book *models.Book) error {
+	if err := db.Where("book_id = ?", book.ID).Delete(&models.BookAuthor{}).Error; err != nil {
+		return err
+	}
+	if len(book.Authors) == 0 {
+		return nil
+	}
+
+	for i := range book.Authors {
+		book.Authors[i].BookID = book.ID
+	}
+	return db.Omit("Author").Create(&book.Authors).Error
+}
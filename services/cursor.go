@@ -0,0 +1,173 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// cursorKey signs the cursors handed out to clients. It is read from
+// CURSOR_SECRET, falling back to a random key, in which case cursors stop
+// being valid once the server restarts.
+var cursorKey = loadCursorKey()
+
+func loadCursorKey() []byte {
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// cursor is the position of the last book on a page, expressed as the value
+// of every sort key of the query that produced it.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+func sortSignature(keys []SortField) string {
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = key.Field
+		if key.Desc {
+			fields[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func sortValue(book *models.Book, field string) any {
+	switch field {
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "year":
+		return book.Year
+	case "created_at":
+		return book.CreatedAt
+	case "updated_at":
+		return book.UpdatedAt
+	default:
+		return book.ID
+	}
+}
+
+// encodeCursor returns the signed cursor pointing just after book.
+func encodeCursor(keys []SortField, book *models.Book) string {
+	c := cursor{Sort: sortSignature(keys)}
+	for _, key := range keys {
+		c.Values = append(c.Values, sortValue(book, key.Field))
+	}
+
+	payload, _ := json.Marshal(c)
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write(payload)
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// decodeCursor verifies the signature of token and returns the sort key values
+// it holds. The cursor must have been issued for the same sort order.
+func decodeCursor(keys []SortField, token string) ([]any, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, cursorKey)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sortSignature(keys) || len(c.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: issued for a different sort order", ErrInvalidCursor)
+	}
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		value, err := cursorValue(key.Field, c.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func cursorValue(field string, raw any) (any, error) {
+	switch field {
+	case "title", "author":
+		if s, ok := raw.(string); ok {
+			return s, nil
+		}
+	case "created_at", "updated_at":
+		if s, ok := raw.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	default:
+		if n, ok := raw.(json.Number); ok {
+			return n.Int64()
+		}
+	}
+	return nil, ErrInvalidCursor
+}
+
+// after restricts the query to the rows sorted after the given sort key
+// values, as in (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func after(keys []SortField, values []any) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		var conditions []string
+		var args []any
+		for i, key := range keys {
+			var terms []string
+			for j := range i {
+				terms = append(terms, sortableColumns[keys[j].Field]+" = ?")
+				args = append(args, values[j])
+			}
+
+			op := ">"
+			if key.Desc {
+				op = "<"
+			}
+			terms = append(terms, sortableColumns[key.Field]+" "+op+" ?")
+			args = append(args, values[i])
+
+			conditions = append(conditions, "("+strings.Join(terms, " AND ")+")")
+		}
+		return db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+}
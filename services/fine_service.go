@@ -0,0 +1,375 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFinePolicyNotFound  = errors.New("fine policy not found")
+	ErrDuplicateFinePolicy = errors.New("fine policy already exists")
+	ErrClosedDayNotFound   = errors.New("closed day not found")
+	ErrDuplicateClosedDay  = errors.New("branch is already closed on the date")
+	ErrOverpayment         = errors.New("amount is more than the balance")
+)
+
+type IFineService interface {
+	GetFinePolicies() ([]*models.FinePolicy, error)
+	CreateFinePolicy(policy *models.FinePolicy) (*models.FinePolicy, error)
+	UpdateFinePolicy(payload *models.FinePolicy) (*models.FinePolicy, error)
+	DeleteFinePolicy(id uint) (*models.FinePolicy, error)
+	GetClosedDays(branchID uint) ([]*models.ClosedDay, error)
+	AddClosedDay(day *models.ClosedDay) (*models.ClosedDay, error)
+	RemoveClosedDay(branchID uint, date string) (*models.ClosedDay, error)
+	GetBalance(patronID uint) (*models.Balance, error)
+	GetLedger(patronID uint, page, limit int) ([]*models.LedgerEntry, int64, error)
+	RecordEntry(entry *models.LedgerEntry) (*models.LedgerEntry, error)
+	AccrueFines() (int64, error)
+	ForTenant(tenant *models.Tenant) IFineService
+}
+
+var _ IFineService = (*FineService)(nil)
+
+type FineService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewFineService(db *gorm.DB, logger *slog.Logger) *FineService {
+	return &FineService{db: db, logger: logger, now: time.Now}
+}
+
+// ForTenant returns a service that only reads and writes the ledger of the
+// patrons of tenant. Fine policies and closed days are shared by every
+// tenant.
+func (s *FineService) ForTenant(tenant *models.Tenant) IFineService {
+	return &FineService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID), now: s.now}
+}
+
+// GetFinePolicies returns the fine policies, the default one first.
+func (s *FineService) GetFinePolicies() ([]*models.FinePolicy, error) {
+	var policies []*models.FinePolicy
+	if err := s.db.Order("format").Find(&policies).Error; err != nil {
+		s.logger.Error("error fetching fine policies", "error", err)
+		return nil, fmt.Errorf("error while fetching fine policies : %w", err)
+	}
+	s.logger.Info("fetched fine policies", "count", len(policies))
+	return policies, nil
+}
+
+func (s *FineService) CreateFinePolicy(policy *models.FinePolicy) (*models.FinePolicy, error) {
+	if err := s.checkFormat(policy); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(policy).Error; err != nil {
+		s.logger.Error("failed to create new fine policy", "error", err)
+		return nil, fmt.Errorf("failed to create new fine policy : %w", err)
+	}
+	s.logger.Info("created new fine policy", "policy", policy)
+	return policy, nil
+}
+
+// UpdateFinePolicy replaces the fine policy identified by payload.ID. Fines
+// already charged are kept.
+func (s *FineService) UpdateFinePolicy(payload *models.FinePolicy) (*models.FinePolicy, error) {
+	policy, err := s.getFinePolicy(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.Format = payload.Format
+	policy.DailyRate = payload.DailyRate
+	policy.GraceDays = payload.GraceDays
+	policy.MaxFine = payload.MaxFine
+	if err := s.checkFormat(policy); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(policy).Error; err != nil {
+		s.logger.Error("error saving updated fine policy", "policy", policy, "error", err)
+		return nil, fmt.Errorf("error while saving the fine policy : %w", err)
+	}
+	s.logger.Info("updated fine policy", "policy", policy)
+	return policy, nil
+}
+
+func (s *FineService) DeleteFinePolicy(id uint) (*models.FinePolicy, error) {
+	policy, err := s.getFinePolicy(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Unscoped().Delete(policy).Error; err != nil {
+		s.logger.Error("error deleting fine policy", "policy", policy, "error", err)
+		return nil, fmt.Errorf("error while deleting the fine policy : %w", err)
+	}
+	s.logger.Info("deleted fine policy", "policy", policy)
+	return policy, nil
+}
+
+// GetClosedDays returns the dates a branch is closed, in order.
+func (s *FineService) GetClosedDays(branchID uint) ([]*models.ClosedDay, error) {
+	if err := s.checkBranch(branchID); err != nil {
+		return nil, err
+	}
+
+	var days []*models.ClosedDay
+	if err := s.db.Where("branch_id = ?", branchID).Order("date").Find(&days).Error; err != nil {
+		s.logger.Error("error fetching closed days", "branch_id", branchID, "error", err)
+		return nil, fmt.Errorf("error while fetching closed days : %w", err)
+	}
+	s.logger.Info("fetched closed days", "branch_id", branchID, "count", len(days))
+	return days, nil
+}
+
+func (s *FineService) AddClosedDay(day *models.ClosedDay) (*models.ClosedDay, error) {
+	if err := s.checkBranch(day.BranchID); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.ClosedDay{}).Where("branch_id = ? AND date = ?", day.BranchID, day.Date).Count(&count).Error; err != nil {
+		s.logger.Error("error checking closed day", "branch_id", day.BranchID, "date", day.Date, "error", err)
+		return nil, fmt.Errorf("error while checking the closed day : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("branch already closed", "branch_id", day.BranchID, "date", day.Date)
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateClosedDay, day.Date)
+	}
+
+	if err := s.db.Create(day).Error; err != nil {
+		s.logger.Error("failed to add closed day", "branch_id", day.BranchID, "date", day.Date, "error", err)
+		return nil, fmt.Errorf("failed to add closed day : %w", err)
+	}
+	s.logger.Info("added closed day", "branch_id", day.BranchID, "date", day.Date)
+	return day, nil
+}
+
+func (s *FineService) RemoveClosedDay(branchID uint, date string) (*models.ClosedDay, error) {
+	var day models.ClosedDay
+	if err := s.db.Where("branch_id = ? AND date = ?", branchID, date).First(&day).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("closed day not found", "branch_id", branchID, "date", date)
+			return nil, fmt.Errorf("%w: branch %d on %s", ErrClosedDayNotFound, branchID, date)
+		}
+		s.logger.Error("error fetching closed day", "branch_id", branchID, "date", date, "error", err)
+		return nil, fmt.Errorf("error while fetching the closed day : %w", err)
+	}
+	if err := s.db.Delete(&day).Error; err != nil {
+		s.logger.Error("error removing closed day", "branch_id", branchID, "date", date, "error", err)
+		return nil, fmt.Errorf("error while removing the closed day : %w", err)
+	}
+	s.logger.Info("removed closed day", "branch_id", branchID, "date", date)
+	return &day, nil
+}
+
+// GetBalance sums up the ledger of a patron.
+func (s *FineService) GetBalance(patronID uint) (*models.Balance, error) {
+	if err := s.checkPatron(patronID); err != nil {
+		return nil, err
+	}
+
+	balance, err := patronBalance(s.db, patronID)
+	if err != nil {
+		s.logger.Error("error summing up ledger", "id", patronID, "error", err)
+		return nil, fmt.Errorf("error while computing the balance : %w", err)
+	}
+	s.logger.Info("computed balance", "id", patronID, "balance", balance.Balance)
+	return balance, nil
+}
+
+// GetLedger returns a page of the ledger of a patron, the most recent entries
+// first.
+func (s *FineService) GetLedger(patronID uint, page, limit int) ([]*models.LedgerEntry, int64, error) {
+	if err := s.checkPatron(patronID); err != nil {
+		return nil, 0, err
+	}
+
+	db := s.db.Model(&models.LedgerEntry{}).Where("patron_id = ?", patronID)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting ledger entries", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while counting ledger entries : %w", err)
+	}
+
+	var entries []*models.LedgerEntry
+	offset := (page - 1) * limit
+	if err := db.Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		s.logger.Error("error fetching ledger entries", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching ledger entries : %w", err)
+	}
+	s.logger.Info("fetched ledger", "id", patronID, "count", len(entries), "total", total, "page", page, "limit", limit)
+	return entries, total, nil
+}
+
+// RecordEntry records a payment or a waiver from a patron. Neither can be
+// more than the patron owes.
+func (s *FineService) RecordEntry(entry *models.LedgerEntry) (*models.LedgerEntry, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Patron{}).Where("id = ?", entry.PatronID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: id %d", ErrPatronNotFound, entry.PatronID)
+		}
+
+		balance, err := patronBalance(tx, entry.PatronID)
+		if err != nil {
+			return err
+		}
+		if entry.Amount > balance.Balance {
+			return fmt.Errorf("%w: %d of %d", ErrOverpayment, entry.Amount, balance.Balance)
+		}
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrPatronNotFound) || errors.Is(err, ErrOverpayment) {
+			s.logger.Warn("ledger entry rejected", "id", entry.PatronID, "kind", entry.Kind, "error", err)
+			return nil, err
+		}
+		s.logger.Error("failed to record ledger entry", "id", entry.PatronID, "kind", entry.Kind, "error", err)
+		return nil, fmt.Errorf("failed to record the %s : %w", entry.Kind, err)
+	}
+	s.logger.Info("recorded ledger entry", "id", entry.PatronID, "kind", entry.Kind, "amount", entry.Amount)
+	return entry, nil
+}
+
+// AccrueFines charges the fines owed on the open overdue loans, and returns
+// how many loans were charged. Running it again on the same day charges
+// nothing more.
+func (s *FineService) AccrueFines() (int64, error) {
+	now := s.now()
+
+	var loans []*models.Loan
+	if err := s.db.Where("returned_at IS NULL AND due_at < ?", now).Order("id").Find(&loans).Error; err != nil {
+		s.logger.Error("error fetching overdue loans", "error", err)
+		return 0, fmt.Errorf("error while accruing fines : %w", err)
+	}
+
+	var count int64
+	for _, loan := range loans {
+		var charged int64
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			charged, err = accrueFine(tx, loan, now)
+			return err
+		})
+		if err != nil {
+			s.logger.Error("error accruing fine", "loan_id", loan.ID, "error", err)
+			return count, fmt.Errorf("error while accruing fines : %w", err)
+		}
+		if charged > 0 {
+			count++
+		}
+	}
+	s.logger.Info("accrued fines", "loans", count)
+	return count, nil
+}
+
+// RunFineAccrual accrues fines every night, just after midnight, until ctx is
+// done.
+func (s *FineService) RunFineAccrual(ctx context.Context) {
+	for {
+		now := s.now()
+		y, m, d := now.Date()
+		next := time.Date(y, m, d+1, 0, 5, 0, 0, now.Location())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next.Sub(now)):
+		}
+
+		s.AccrueFines()
+	}
+}
+
+// patronBalance sums up the ledger of the patron with the given id.
+func patronBalance(db *gorm.DB, patronID uint) (*models.Balance, error) {
+	var sums []struct {
+		Kind   string
+		Amount int64
+	}
+	err := db.Model(&models.LedgerEntry{}).
+		Select("kind, SUM(amount) AS amount").
+		Where("patron_id = ?", patronID).
+		Group("kind").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &models.Balance{PatronID: patronID}
+	for _, sum := range sums {
+		switch sum.Kind {
+		case models.EntryCharge:
+			balance.Charges = sum.Amount
+		case models.EntryPayment:
+			balance.Payments = sum.Amount
+		case models.EntryWaiver:
+			balance.Waivers = sum.Amount
+		}
+	}
+	balance.Balance = balance.Charges - balance.Payments - balance.Waivers
+	return balance, nil
+}
+
+func (s *FineService) getFinePolicy(id uint) (*models.FinePolicy, error) {
+	var policy models.FinePolicy
+	if err := s.db.First(&policy, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("fine policy not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrFinePolicyNotFound, id)
+		}
+		s.logger.Error("error fetching fine policy", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the fine policy : %w", err)
+	}
+	return &policy, nil
+}
+
+// checkFormat makes sure no other fine policy is for the format of policy.
+func (s *FineService) checkFormat(policy *models.FinePolicy) error {
+	var count int64
+	if err := s.db.Model(&models.FinePolicy{}).Where("format = ? AND id <> ?", policy.Format, policy.ID).Count(&count).Error; err != nil {
+		s.logger.Error("error checking fine policy format", "format", policy.Format, "error", err)
+		return fmt.Errorf("error while checking the fine policy : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("fine policy already exists", "format", policy.Format)
+		return fmt.Errorf("%w: format %q", ErrDuplicateFinePolicy, policy.Format)
+	}
+	return nil
+}
+
+func (s *FineService) checkBranch(id uint) error {
+	var count int64
+	if err := s.db.Model(&models.Branch{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		s.logger.Error("error fetching branch", "id", id, "error", err)
+		return fmt.Errorf("error while fetching the branch : %w", err)
+	}
+	if count == 0 {
+		s.logger.Warn("branch not found", "id", id)
+		return fmt.Errorf("%w: id %d", ErrBranchNotFound, id)
+	}
+	return nil
+}
+
+func (s *FineService) checkPatron(id uint) error {
+	var count int64
+	if err := s.db.Model(&models.Patron{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		s.logger.Error("error fetching patron", "id", id, "error", err)
+		return fmt.Errorf("error while fetching the patron : %w", err)
+	}
+	if count == 0 {
+		s.logger.Warn("patron not found", "id", id)
+		return fmt.Errorf("%w: id %d", ErrPatronNotFound, id)
+	}
+	return nil
+}
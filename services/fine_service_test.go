@@ -0,0 +1,155 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverdueFine(t *testing.T) {
+	due := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	policy := &models.FinePolicy{DailyRate: 25, GraceDays: 1, MaxFine: 200}
+
+	cases := []struct {
+		name   string
+		end    time.Time
+		closed []string
+		fine   int64
+	}{
+		{"on time", due.Add(time.Hour), nil, 0},
+		{"within grace", due.Add(12 * time.Hour), nil, 0},
+		{"partial days count", due.Add(36 * time.Hour), nil, 25},
+		{"closed days are skipped", due.AddDate(0, 0, 4), []string{"2024-03-03", "2024-03-04"}, 25},
+		{"capped", due.AddDate(0, 0, 30), nil, 200},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.fine, overdueFine(policy, due, c.end, c.closed))
+		})
+	}
+}
+
+func TestFines(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	branchService := NewBranchService(service.db, service.logger)
+	copyService := NewCopyService(service.db, service.logger)
+	patronService := NewPatronService(service.db, service.logger)
+	loanService := NewLoanService(service.db, service.logger, LoanPolicy{Period: 7 * 24 * time.Hour, HoldPickup: 24 * time.Hour})
+	fineService := NewFineService(service.db, service.logger)
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	loanService.now = func() time.Time { return now }
+	fineService.now = func() time.Time { return now }
+
+	_, err := service.PatchBook(2, 0, func(book *models.Book) error {
+		book.Format = models.FormatEbook
+		return nil
+	})
+	assert.NoError(t, err)
+
+	branch, err := branchService.CreateBranch(&models.Branch{Name: "Central"})
+	assert.NoError(t, err)
+	printed, err := copyService.CreateCopy(&models.Copy{Barcode: "C001", BookID: 1, BranchID: branch.ID})
+	assert.NoError(t, err)
+	digital, err := copyService.CreateCopy(&models.Copy{Barcode: "C002", BookID: 2, BranchID: branch.ID})
+	assert.NoError(t, err)
+	ada, err := patronService.CreatePatron(&models.Patron{Name: "Ada", Email: "ada@example.com"})
+	assert.NoError(t, err)
+
+	t.Run("policies are unique per format", func(t *testing.T) {
+		_, err := fineService.CreateFinePolicy(&models.FinePolicy{DailyRate: 20, GraceDays: 2, MaxFine: 500})
+		assert.NoError(t, err)
+		_, err = fineService.CreateFinePolicy(&models.FinePolicy{Format: models.FormatEbook, DailyRate: 0})
+		assert.NoError(t, err)
+
+		_, err = fineService.CreateFinePolicy(&models.FinePolicy{DailyRate: 10})
+		assert.ErrorIs(t, err, ErrDuplicateFinePolicy)
+
+		_, err = fineService.AddClosedDay(&models.ClosedDay{BranchID: branch.ID, Date: "2024-03-12"})
+		assert.NoError(t, err)
+		_, err = fineService.AddClosedDay(&models.ClosedDay{BranchID: branch.ID, Date: "2024-03-12"})
+		assert.ErrorIs(t, err, ErrDuplicateClosedDay)
+		_, err = fineService.AddClosedDay(&models.ClosedDay{BranchID: 99, Date: "2024-03-12"})
+		assert.ErrorIs(t, err, ErrBranchNotFound)
+	})
+
+	var loan, ebookLoan *models.Loan
+	t.Run("fines accrue nightly on overdue loans", func(t *testing.T) {
+		loan, err = loanService.Checkout(CheckoutRequest{PatronID: ada.ID, CopyID: printed.ID})
+		assert.NoError(t, err)
+		ebookLoan, err = loanService.Checkout(CheckoutRequest{PatronID: ada.ID, CopyID: digital.ID})
+		assert.NoError(t, err)
+
+		// due on the 8th, overdue on the 9th to the 13th, closed on the 12th,
+		// two days of grace
+		now = time.Date(2024, 3, 13, 0, 5, 0, 0, time.UTC)
+		count, err := fineService.AccrueFines()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, err = fineService.AccrueFines()
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+
+		balance, err := fineService.GetBalance(ada.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Balance{PatronID: ada.ID, Charges: 40, Balance: 40}, balance)
+	})
+
+	t.Run("returning a loan charges the rest of the fine", func(t *testing.T) {
+		now = time.Date(2024, 3, 14, 9, 0, 0, 0, time.UTC)
+		_, err := loanService.ReturnLoan(loan.ID)
+		assert.NoError(t, err)
+		_, err = loanService.ReturnLoan(ebookLoan.ID)
+		assert.NoError(t, err)
+
+		entries, total, err := fineService.GetLedger(ada.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, int64(20), entries[0].Amount)
+		assert.Equal(t, loan.ID, *entries[0].LoanID)
+	})
+
+	t.Run("payments and waivers reduce the balance", func(t *testing.T) {
+		_, err := patronService.DeletePatron(ada.ID)
+		assert.ErrorIs(t, err, ErrPatronInUse)
+
+		_, err = fineService.RecordEntry(&models.LedgerEntry{PatronID: ada.ID, Kind: models.EntryPayment, Amount: 100})
+		assert.ErrorIs(t, err, ErrOverpayment)
+
+		_, err = fineService.RecordEntry(&models.LedgerEntry{PatronID: ada.ID, Kind: models.EntryPayment, Amount: 50})
+		assert.NoError(t, err)
+		_, err = fineService.RecordEntry(&models.LedgerEntry{PatronID: ada.ID, Kind: models.EntryWaiver, Amount: 10})
+		assert.NoError(t, err)
+
+		balance, err := fineService.GetBalance(ada.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.Balance{PatronID: ada.ID, Charges: 60, Payments: 50, Waivers: 10}, balance)
+
+		_, err = fineService.GetBalance(99)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+	})
+
+	t.Run("the ledgers of other tenants are out of reach", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		ours, theirs := fineService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), fineService.ForTenant(springfield)
+
+		_, err = theirs.GetBalance(ada.ID)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		_, _, err = theirs.GetLedger(ada.ID, 1, 10)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		_, err = theirs.RecordEntry(&models.LedgerEntry{PatronID: ada.ID, Kind: models.EntryWaiver, Amount: 10})
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+
+		balance, err := ours.GetBalance(ada.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), balance.Balance)
+		_, total, err := ours.GetLedger(ada.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+	})
+}
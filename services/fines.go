@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+// accrueFine charges the patron of loan what they owe on it as of now, or as
+// of its return, on top of what they were already charged for it. Fines only
+// grow: a policy lowered afterwards doesn't refund charges.
+func accrueFine(db *gorm.DB, loan *models.Loan, now time.Time) (int64, error) {
+	end := now
+	if loan.ReturnedAt != nil {
+		end = *loan.ReturnedAt
+	}
+	if !end.After(loan.DueAt) {
+		return 0, nil
+	}
+
+	var item struct {
+		Format   string
+		BranchID uint
+	}
+	err := db.Table("copies").
+		Select("books.format, copies.branch_id").
+		Joins("JOIN books ON books.id = copies.book_id").
+		Where("copies.id = ?", loan.CopyID).
+		Take(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var policy models.FinePolicy
+	err = db.Where("format = ? OR format = ''", item.Format).Order("format DESC").First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	first, last := loan.DueAt.AddDate(0, 0, 1).Format(time.DateOnly), end.Format(time.DateOnly)
+	var closed []string
+	err = db.Model(&models.ClosedDay{}).
+		Where("branch_id = ? AND date BETWEEN ? AND ?", item.BranchID, first, last).
+		Pluck("date", &closed).Error
+	if err != nil {
+		return 0, err
+	}
+
+	fine := overdueFine(&policy, loan.DueAt, end, closed)
+
+	var charged int64
+	err = db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("loan_id = ? AND kind = ?", loan.ID, models.EntryCharge).
+		Scan(&charged).Error
+	if err != nil {
+		return 0, err
+	}
+	if fine <= charged {
+		return 0, nil
+	}
+
+	entry := models.LedgerEntry{
+		PatronID: loan.PatronID,
+		LoanID:   &loan.ID,
+		Kind:     models.EntryCharge,
+		Amount:   fine - charged,
+		Note:     fmt.Sprintf("overdue fine for loan %d", loan.ID),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return 0, err
+	}
+	return entry.Amount, nil
+}
+
+// overdueFine returns the fine under policy on a copy due at due and returned
+// at end. Every day after the due date the copy is out, partly or fully, is
+// charged, except the closed days of its branch and the first GraceDays
+// days, up to MaxFine when it is set.
+func overdueFine(policy *models.FinePolicy, due, end time.Time, closed []string) int64 {
+	isClosed := make(map[string]bool, len(closed))
+	for _, date := range closed {
+		isClosed[date] = true
+	}
+
+	y, m, d := due.Date()
+	day := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	y, m, d = end.Date()
+	last := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	var days int64
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !isClosed[day.Format(time.DateOnly)] {
+			days++
+		}
+	}
+
+	days -= int64(policy.GraceDays)
+	if days <= 0 {
+		return 0
+	}
+	fine := days * policy.DailyRate
+	if policy.MaxFine > 0 {
+		fine = min(fine, policy.MaxFine)
+	}
+	return fine
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrHoldNotFound  = errors.New("hold not found")
+	ErrDuplicateHold = errors.New("patron already has a hold on the book")
+	ErrHoldClosed    = errors.New("hold is no longer active")
+	ErrCopyOnHold    = errors.New("copy is set aside for a hold")
+)
+
+type IHoldService interface {
+	PlaceHold(hold *models.Hold) (*models.Hold, error)
+	GetHold(id uint) (*models.Hold, error)
+	GetBookHolds(bookID uint, page, limit int) ([]*models.Hold, int64, error)
+	CancelHold(id uint) (*models.Hold, error)
+	ExpireHolds() (int64, error)
+	GetPatronNotifications(patronID uint, page, limit int) ([]*models.Notification, int64, error)
+	ForTenant(tenant *models.Tenant) IHoldService
+}
+
+var _ IHoldService = (*HoldService)(nil)
+
+type HoldService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+	policy LoanPolicy
+	now    func() time.Time
+}
+
+func NewHoldService(db *gorm.DB, logger *slog.Logger, policy LoanPolicy) *HoldService {
+	return &HoldService{db: db, logger: logger, policy: policy, now: time.Now}
+}
+
+// ForTenant returns a service that only places holds on the books of tenant
+// for the patrons of tenant, and only reads and writes those holds and the
+// notifications about them. Branches are shared by every tenant.
+func (s *HoldService) ForTenant(tenant *models.Tenant) IHoldService {
+	return &HoldService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID), policy: s.policy, now: s.now}
+}
+
+// PlaceHold queues a patron for the book hold.BookID. When a copy of the book
+// is available, it is set aside for the hold right away.
+func (s *HoldService) PlaceHold(hold *models.Hold) (*models.Hold, error) {
+	now := s.now()
+	hold.Status, hold.CopyID, hold.ReadyAt, hold.ExpiresAt = models.HoldWaiting, nil, nil, nil
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Book{}).Where("id = ?", hold.BookID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: id %d", ErrNotFound, hold.BookID)
+		}
+		if err := tx.Model(&models.Patron{}).Where("id = ?", hold.PatronID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: id %d", ErrUnknownPatron, hold.PatronID)
+		}
+		if err := tx.Model(&models.Branch{}).Where("id = ?", hold.PickupBranchID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: id %d", ErrUnknownBranch, hold.PickupBranchID)
+		}
+
+		err := tx.Model(&models.Hold{}).
+			Where("book_id = ? AND patron_id = ? AND status IN ?", hold.BookID, hold.PatronID, []string{models.HoldWaiting, models.HoldReady}).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: book %d", ErrDuplicateHold, hold.BookID)
+		}
+
+		if err := tx.Omit("PickupBranch").Create(hold).Error; err != nil {
+			return err
+		}
+		if err := notify(tx, hold, models.EventHoldPlaced); err != nil {
+			return err
+		}
+		return fillHolds(tx, hold.BookID, now, s.policy.HoldPickup)
+	})
+	if err != nil {
+		if isRejectedHold(err) {
+			s.logger.Warn("hold rejected", "book_id", hold.BookID, "patron_id", hold.PatronID, "error", err)
+			return nil, err
+		}
+		s.logger.Error("failed to place hold", "book_id", hold.BookID, "patron_id", hold.PatronID, "error", err)
+		return nil, fmt.Errorf("failed to place hold : %w", err)
+	}
+	s.logger.Info("placed hold", "id", hold.ID, "book_id", hold.BookID, "patron_id", hold.PatronID)
+	return s.GetHold(hold.ID)
+}
+
+// GetHold returns the hold with the given id, along with its position in the
+// queue while it is waiting.
+func (s *HoldService) GetHold(id uint) (*models.Hold, error) {
+	var hold models.Hold
+	if err := s.db.Preload("PickupBranch").First(&hold, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("hold not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrHoldNotFound, id)
+		}
+		s.logger.Error("error fetching hold", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the hold : %w", err)
+	}
+
+	if hold.Status == models.HoldWaiting {
+		var ahead int64
+		err := s.db.Model(&models.Hold{}).Where("book_id = ? AND status = ? AND id < ?", hold.BookID, models.HoldWaiting, hold.ID).Count(&ahead).Error
+		if err != nil {
+			s.logger.Error("error fetching hold position", "id", id, "error", err)
+			return nil, fmt.Errorf("error while fetching the hold : %w", err)
+		}
+		hold.Position = int(ahead) + 1
+	}
+	s.logger.Info("fetched hold", "id", id)
+	return &hold, nil
+}
+
+// GetBookHolds returns a page of the active holds on a book: the holds ready
+// for pickup, then the waiting ones in queue order.
+func (s *HoldService) GetBookHolds(bookID uint, page, limit int) ([]*models.Hold, int64, error) {
+	var count int64
+	if err := s.db.Model(&models.Book{}).Where("id = ?", bookID).Count(&count).Error; err != nil {
+		s.logger.Error("error fetching book", "id", bookID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching the book : %w", err)
+	}
+	if count == 0 {
+		s.logger.Warn("book not found", "id", bookID)
+		return nil, 0, fmt.Errorf("%w: id %d", ErrNotFound, bookID)
+	}
+
+	db := s.db.Model(&models.Hold{}).Where("book_id = ? AND status IN ?", bookID, []string{models.HoldWaiting, models.HoldReady})
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting holds", "book_id", bookID, "error", err)
+		return nil, 0, fmt.Errorf("error while counting holds : %w", err)
+	}
+
+	var ready int64
+	if err := db.Session(&gorm.Session{}).Where("status = ?", models.HoldReady).Count(&ready).Error; err != nil {
+		s.logger.Error("error counting holds", "book_id", bookID, "error", err)
+		return nil, 0, fmt.Errorf("error while counting holds : %w", err)
+	}
+
+	var holds []*models.Hold
+	offset := (page - 1) * limit
+	err := db.Preload("PickupBranch").
+		Order(gorm.Expr("status = ? DESC, id", models.HoldReady)).
+		Limit(limit).Offset(offset).Find(&holds).Error
+	if err != nil {
+		s.logger.Error("error fetching holds", "book_id", bookID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching holds : %w", err)
+	}
+	for i, hold := range holds {
+		if hold.Status == models.HoldWaiting {
+			hold.Position = offset + i + 1 - int(ready)
+		}
+	}
+	s.logger.Info("fetched holds", "book_id", bookID, "count", len(holds), "total", total, "page", page, "limit", limit)
+	return holds, total, nil
+}
+
+// CancelHold takes a hold out of the queue. The copy set aside for a ready
+// hold goes to the next waiting hold.
+func (s *HoldService) CancelHold(id uint) (*models.Hold, error) {
+	if err := s.closeHold(id, models.HoldCancelled, models.EventHoldCancelled, models.HoldWaiting, models.HoldReady); err != nil {
+		if isRejectedHold(err) {
+			s.logger.Warn("hold cancellation rejected", "id", id, "error", err)
+			return nil, err
+		}
+		s.logger.Error("error cancelling hold", "id", id, "error", err)
+		return nil, fmt.Errorf("error while cancelling the hold : %w", err)
+	}
+	s.logger.Info("cancelled hold", "id", id)
+	return s.GetHold(id)
+}
+
+// ExpireHolds expires the ready holds that weren't picked up in time, rolling
+// their copies over to the next waiting holds, and returns how many expired.
+func (s *HoldService) ExpireHolds() (int64, error) {
+	var ids []uint
+	err := s.db.Model(&models.Hold{}).Where("status = ? AND expires_at < ?", models.HoldReady, s.now()).Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		s.logger.Error("error fetching expired holds", "error", err)
+		return 0, fmt.Errorf("error while expiring holds : %w", err)
+	}
+
+	var count int64
+	for _, id := range ids {
+		err := s.closeHold(id, models.HoldExpired, models.EventHoldExpired, models.HoldReady)
+		if errors.Is(err, ErrHoldClosed) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("error expiring hold", "id", id, "error", err)
+			return count, fmt.Errorf("error while expiring holds : %w", err)
+		}
+		count++
+	}
+	s.logger.Info("expired holds", "count", count)
+	return count, nil
+}
+
+// RunHoldExpiry expires holds that weren't picked up in time, once right away
+// and then on every interval, until ctx is done.
+func (s *HoldService) RunHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.ExpireHolds()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetPatronNotifications returns a page of the notifications of a patron, the
+// most recent first.
+func (s *HoldService) GetPatronNotifications(patronID uint, page, limit int) ([]*models.Notification, int64, error) {
+	var count int64
+	if err := s.db.Model(&models.Patron{}).Where("id = ?", patronID).Count(&count).Error; err != nil {
+		s.logger.Error("error fetching patron", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching the patron : %w", err)
+	}
+	if count == 0 {
+		s.logger.Warn("patron not found", "id", patronID)
+		return nil, 0, fmt.Errorf("%w: id %d", ErrPatronNotFound, patronID)
+	}
+
+	db := s.db.Model(&models.Notification{}).Where("patron_id = ?", patronID)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting notifications", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while counting notifications : %w", err)
+	}
+
+	var notifications []*models.Notification
+	offset := (page - 1) * limit
+	if err := db.Order("id DESC").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+		s.logger.Error("error fetching notifications", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching notifications : %w", err)
+	}
+	s.logger.Info("fetched notifications", "id", patronID, "count", len(notifications), "total", total, "page", page, "limit", limit)
+	return notifications, total, nil
+}
+
+// closeHold moves the hold with the given id from one of the from statuses to
+// status, notifies its patron of event and releases its copy, if any.
+func (s *HoldService) closeHold(id uint, status, event string, from ...string) error {
+	now := s.now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var hold models.Hold
+		if err := tx.First(&hold, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id %d", ErrHoldNotFound, id)
+			}
+			return err
+		}
+
+		result := tx.Model(&models.Hold{}).Where("id = ? AND status IN ?", id, from).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: id %d is %s", ErrHoldClosed, id, hold.Status)
+		}
+		if err := notify(tx, &hold, event); err != nil {
+			return err
+		}
+		return releaseHold(tx, &hold, now, s.policy.HoldPickup)
+	})
+}
+
+// isRejectedHold reports whether err is a circulation rule rejecting a hold
+// request rather than a failure.
+func isRejectedHold(err error) bool {
+	return slices.ContainsFunc([]error{ErrNotFound, ErrUnknownPatron, ErrUnknownBranch, ErrDuplicateHold, ErrHoldNotFound, ErrHoldClosed}, func(target error) bool {
+		return errors.Is(err, target)
+	})
+}
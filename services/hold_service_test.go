@@ -0,0 +1,196 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolds(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	branchService := NewBranchService(service.db, service.logger)
+	copyService := NewCopyService(service.db, service.logger)
+	patronService := NewPatronService(service.db, service.logger)
+	policy := LoanPolicy{Period: 14 * 24 * time.Hour, RenewalLimit: 1, HoldPickup: 3 * 24 * time.Hour}
+	loanService := NewLoanService(service.db, service.logger, policy)
+	holdService := NewHoldService(service.db, service.logger, policy)
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	loanService.now = func() time.Time { return now }
+	holdService.now = func() time.Time { return now }
+
+	central, err := branchService.CreateBranch(&models.Branch{Name: "Central"})
+	assert.NoError(t, err)
+	east, err := branchService.CreateBranch(&models.Branch{Name: "East"})
+	assert.NoError(t, err)
+	only, err := copyService.CreateCopy(&models.Copy{Barcode: "C001", BookID: 1, BranchID: central.ID})
+	assert.NoError(t, err)
+
+	patron := func(name string) *models.Patron {
+		created, err := patronService.CreatePatron(&models.Patron{Name: name, Email: name + "@example.com"})
+		assert.NoError(t, err)
+		return created
+	}
+	ada, bob, cy := patron("ada"), patron("bob"), patron("cy")
+
+	loan, err := loanService.Checkout(CheckoutRequest{PatronID: ada.ID, CopyID: only.ID})
+	assert.NoError(t, err)
+
+	events := func(patronID uint) []string {
+		notifications, _, err := holdService.GetPatronNotifications(patronID, 1, 10)
+		assert.NoError(t, err)
+		var events []string
+		for _, notification := range notifications {
+			events = append(events, notification.Event)
+		}
+		return events
+	}
+
+	var bobHold, cyHold *models.Hold
+	t.Run("holds queue up first come first served", func(t *testing.T) {
+		bobHold, err = holdService.PlaceHold(&models.Hold{BookID: 1, PatronID: bob.ID, PickupBranchID: east.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldWaiting, bobHold.Status)
+		assert.Equal(t, 1, bobHold.Position)
+
+		cyHold, err = holdService.PlaceHold(&models.Hold{BookID: 1, PatronID: cy.ID, PickupBranchID: central.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cyHold.Position)
+
+		_, err = holdService.PlaceHold(&models.Hold{BookID: 1, PatronID: bob.ID, PickupBranchID: central.ID})
+		assert.ErrorIs(t, err, ErrDuplicateHold)
+
+		_, err = holdService.PlaceHold(&models.Hold{BookID: 99, PatronID: bob.ID, PickupBranchID: central.ID})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = holdService.PlaceHold(&models.Hold{BookID: 1, PatronID: 99, PickupBranchID: central.ID})
+		assert.ErrorIs(t, err, ErrUnknownPatron)
+
+		_, err = holdService.PlaceHold(&models.Hold{BookID: 2, PatronID: bob.ID, PickupBranchID: 99})
+		assert.ErrorIs(t, err, ErrUnknownBranch)
+
+		holds, total, err := holdService.GetBookHolds(1, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []int{1, 2}, []int{holds[0].Position, holds[1].Position})
+	})
+
+	t.Run("a returned copy is set aside for the next hold", func(t *testing.T) {
+		returned, err := loanService.ReturnLoan(loan.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.CopyOnHold, returned.Copy.Status)
+
+		hold, err := holdService.GetHold(bobHold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, only.ID, *hold.CopyID)
+		assert.True(t, hold.ExpiresAt.Equal(now.Add(3*24*time.Hour)))
+		assert.Equal(t, []string{models.EventHoldReady, models.EventHoldPlaced}, events(bob.ID))
+
+		hold, err = holdService.GetHold(cyHold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, hold.Position)
+
+		_, err = loanService.Checkout(CheckoutRequest{PatronID: ada.ID, CopyID: only.ID})
+		assert.ErrorIs(t, err, ErrCopyUnavailable)
+
+		_, err = copyService.DeleteCopy(only.ID)
+		assert.ErrorIs(t, err, ErrCopyOnHold)
+	})
+
+	t.Run("an expired hold rolls over to the next patron", func(t *testing.T) {
+		count, err := holdService.ExpireHolds()
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+
+		now = now.Add(4 * 24 * time.Hour)
+		count, err = holdService.ExpireHolds()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		hold, err := holdService.GetHold(bobHold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldExpired, hold.Status)
+		assert.Equal(t, models.EventHoldExpired, events(bob.ID)[0])
+
+		hold, err = holdService.GetHold(cyHold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, only.ID, *hold.CopyID)
+
+		_, err = holdService.CancelHold(bobHold.ID)
+		assert.ErrorIs(t, err, ErrHoldClosed)
+	})
+
+	t.Run("checking out a held copy fulfils the hold", func(t *testing.T) {
+		loan, err := loanService.Checkout(CheckoutRequest{PatronID: cy.ID, CopyID: only.ID})
+		assert.NoError(t, err)
+
+		hold, err := holdService.GetHold(cyHold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldFulfilled, hold.Status)
+		assert.Equal(t, []string{models.EventHoldFulfilled, models.EventHoldReady, models.EventHoldPlaced}, events(cy.ID))
+
+		_, total, err := holdService.GetBookHolds(1, 1, 10)
+		assert.NoError(t, err)
+		assert.Zero(t, total)
+
+		_, err = loanService.ReturnLoan(loan.ID)
+		assert.NoError(t, err)
+		bookCopy, err := copyService.GetCopy(only.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.CopyAvailable, bookCopy.Status)
+	})
+
+	t.Run("a hold on an available copy is ready right away", func(t *testing.T) {
+		hold, err := holdService.PlaceHold(&models.Hold{BookID: 1, PatronID: ada.ID, PickupBranchID: central.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldReady, hold.Status)
+
+		cancelled, err := holdService.CancelHold(hold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldCancelled, cancelled.Status)
+
+		bookCopy, err := copyService.GetCopy(only.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.CopyAvailable, bookCopy.Status)
+	})
+	t.Run("holds of other tenants are out of reach", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		ours, theirs := holdService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), holdService.ForTenant(springfield)
+
+		_, err = theirs.PlaceHold(&models.Hold{BookID: 1, PatronID: bob.ID, PickupBranchID: central.ID})
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, _, err = theirs.GetBookHolds(1, 1, 10)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = theirs.GetHold(bobHold.ID)
+		assert.ErrorIs(t, err, ErrHoldNotFound)
+		_, err = theirs.CancelHold(cyHold.ID)
+		assert.ErrorIs(t, err, ErrHoldNotFound)
+
+		theirBook, err := service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Thirteen", Author: "Author M", Year: 2024})
+		assert.NoError(t, err)
+		_, err = theirs.PlaceHold(&models.Hold{BookID: theirBook.ID, PatronID: bob.ID, PickupBranchID: central.ID})
+		assert.ErrorIs(t, err, ErrUnknownPatron)
+		dee, err := patronService.ForTenant(springfield).CreatePatron(&models.Patron{Name: "dee", Email: "dee@example.com"})
+		assert.NoError(t, err)
+		theirHold, err := theirs.PlaceHold(&models.Hold{BookID: theirBook.ID, PatronID: dee.ID, PickupBranchID: central.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldWaiting, theirHold.Status)
+
+		_, err = ours.GetHold(theirHold.ID)
+		assert.ErrorIs(t, err, ErrHoldNotFound)
+		notifications, total, err := theirs.GetPatronNotifications(dee.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, theirHold.ID, notifications[0].HoldID)
+		_, _, err = ours.GetPatronNotifications(dee.ID, 1, 10)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		_, _, err = theirs.GetPatronNotifications(bob.ID, 1, 10)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+	})
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+// notify records an event about hold for its patron.
+func notify(db *gorm.DB, hold *models.Hold, event string) error {
+	return db.Create(&models.Notification{PatronID: hold.PatronID, Event: event, HoldID: hold.ID, BookID: hold.BookID}).Error
+}
+
+// fillHolds sets available copies of the book with the given id aside for the
+// waiting holds on it, first come first served, until either runs out. A copy
+// at the pickup branch of a hold is preferred. Each hold is ready for pickup
+// until now plus pickup.
+func fillHolds(db *gorm.DB, bookID uint, now time.Time, pickup time.Duration) error {
+	for {
+		var hold models.Hold
+		err := db.Where("book_id = ? AND status = ?", bookID, models.HoldWaiting).Order("id").First(&hold).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var bookCopy models.Copy
+		err = db.Where("book_id = ? AND status = ?", bookID, models.CopyAvailable).
+			Order(gorm.Expr("branch_id = ? DESC, barcode", hold.PickupBranchID)).
+			First(&bookCopy).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := db.Model(&models.Copy{}).
+			Where("id = ? AND status = ?", bookCopy.ID, models.CopyAvailable).
+			Update("status", models.CopyOnHold)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		expiresAt := now.Add(pickup)
+		hold.Status, hold.CopyID, hold.ReadyAt, hold.ExpiresAt = models.HoldReady, &bookCopy.ID, &now, &expiresAt
+		if err := db.Model(&hold).Select("status", "copy_id", "ready_at", "expires_at").Updates(&hold).Error; err != nil {
+			return err
+		}
+		if err := notify(db, &hold, models.EventHoldReady); err != nil {
+			return err
+		}
+	}
+}
+
+// releaseHold puts the copy set aside for hold back into circulation, for the
+// next waiting hold on the book or on the shelf.
+func releaseHold(db *gorm.DB, hold *models.Hold, now time.Time, pickup time.Duration) error {
+	if hold.CopyID == nil {
+		return nil
+	}
+	err := db.Model(&models.Copy{}).
+		Where("id = ? AND status = ?", *hold.CopyID, models.CopyOnHold).
+		Update("status", models.CopyAvailable).Error
+	if err != nil {
+		return err
+	}
+	return fillHolds(db, hold.BookID, now, pickup)
+}
+
+// fulfilHold closes the hold patronID has on the book of bookCopy as they
+// check the copy out. A copy set aside for a hold can only be checked out by
+// the patron of the hold.
+func fulfilHold(db *gorm.DB, bookCopy *models.Copy, patronID uint) error {
+	query := db.Where("patron_id = ? AND book_id = ? AND status = ?", patronID, bookCopy.BookID, models.HoldWaiting)
+	if bookCopy.Status == models.CopyOnHold {
+		query = db.Where("patron_id = ? AND copy_id = ? AND status = ?", patronID, bookCopy.ID, models.HoldReady)
+	}
+
+	var hold models.Hold
+	err := query.Order("id").First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if bookCopy.Status == models.CopyOnHold {
+			return fmt.Errorf("%w: copy %d is set aside for another patron", ErrCopyUnavailable, bookCopy.ID)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := db.Model(&hold).Update("status", models.HoldFulfilled).Error; err != nil {
+		return err
+	}
+	return notify(db, &hold, models.EventHoldFulfilled)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportRejected  = "rejected"
+)
+
+// ImportResult is the outcome of importing the book at the same position,
+// along with why it was rejected.
+type ImportResult struct {
+	Status string
+	Book   *models.Book
+	Err    error
+}
+
+// errDryRun rolls back the books created by a dry run.
+var errDryRun = errors.New("dry run")
+
+// ImportBooks creates every book that isn't already in the catalog, within a
+// single transaction. A book is a duplicate when a book with the same title,
+// author and year exists, ignoring case, or with the same ISBN, or appears
+// earlier in books. Books rejected like CreateBook rejects them, eg: for a
+// missing ISBN the tenant requires, are reported without failing the others.
+// With dryRun the books are created the same way and then rolled back, so the
+// results tell what would happen.
+func (s *BookService) ImportBooks(books []*models.Book, dryRun bool) ([]ImportResult, error) {
+	results := make([]ImportResult, len(books))
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		seen := make(map[string]bool)
+		for i, book := range books {
+			if book.ISBN != nil {
+				if isbn, ok := models.NormalizeISBN(*book.ISBN); ok {
+					book.ISBN = &isbn
+				}
+			}
+
+			keys := duplicateKeys(book)
+			if slices.ContainsFunc(keys, func(key string) bool { return seen[key] }) {
+				results[i] = ImportResult{Status: ImportDuplicate, Book: book}
+				continue
+			}
+
+			duplicates := tx.Unscoped().Model(&models.Book{}).
+				Where("deleted_at IS NULL AND title = ? COLLATE NOCASE AND author = ? COLLATE NOCASE AND year = ?", book.Title, book.Author, book.Year)
+			if book.ISBN != nil {
+				duplicates = duplicates.Or("isbn = ?", *book.ISBN)
+			}
+
+			var count int64
+			err := duplicates.Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				results[i] = ImportResult{Status: ImportDuplicate, Book: book}
+				continue
+			}
+
+			// a dry run leaves the book as it was given
+			created := book
+			if dryRun {
+				created = new(models.Book)
+				*created = *book
+			}
+			if _, err := s.withDB(tx).CreateBook(created); err != nil {
+				if !isRejectedImport(err) {
+					return err
+				}
+				results[i] = ImportResult{Status: ImportRejected, Book: book, Err: err}
+				continue
+			}
+			for _, key := range keys {
+				seen[key] = true
+			}
+			results[i] = ImportResult{Status: ImportCreated, Book: book}
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		s.logger.Error("error importing books", "count", len(books), "error", err)
+		return nil, fmt.Errorf("error while importing books : %w", err)
+	}
+	s.logger.Info("imported books", "count", len(books), "dry_run", dryRun)
+	return results, nil
+}
+
+// isRejectedImport reports whether err rejects a single imported book.
+func isRejectedImport(err error) bool {
+	return isInvalidBook(err) || errors.Is(err, ErrISBNRequired) || errors.Is(err, ErrInvalidISBN) || errors.Is(err, ErrDuplicateISBN)
+}
+
+func duplicateKeys(book *models.Book) []string {
+	keys := []string{fmt.Sprintf("%s\x00%s\x00%d", strings.ToLower(book.Title), strings.ToLower(book.Author), book.Year)}
+	if book.ISBN != nil {
+		keys = append(keys, "isbn\x00"+*book.ISBN)
+	}
+	return keys
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLoanNotFound    = errors.New("loan not found")
+	ErrLoanReturned    = errors.New("loan already returned")
+	ErrRenewalLimit    = errors.New("renewal limit reached")
+	ErrCopyUnavailable = errors.New("copy is not available")
+	ErrUnknownCopy     = errors.New("unknown copy")
+)
+
+// LoanPolicy sets how long copies are lent for, how many times a loan can be
+// renewed and how long a copy set aside for a hold waits for pickup.
+type LoanPolicy struct {
+	Period       time.Duration
+	RenewalLimit int
+	HoldPickup   time.Duration
+}
+
+// DefaultLoanPolicy lends copies for three weeks, renewable twice, and keeps
+// held copies for a week.
+var DefaultLoanPolicy = LoanPolicy{Period: 21 * 24 * time.Hour, RenewalLimit: 2, HoldPickup: 7 * 24 * time.Hour}
+
+// CheckoutRequest asks to lend a copy, given by id or by barcode, to a patron.
+type CheckoutRequest struct {
+	PatronID uint   `json:"patron_id" validate:"required"`
+	CopyID   uint   `json:"copy_id" validate:"required_without=Barcode"`
+	Barcode  string `json:"barcode" validate:"omitempty,max=64"`
+}
+
+// LoanFilter narrows down the loans of a patron. An overdue loan is an active
+// loan past its due date.
+type LoanFilter struct {
+	Status string `query:"status" validate:"omitempty,oneof=active returned overdue"`
+}
+
+type ILoanService interface {
+	Checkout(request CheckoutRequest) (*models.Loan, error)
+	GetLoan(id uint) (*models.Loan, error)
+	ReturnLoan(id uint) (*models.Loan, error)
+	RenewLoan(id uint) (*models.Loan, error)
+	GetPatronLoans(patronID uint, filter LoanFilter, page, limit int) ([]*models.Loan, int64, error)
+	ForTenant(tenant *models.Tenant) ILoanService
+}
+
+var _ ILoanService = (*LoanService)(nil)
+
+type LoanService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+	policy LoanPolicy
+	now    func() time.Time
+}
+
+func NewLoanService(db *gorm.DB, logger *slog.Logger, policy LoanPolicy) *LoanService {
+	return &LoanService{db: db, logger: logger, policy: policy, now: time.Now}
+}
+
+// ForTenant returns a service that only lends the copies of the books of
+// tenant to the patrons of tenant, and only reads and writes their loans.
+func (s *LoanService) ForTenant(tenant *models.Tenant) ILoanService {
+	return &LoanService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID), policy: s.policy, now: s.now}
+}
+
+// Checkout lends a copy to a patron until the end of the loan period. The copy
+// is marked on loan in the same transaction, and only if it is available, so
+// a copy is never lent twice at once. A copy set aside for a hold can only be
+// checked out by the patron of the hold, which fulfils it.
+func (s *LoanService) Checkout(request CheckoutRequest) (*models.Loan, error) {
+	now := s.now()
+	var loan models.Loan
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Patron{}).Where("id = ?", request.PatronID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: id %d", ErrUnknownPatron, request.PatronID)
+		}
+
+		var bookCopy models.Copy
+		db := tx.Preload("Branch")
+		if request.CopyID != 0 {
+			db = db.Where("id = ?", request.CopyID)
+		} else {
+			db = db.Where("barcode = ?", normalizeBarcode(request.Barcode))
+		}
+		if err := db.First(&bookCopy).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id %d, barcode %q", ErrUnknownCopy, request.CopyID, request.Barcode)
+			}
+			return err
+		}
+
+		if bookCopy.Status != models.CopyAvailable && bookCopy.Status != models.CopyOnHold {
+			return fmt.Errorf("%w: copy %d is %s", ErrCopyUnavailable, bookCopy.ID, bookCopy.Status)
+		}
+		if err := fulfilHold(tx, &bookCopy, request.PatronID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Copy{}).
+			Where("id = ? AND status = ?", bookCopy.ID, bookCopy.Status).
+			Update("status", models.CopyOnLoan)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: copy %d is %s", ErrCopyUnavailable, bookCopy.ID, bookCopy.Status)
+		}
+		bookCopy.Status = models.CopyOnLoan
+
+		loan = models.Loan{
+			CopyID:       bookCopy.ID,
+			PatronID:     request.PatronID,
+			CheckedOutAt: now,
+			DueAt:        now.Add(s.policy.Period),
+		}
+		if err := tx.Create(&loan).Error; err != nil {
+			return err
+		}
+		loan.Copy = &bookCopy
+		return nil
+	})
+	if err != nil {
+		if isRejectedLoan(err) {
+			s.logger.Warn("checkout rejected", "patron_id", request.PatronID, "copy_id", request.CopyID, "barcode", request.Barcode, "error", err)
+			return nil, err
+		}
+		s.logger.Error("error checking out copy", "patron_id", request.PatronID, "copy_id", request.CopyID, "error", err)
+		return nil, fmt.Errorf("error while checking out the copy : %w", err)
+	}
+	s.logger.Info("checked out copy", "loan_id", loan.ID, "copy_id", loan.CopyID, "patron_id", loan.PatronID, "due_at", loan.DueAt)
+	return &loan, nil
+}
+
+func (s *LoanService) GetLoan(id uint) (*models.Loan, error) {
+	var loan models.Loan
+	if err := s.db.Preload("Copy.Branch").First(&loan, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("loan not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrLoanNotFound, id)
+		}
+		s.logger.Error("error fetching loan", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the loan : %w", err)
+	}
+	s.logger.Info("fetched loan", "id", id)
+	return &loan, nil
+}
+
+// ReturnLoan closes a loan and makes its copy available again, or sets it
+// aside for the next waiting hold on its book. The patron is charged the
+// fine owed on the loan, if any.
+func (s *LoanService) ReturnLoan(id uint) (*models.Loan, error) {
+	now := s.now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Loan{}).Where("id = ? AND returned_at IS NULL", id).Update("returned_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var loan models.Loan
+			if err := tx.First(&loan, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: id %d", ErrLoanNotFound, id)
+				}
+				return err
+			}
+			return fmt.Errorf("%w: id %d on %s", ErrLoanReturned, id, loan.ReturnedAt.Format(time.DateOnly))
+		}
+
+		var loan models.Loan
+		if err := tx.First(&loan, id).Error; err != nil {
+			return err
+		}
+		if _, err := accrueFine(tx, &loan, now); err != nil {
+			return err
+		}
+
+		var bookCopy models.Copy
+		if err := tx.First(&bookCopy, loan.CopyID).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.Copy{}).
+			Where("id = ? AND status = ?", bookCopy.ID, models.CopyOnLoan).
+			Update("status", models.CopyAvailable).Error
+		if err != nil {
+			return err
+		}
+		return fillHolds(tx, bookCopy.BookID, now, s.policy.HoldPickup)
+	})
+	if err != nil {
+		if isRejectedLoan(err) {
+			s.logger.Warn("return rejected", "id", id, "error", err)
+			return nil, err
+		}
+		s.logger.Error("error returning loan", "id", id, "error", err)
+		return nil, fmt.Errorf("error while returning the loan : %w", err)
+	}
+	s.logger.Info("returned loan", "id", id)
+	return s.GetLoan(id)
+}
+
+// RenewLoan lends the copy of a loan for another loan period from now, as long
+// as the loan hasn't been renewed as many times as the policy allows. A
+// renewal never brings the due date forward.
+func (s *LoanService) RenewLoan(id uint) (*models.Loan, error) {
+	now := s.now()
+	var loan models.Loan
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&loan, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id %d", ErrLoanNotFound, id)
+			}
+			return err
+		}
+		if loan.ReturnedAt != nil {
+			return fmt.Errorf("%w: id %d", ErrLoanReturned, id)
+		}
+		if loan.Renewals >= s.policy.RenewalLimit {
+			return fmt.Errorf("%w: renewed %d times", ErrRenewalLimit, loan.Renewals)
+		}
+
+		loan.Renewals++
+		if due := now.Add(s.policy.Period); due.After(loan.DueAt) {
+			loan.DueAt = due
+		}
+		return tx.Model(&loan).Select("renewals", "due_at").Updates(&loan).Error
+	})
+	if err != nil {
+		if isRejectedLoan(err) {
+			s.logger.Warn("renewal rejected", "id", id, "error", err)
+			return nil, err
+		}
+		s.logger.Error("error renewing loan", "id", id, "error", err)
+		return nil, fmt.Errorf("error while renewing the loan : %w", err)
+	}
+	s.logger.Info("renewed loan", "id", id, "renewals", loan.Renewals, "due_at", loan.DueAt)
+	return s.GetLoan(id)
+}
+
+// GetPatronLoans returns a page of the loans of a patron matching filter, the
+// most recent first.
+func (s *LoanService) GetPatronLoans(patronID uint, filter LoanFilter, page, limit int) ([]*models.Loan, int64, error) {
+	var count int64
+	if err := s.db.Model(&models.Patron{}).Where("id = ?", patronID).Count(&count).Error; err != nil {
+		s.logger.Error("error fetching patron", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching the patron : %w", err)
+	}
+	if count == 0 {
+		s.logger.Warn("patron not found", "id", patronID)
+		return nil, 0, fmt.Errorf("%w: id %d", ErrPatronNotFound, patronID)
+	}
+
+	db := s.db.Model(&models.Loan{}).Where("patron_id = ?", patronID)
+	switch filter.Status {
+	case "active":
+		db = db.Where("returned_at IS NULL")
+	case "returned":
+		db = db.Where("returned_at IS NOT NULL")
+	case "overdue":
+		db = db.Where("returned_at IS NULL AND due_at < ?", s.now())
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting patron loans", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while counting loans : %w", err)
+	}
+
+	var loans []*models.Loan
+	offset := (page - 1) * limit
+	if err := db.Preload("Copy.Branch").Order("checked_out_at DESC, id DESC").Limit(limit).Offset(offset).Find(&loans).Error; err != nil {
+		s.logger.Error("error fetching patron loans", "id", patronID, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching loans : %w", err)
+	}
+	s.logger.Info("fetched patron loans", "id", patronID, "count", len(loans), "total", total, "page", page, "limit", limit)
+	return loans, total, nil
+}
+
+// isRejectedLoan reports whether err is a circulation rule rejecting a request
+// rather than a failure.
+func isRejectedLoan(err error) bool {
+	return slices.ContainsFunc([]error{ErrLoanNotFound, ErrLoanReturned, ErrRenewalLimit, ErrCopyUnavailable, ErrUnknownCopy, ErrUnknownPatron}, func(target error) bool {
+		return errors.Is(err, target)
+	})
+}
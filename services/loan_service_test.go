@@ -0,0 +1,223 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestLoans(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	branchService := NewBranchService(service.db, service.logger)
+	copyService := NewCopyService(service.db, service.logger)
+	patronService := NewPatronService(service.db, service.logger)
+	loanService := NewLoanService(service.db, service.logger, LoanPolicy{Period: 14 * 24 * time.Hour, RenewalLimit: 1})
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	loanService.now = func() time.Time { return now }
+
+	branch, err := branchService.CreateBranch(&models.Branch{Name: "Central"})
+	assert.NoError(t, err)
+	first, err := copyService.CreateCopy(&models.Copy{Barcode: "C001", BookID: 1, BranchID: branch.ID})
+	assert.NoError(t, err)
+	_, err = copyService.CreateCopy(&models.Copy{Barcode: "C002", BookID: 2, BranchID: branch.ID, Status: models.CopyInRepair})
+	assert.NoError(t, err)
+
+	ada, err := patronService.CreatePatron(&models.Patron{Name: "Ada", Email: "ada@example.com"})
+	assert.NoError(t, err)
+	bob, err := patronService.CreatePatron(&models.Patron{Name: "Bob", Email: "bob@example.com"})
+	assert.NoError(t, err)
+
+	t.Run("patron emails are unique", func(t *testing.T) {
+		_, err := patronService.CreatePatron(&models.Patron{Name: "Ada Again", Email: "ADA@example.com"})
+		assert.ErrorIs(t, err, ErrDuplicatePatron)
+
+		patrons, total, err := patronService.GetAllPatrons("bob@", 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, bob.ID, patrons[0].ID)
+	})
+
+	var loan *models.Loan
+	t.Run("checkout lends an available copy", func(t *testing.T) {
+		loan, err = loanService.Checkout(CheckoutRequest{PatronID: ada.ID, Barcode: " c001 "})
+		assert.NoError(t, err)
+		assert.Equal(t, first.ID, loan.CopyID)
+		assert.Equal(t, now.Add(14*24*time.Hour), loan.DueAt)
+		assert.Equal(t, models.CopyOnLoan, loan.Copy.Status)
+
+		_, err = loanService.Checkout(CheckoutRequest{PatronID: bob.ID, CopyID: first.ID})
+		assert.ErrorIs(t, err, ErrCopyUnavailable)
+
+		_, err = loanService.Checkout(CheckoutRequest{PatronID: bob.ID, Barcode: "C002"})
+		assert.ErrorIs(t, err, ErrCopyUnavailable)
+
+		_, err = loanService.Checkout(CheckoutRequest{PatronID: bob.ID, Barcode: "C999"})
+		assert.ErrorIs(t, err, ErrUnknownCopy)
+
+		_, err = loanService.Checkout(CheckoutRequest{PatronID: 99, CopyID: first.ID})
+		assert.ErrorIs(t, err, ErrUnknownPatron)
+	})
+
+	t.Run("a lent copy and its patron can't be removed", func(t *testing.T) {
+		_, err := copyService.DeleteCopy(first.ID)
+		assert.ErrorIs(t, err, ErrCopyOnLoan)
+
+		_, err = copyService.UpdateCopy(&models.Copy{Model: first.Model, Barcode: "C001", BookID: 1, BranchID: branch.ID, Status: models.CopyAvailable})
+		assert.ErrorIs(t, err, ErrCopyOnLoan)
+
+		_, err = patronService.DeletePatron(ada.ID)
+		assert.ErrorIs(t, err, ErrPatronInUse)
+	})
+
+	t.Run("renewals are limited", func(t *testing.T) {
+		now = now.Add(10 * 24 * time.Hour)
+		renewed, err := loanService.RenewLoan(loan.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, renewed.Renewals)
+		assert.True(t, renewed.DueAt.Equal(now.Add(14*24*time.Hour)))
+
+		_, err = loanService.RenewLoan(loan.ID)
+		assert.ErrorIs(t, err, ErrRenewalLimit)
+	})
+
+	t.Run("patron loans are filtered by status", func(t *testing.T) {
+		_, total, err := loanService.GetPatronLoans(ada.ID, LoanFilter{Status: "active"}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+
+		_, total, _ = loanService.GetPatronLoans(ada.ID, LoanFilter{Status: "overdue"}, 1, 10)
+		assert.Equal(t, int64(0), total)
+
+		now = now.Add(30 * 24 * time.Hour)
+		loans, total, _ := loanService.GetPatronLoans(ada.ID, LoanFilter{Status: "overdue"}, 1, 10)
+		assert.Equal(t, int64(1), total)
+		assert.True(t, loans[0].Overdue(now))
+
+		_, _, err = loanService.GetPatronLoans(99, LoanFilter{}, 1, 10)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+	})
+
+	t.Run("return makes the copy available again", func(t *testing.T) {
+		returned, err := loanService.ReturnLoan(loan.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, returned.ReturnedAt)
+		assert.Equal(t, models.CopyAvailable, returned.Copy.Status)
+
+		_, err = loanService.ReturnLoan(loan.ID)
+		assert.ErrorIs(t, err, ErrLoanReturned)
+
+		_, err = loanService.RenewLoan(loan.ID)
+		assert.ErrorIs(t, err, ErrLoanReturned)
+
+		_, err = loanService.ReturnLoan(99)
+		assert.ErrorIs(t, err, ErrLoanNotFound)
+
+		again, err := loanService.Checkout(CheckoutRequest{PatronID: bob.ID, CopyID: first.ID})
+		assert.NoError(t, err)
+		assert.NotEqual(t, loan.ID, again.ID)
+
+		_, err = patronService.DeletePatron(ada.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("books with loan history are kept", func(t *testing.T) {
+		_, err := service.DeleteBook(1, 0)
+		assert.NoError(t, err)
+
+		_, err = service.PurgeBook(1, 0)
+		assert.ErrorIs(t, err, ErrHasLoans)
+
+		count, err := service.PurgeTrash(time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+
+		kept, err := loanService.GetLoan(loan.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "C001", kept.Copy.Barcode)
+
+		_, err = service.DeleteBook(2, 0)
+		assert.NoError(t, err)
+		count, err = service.PurgeTrash(time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("loans of other tenants are out of reach", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		ours, theirs := loanService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), loanService.ForTenant(springfield)
+		cy, err := patronService.ForTenant(springfield).CreatePatron(&models.Patron{Name: "Cy", Email: "cy@example.com"})
+		assert.NoError(t, err)
+
+		theirBook, err := service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Fourteen", Author: "Author N", Year: 2024})
+		assert.NoError(t, err)
+		_, err = copyService.ForTenant(springfield).CreateCopy(&models.Copy{Barcode: "S001", BookID: theirBook.ID, BranchID: branch.ID})
+		assert.NoError(t, err)
+
+		_, err = ours.Checkout(CheckoutRequest{PatronID: bob.ID, Barcode: "S001"})
+		assert.ErrorIs(t, err, ErrUnknownCopy)
+		_, err = ours.Checkout(CheckoutRequest{PatronID: cy.ID, Barcode: "C001"})
+		assert.ErrorIs(t, err, ErrUnknownPatron)
+		_, err = theirs.Checkout(CheckoutRequest{PatronID: bob.ID, Barcode: "S001"})
+		assert.ErrorIs(t, err, ErrUnknownPatron)
+		theirLoan, err := theirs.Checkout(CheckoutRequest{PatronID: cy.ID, Barcode: "S001"})
+		assert.NoError(t, err)
+
+		_, err = ours.GetLoan(theirLoan.ID)
+		assert.ErrorIs(t, err, ErrLoanNotFound)
+		_, err = ours.RenewLoan(theirLoan.ID)
+		assert.ErrorIs(t, err, ErrLoanNotFound)
+		_, err = ours.ReturnLoan(theirLoan.ID)
+		assert.ErrorIs(t, err, ErrLoanNotFound)
+		_, err = theirs.GetLoan(loan.ID)
+		assert.ErrorIs(t, err, ErrLoanNotFound)
+
+		_, _, err = ours.GetPatronLoans(cy.ID, LoanFilter{}, 1, 10)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		loans, total, err := theirs.GetPatronLoans(cy.ID, LoanFilter{}, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, theirLoan.ID, loans[0].ID)
+
+		returned, err := theirs.ReturnLoan(theirLoan.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, returned.ReturnedAt)
+	})
+
+	t.Run("patrons are members of a tenant", func(t *testing.T) {
+		shelbyville, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "shelbyville", Name: "Shelbyville Elementary"})
+		assert.NoError(t, err)
+		ours, theirs := patronService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), patronService.ForTenant(shelbyville)
+
+		_, err = theirs.GetPatron(bob.ID)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		_, err = theirs.UpdatePatron(&models.Patron{Model: gorm.Model{ID: bob.ID}, Name: "Bob", Email: "pwned@example.com"})
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		_, err = theirs.DeletePatron(bob.ID)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		_, total, err := theirs.GetAllPatrons("", 1, 10)
+		assert.NoError(t, err)
+		assert.Zero(t, total)
+
+		// Emails are only unique within a tenant.
+		theirBob, err := theirs.CreatePatron(&models.Patron{Name: "Bob", Email: "bob@example.com"})
+		assert.NoError(t, err)
+		_, err = theirs.CreatePatron(&models.Patron{Name: "Bob Again", Email: "BOB@example.com"})
+		assert.ErrorIs(t, err, ErrDuplicatePatron)
+		patrons, total, err := theirs.GetAllPatrons("bob@", 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, theirBob.ID, patrons[0].ID)
+
+		_, err = ours.GetPatron(theirBob.ID)
+		assert.ErrorIs(t, err, ErrPatronNotFound)
+		patron, err := ours.GetPatron(bob.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "bob@example.com", patron.Email)
+	})
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPatronNotFound  = errors.New("patron not found")
+	ErrPatronInUse     = errors.New("patron has loans out or owes fines")
+	ErrDuplicatePatron = errors.New("patron already exists")
+	ErrUnknownPatron   = errors.New("unknown patron")
+)
+
+type IPatronService interface {
+	GetAllPatrons(search string, page, limit int) ([]*models.Patron, int64, error)
+	GetPatron(id uint) (*models.Patron, error)
+	CreatePatron(patron *models.Patron) (*models.Patron, error)
+	UpdatePatron(payload *models.Patron) (*models.Patron, error)
+	DeletePatron(id uint) (*models.Patron, error)
+	ForTenant(tenant *models.Tenant) IPatronService
+}
+
+var _ IPatronService = (*PatronService)(nil)
+
+// PatronService manages the patrons of every tenant, unless it was returned by
+// ForTenant.
+type PatronService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+	tenant *models.Tenant
+}
+
+func NewPatronService(db *gorm.DB, logger *slog.Logger) *PatronService {
+	return &PatronService{db: db, logger: logger}
+}
+
+// ForTenant returns a service that only reads and writes the patrons of
+// tenant, the patrons it creates being members of tenant. Emails are only
+// unique within a tenant.
+func (s *PatronService) ForTenant(tenant *models.Tenant) IPatronService {
+	return &PatronService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID), tenant: tenant}
+}
+
+// GetAllPatrons returns a page of patrons sorted by name. A non empty search
+// only returns the patrons whose name or email contains it.
+func (s *PatronService) GetAllPatrons(search string, page, limit int) ([]*models.Patron, int64, error) {
+	db := s.db.Model(&models.Patron{})
+	if search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		db = db.Where(`(name LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting patrons", "error", err)
+		return nil, 0, fmt.Errorf("error while counting patrons : %w", err)
+	}
+
+	var patrons []*models.Patron
+	offset := (page - 1) * limit
+	if err := db.Order("name COLLATE NOCASE, id").Limit(limit).Offset(offset).Find(&patrons).Error; err != nil {
+		s.logger.Error("error fetching patrons", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching patrons : %w", err)
+	}
+	s.logger.Info("fetched patrons", "count", len(patrons), "total", total, "page", page, "limit", limit)
+	return patrons, total, nil
+}
+
+func (s *PatronService) GetPatron(id uint) (*models.Patron, error) {
+	var patron models.Patron
+	if err := s.db.First(&patron, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("patron not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrPatronNotFound, id)
+		}
+		s.logger.Error("error fetching patron", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the patron : %w", err)
+	}
+	s.logger.Info("fetched patron", "id", patron.ID)
+	return &patron, nil
+}
+
+func (s *PatronService) CreatePatron(patron *models.Patron) (*models.Patron, error) {
+	patron.Name = models.NormalizeName(patron.Name)
+	patron.Email = strings.TrimSpace(patron.Email)
+	if s.tenant != nil {
+		patron.TenantID = s.tenant.ID
+	}
+	if err := s.checkEmail(patron); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(patron).Error; err != nil {
+		s.logger.Error("failed to create new patron", "error", err)
+		return nil, fmt.Errorf("failed to create new patron : %w", err)
+	}
+	s.logger.Info("created new patron", "id", patron.ID)
+	return patron, nil
+}
+
+func (s *PatronService) UpdatePatron(payload *models.Patron) (*models.Patron, error) {
+	patron, err := s.GetPatron(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	patron.Name = models.NormalizeName(payload.Name)
+	patron.Email = strings.TrimSpace(payload.Email)
+	if err := s.checkEmail(patron); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(patron).Error; err != nil {
+		s.logger.Error("error saving updated patron", "id", patron.ID, "error", err)
+		return nil, fmt.Errorf("error while saving the patron : %w", err)
+	}
+	s.logger.Info("updated patron", "id", patron.ID)
+	return patron, nil
+}
+
+// DeletePatron deletes a patron that has no loans out and owes nothing.
+func (s *PatronService) DeletePatron(id uint) (*models.Patron, error) {
+	patron, err := s.GetPatron(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Loan{}).Where("patron_id = ? AND returned_at IS NULL", id).Count(&count).Error; err != nil {
+		s.logger.Error("error counting patron loans", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the patron : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("patron to delete has loans out", "id", id, "loans", count)
+		return nil, fmt.Errorf("%w: %d loans", ErrPatronInUse, count)
+	}
+
+	balance, err := patronBalance(s.db, id)
+	if err != nil {
+		s.logger.Error("error summing up patron ledger", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the patron : %w", err)
+	}
+	if balance.Balance > 0 {
+		s.logger.Warn("patron to delete owes fines", "id", id, "balance", balance.Balance)
+		return nil, fmt.Errorf("%w: owes %d", ErrPatronInUse, balance.Balance)
+	}
+
+	if err := s.db.Delete(patron).Error; err != nil {
+		s.logger.Error("error deleting patron", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the patron : %w", err)
+	}
+	s.logger.Info("deleted patron", "id", id)
+	return patron, nil
+}
+
+// checkEmail makes sure no other patron has the email of patron, ignoring
+// case.
+func (s *PatronService) checkEmail(patron *models.Patron) error {
+	var count int64
+	err := s.db.Model(&models.Patron{}).
+		Where("email = ? COLLATE NOCASE AND id <> ?", patron.Email, patron.ID).
+		Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking patron email", "id", patron.ID, "error", err)
+		return fmt.Errorf("error while checking the patron email : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("patron already exists", "id", patron.ID)
+		return fmt.Errorf("%w: email already in use", ErrDuplicatePatron)
+	}
+	return nil
+}
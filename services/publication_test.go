@@ -0,0 +1,113 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestPublicationDetails(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	date := func(s string) *models.Date {
+		d, err := models.ParseDate(s)
+		assert.NoError(t, err)
+		return &d
+	}
+
+	hardcover, err := service.CreateBook(&models.Book{
+		Title: "Book Four", Author: "Author D", Year: 2003,
+		Publisher: &models.Publisher{Name: "Doubleday"}, Edition: "First edition",
+		Format: models.FormatHardcover, PageCount: 454, Language: "en-gb", PublishedOn: date("2003-03-18"),
+	})
+	assert.NoError(t, err)
+
+	t.Run("stored and read back", func(t *testing.T) {
+		book, err := service.GetBook(hardcover.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Doubleday", book.Publisher.Name)
+		assert.Equal(t, "First edition", book.Edition)
+		assert.Equal(t, models.FormatHardcover, book.Format)
+		assert.Equal(t, 454, book.PageCount)
+		assert.Equal(t, "en-GB", book.Language)
+		assert.Equal(t, "2003-03-18", book.PublishedOn.String())
+	})
+
+	t.Run("publishers are shared", func(t *testing.T) {
+		ebook, err := service.CreateBook(&models.Book{
+			Title: "Book Four", Author: "Author D", Year: 2004,
+			Publisher: &models.Publisher{Name: "doubleday"}, Format: models.FormatEbook, Language: "pt-br",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, *hardcover.PublisherID, *ebook.PublisherID)
+		assert.Equal(t, "pt-BR", ebook.Language)
+
+		paperback, err := service.CreateBook(&models.Book{
+			Title: "Book Four", Author: "Author D", Year: 2005,
+			Publisher: &models.Publisher{Model: gorm.Model{ID: *hardcover.PublisherID}}, Format: models.FormatPaperback, PageCount: 600,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Doubleday", paperback.Publisher.Name)
+	})
+
+	t.Run("must be consistent", func(t *testing.T) {
+		_, err := service.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2004, Publisher: &models.Publisher{Model: gorm.Model{ID: 99}}})
+		assert.ErrorIs(t, err, ErrUnknownPublisher)
+
+		_, err = service.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2004, PublishedOn: date("2003-03-18")})
+		assert.ErrorIs(t, err, ErrYearMismatch)
+
+		_, err = service.UpdateBook(&models.Book{Model: gorm.Model{ID: hardcover.ID}, Title: "Book Four", Author: "Author D", Year: 2003, Language: "not a tag!"})
+		assert.ErrorIs(t, err, ErrInvalidLanguage)
+	})
+
+	t.Run("cleared on update", func(t *testing.T) {
+		book, err := service.UpdateBook(&models.Book{Model: gorm.Model{ID: 1}, Title: "Book One", Author: "Author A", Year: 2021, Publisher: &models.Publisher{Name: "Penguin"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "Penguin", book.Publisher.Name)
+
+		book, err = service.UpdateBook(&models.Book{Model: gorm.Model{ID: 1}, Title: "Book One", Author: "Author A", Year: 2021})
+		assert.NoError(t, err)
+		assert.Nil(t, book.Publisher)
+
+		fetched, _ := service.GetBook(1)
+		assert.Nil(t, fetched.Publisher)
+		assert.Nil(t, fetched.PublisherID)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		count := func(query BookQuery) int64 {
+			query.Page, query.Limit = 1, 10
+			_, total, err := service.GetAllBooks(query)
+			assert.NoError(t, err)
+			return total
+		}
+
+		assert.Equal(t, int64(3), count(BookQuery{Publisher: "DOUBLEDAY"}))
+		assert.Equal(t, int64(3), count(BookQuery{PublisherID: *hardcover.PublisherID}))
+		assert.Equal(t, int64(1), count(BookQuery{Format: models.FormatEbook}))
+		assert.Equal(t, int64(1), count(BookQuery{Language: "en"}))
+		assert.Equal(t, int64(1), count(BookQuery{Language: "PT-br"}))
+		assert.Equal(t, int64(0), count(BookQuery{Language: "pt-PT"}))
+		assert.Equal(t, int64(1), count(BookQuery{PublishedFrom: "2003-01-01", PublishedTo: "2003-12-31"}))
+		assert.Equal(t, int64(0), count(BookQuery{PublishedFrom: "2003-03-19"}))
+		assert.Equal(t, int64(1), count(BookQuery{PagesMin: 500}))
+		assert.Equal(t, int64(1), count(BookQuery{PagesMax: 500}))
+	})
+
+	t.Run("exported", func(t *testing.T) {
+		var exported []*models.Book
+		err := service.ExportBooks(BookQuery{Format: models.FormatHardcover}, func(book *models.Book) error {
+			exported = append(exported, book)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, exported, 1)
+		assert.Equal(t, "Doubleday", exported[0].Publisher.Name)
+		assert.Len(t, exported[0].Authors, 1)
+	})
+
+}
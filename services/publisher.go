@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nsltharaka/booksapi/models"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownPublisher = errors.New("unknown publisher")
+	ErrYearMismatch     = errors.New("year does not match the publication date")
+	ErrInvalidLanguage  = errors.New("invalid language tag")
+)
+
+// describeBook checks the publication details of book and stores its
+// language tag in canonical form, eg: "pt-br" becomes "pt-BR".
+func describeBook(book *models.Book) error {
+	if book.Language != "" {
+		tag, err := language.Parse(book.Language)
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidLanguage, book.Language)
+		}
+		book.Language = tag.String()
+	}
+
+	if book.PublishedOn != nil && book.PublishedOn.Year() != book.Year {
+		return fmt.Errorf("%w: %s is not in %d", ErrYearMismatch, book.PublishedOn, book.Year)
+	}
+	return nil
+}
+
+// resolvePublisher links book to its publisher. A publisher given by id must
+// exist, one given by name only is created when no publisher has that name,
+// ignoring case. A book given neither has no publisher.
+func resolvePublisher(db *gorm.DB, book *models.Book) error {
+	publisher := book.Publisher
+
+	switch {
+	case publisher == nil || (publisher.ID == 0 && strings.TrimSpace(publisher.Name) == ""):
+		book.Publisher, book.PublisherID = nil, nil
+		return nil
+
+	case publisher.ID != 0:
+		var existing models.Publisher
+		if err := db.First(&existing, publisher.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id %d", ErrUnknownPublisher, publisher.ID)
+			}
+			return err
+		}
+		book.Publisher = &existing
+
+	default:
+		name := models.NormalizeName(publisher.Name)
+		var existing models.Publisher
+		err := db.Where("name = ? COLLATE NOCASE", name).Order("id").First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			existing = models.Publisher{Name: name}
+			err = db.Create(&existing).Error
+		}
+		if err != nil {
+			return err
+		}
+		book.Publisher = &existing
+	}
+
+	book.PublisherID = &book.Publisher.ID
+	return nil
+}
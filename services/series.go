@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownSeries = errors.New("unknown series")
+)
+
+// readingOrder orders the books of a series by position, the books without a
+// position coming last, by year.
+const readingOrder = "series_position IS NULL, series_position, year, id"
+
+// checkSeries makes sure the series of book exists.
+func checkSeries(db *gorm.DB, book *models.Book) error {
+	if book.SeriesID == nil {
+		book.SeriesPosition = nil
+		return nil
+	}
+
+	var count int64
+	if err := db.Model(&models.Series{}).Where("id = ?", *book.SeriesID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: id %d", ErrUnknownSeries, *book.SeriesID)
+	}
+	return nil
+}
+
+// GetSeriesNeighbors returns the ids of the books before and after book in the
+// reading order of its series. Either is zero when there is no such book, as
+// is the case for both when book isn't part of a series.
+func (s *BookService) GetSeriesNeighbors(book *models.Book) (previous, next uint, err error) {
+	if book.SeriesID == nil {
+		return 0, 0, nil
+	}
+
+	ordered := s.db.Model(&models.Book{}).
+		Select("id, LAG(id) OVER (ORDER BY "+readingOrder+") AS previous, LEAD(id) OVER (ORDER BY "+readingOrder+") AS next").
+		Where("series_id = ?", *book.SeriesID)
+
+	var neighbors struct {
+		Previous *uint
+		Next     *uint
+	}
+	if err := s.db.Table("(?) AS ordered", ordered).Select("previous, next").Where("id = ?", book.ID).Scan(&neighbors).Error; err != nil {
+		s.logger.Error("error fetching series neighbors", "id", book.ID, "series_id", *book.SeriesID, "error", err)
+		return 0, 0, fmt.Errorf("error while fetching the series neighbors : %w", err)
+	}
+
+	if neighbors.Previous != nil {
+		previous = *neighbors.Previous
+	}
+	if neighbors.Next != nil {
+		next = *neighbors.Next
+	}
+	return previous, next, nil
+}
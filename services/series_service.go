@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSeriesNotFound  = errors.New("series not found")
+	ErrSeriesInUse     = errors.New("series has books")
+	ErrDuplicateSeries = errors.New("series already exists")
+)
+
+type ISeriesService interface {
+	GetAllSeries(name string, page, limit int) ([]*models.Series, int64, error)
+	GetSeries(id uint) (*models.Series, error)
+	GetSeriesBooks(id uint, page, limit int) ([]*models.Book, int64, error)
+	CreateSeries(series *models.Series) (*models.Series, error)
+	UpdateSeries(payload *models.Series) (*models.Series, error)
+	DeleteSeries(id uint) (*models.Series, error)
+	ForTenant(tenant *models.Tenant) ISeriesService
+}
+
+var _ ISeriesService = (*SeriesService)(nil)
+
+type SeriesService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewSeriesService(db *gorm.DB, logger *slog.Logger) *SeriesService {
+	return &SeriesService{db: db, logger: logger}
+}
+
+// ForTenant returns a service listing the books of tenant only. Series are
+// shared by every tenant.
+func (s *SeriesService) ForTenant(tenant *models.Tenant) ISeriesService {
+	return &SeriesService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID)}
+}
+
+// GetAllSeries returns a page of series sorted by name. A non empty name only
+// returns the series whose name contains it.
+func (s *SeriesService) GetAllSeries(name string, page, limit int) ([]*models.Series, int64, error) {
+	db := s.db.Model(&models.Series{})
+	if name != "" {
+		db = db.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(name)+"%")
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting series", "error", err)
+		return nil, 0, fmt.Errorf("error while counting series : %w", err)
+	}
+
+	var series []*models.Series
+	offset := (page - 1) * limit
+	if err := db.Order("name COLLATE NOCASE, id").Limit(limit).Offset(offset).Find(&series).Error; err != nil {
+		s.logger.Error("error fetching series", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching series : %w", err)
+	}
+	s.logger.Info("fetched series", "count", len(series), "total", total, "page", page, "limit", limit)
+	return series, total, nil
+}
+
+func (s *SeriesService) GetSeries(id uint) (*models.Series, error) {
+	var series models.Series
+	if err := s.db.First(&series, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("series not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrSeriesNotFound, id)
+		}
+		s.logger.Error("error fetching series", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the series : %w", err)
+	}
+	s.logger.Info("fetched series", "series", series)
+	return &series, nil
+}
+
+// GetSeriesBooks returns a page of the books of a series in reading order.
+func (s *SeriesService) GetSeriesBooks(id uint, page, limit int) ([]*models.Book, int64, error) {
+	if _, err := s.GetSeries(id); err != nil {
+		return nil, 0, err
+	}
+
+	db := s.db.Model(&models.Book{}).Where("series_id = ?", id)
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting series books", "id", id, "error", err)
+		return nil, 0, fmt.Errorf("error while counting series books : %w", err)
+	}
+
+	var books []*models.Book
+	offset := (page - 1) * limit
+	if err := db.Scopes(withDetails).Order(readingOrder).Limit(limit).Offset(offset).Find(&books).Error; err != nil {
+		s.logger.Error("error fetching series books", "id", id, "error", err)
+		return nil, 0, fmt.Errorf("error while fetching series books : %w", err)
+	}
+	s.logger.Info("fetched series books", "id", id, "count", len(books), "total", total, "page", page, "limit", limit)
+	return books, total, nil
+}
+
+func (s *SeriesService) CreateSeries(series *models.Series) (*models.Series, error) {
+	series.Name = models.NormalizeName(series.Name)
+	if err := s.checkName(series); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(series).Error; err != nil {
+		s.logger.Error("failed to create new series", "error", err)
+		return nil, fmt.Errorf("failed to create new series : %w", err)
+	}
+	s.logger.Info("created new series", "series", series)
+	return series, nil
+}
+
+// UpdateSeries renames the series identified by payload.ID.
+func (s *SeriesService) UpdateSeries(payload *models.Series) (*models.Series, error) {
+	series, err := s.GetSeries(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	series.Name = models.NormalizeName(payload.Name)
+	if err := s.checkName(series); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(series).Error; err != nil {
+		s.logger.Error("error saving updated series", "series", series, "error", err)
+		return nil, fmt.Errorf("error while saving the series : %w", err)
+	}
+	s.logger.Info("updated series", "series", series)
+	return series, nil
+}
+
+// DeleteSeries deletes a series that has no books in any tenant, trashed books
+// included.
+func (s *SeriesService) DeleteSeries(id uint) (*models.Series, error) {
+	series, err := s.GetSeries(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := allTenants(s.db).Unscoped().Model(&models.Book{}).Where("series_id = ?", id).Count(&count).Error; err != nil {
+		s.logger.Error("error counting series books", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the series : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("series to delete has books", "id", id, "books", count)
+		return nil, fmt.Errorf("%w: %d books", ErrSeriesInUse, count)
+	}
+
+	if err := s.db.Delete(series).Error; err != nil {
+		s.logger.Error("error deleting series", "series", series, "error", err)
+		return nil, fmt.Errorf("error while deleting the series : %w", err)
+	}
+	s.logger.Info("deleted series", "series", series)
+	return series, nil
+}
+
+// checkName makes sure no other series has the name of series, ignoring case.
+func (s *SeriesService) checkName(series *models.Series) error {
+	var count int64
+	err := s.db.Model(&models.Series{}).
+		Where("name = ? COLLATE NOCASE AND id <> ?", series.Name, series.ID).
+		Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking series name", "name", series.Name, "error", err)
+		return fmt.Errorf("error while checking the series name : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("series already exists", "name", series.Name)
+		return fmt.Errorf("%w: %s", ErrDuplicateSeries, series.Name)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeries(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	seriesService := NewSeriesService(service.db, service.logger)
+
+	saga, err := seriesService.CreateSeries(&models.Series{Name: " The  Saga "})
+	assert.NoError(t, err)
+	assert.Equal(t, "The Saga", saga.Name)
+
+	position := func(p float64) *float64 { return &p }
+	place := func(id uint, p *float64) {
+		book, _ := service.GetBook(id)
+		book.SeriesID, book.SeriesPosition = &saga.ID, p
+		_, err := service.UpdateBook(book)
+		assert.NoError(t, err)
+	}
+	place(1, position(2))
+	place(2, position(1))
+	place(3, nil)
+	novella, err := service.CreateBook(&models.Book{Title: "Book One and a Half", Author: "Author A", Year: 2024, SeriesID: &saga.ID, SeriesPosition: position(1.5)})
+	assert.NoError(t, err)
+
+	t.Run("series names are unique", func(t *testing.T) {
+		_, err := seriesService.CreateSeries(&models.Series{Name: "the saga"})
+		assert.ErrorIs(t, err, ErrDuplicateSeries)
+
+		series, total, err := seriesService.GetAllSeries("saga", 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, "The Saga", series[0].Name)
+	})
+
+	t.Run("books are listed in reading order", func(t *testing.T) {
+		books, total, err := seriesService.GetSeriesBooks(saga.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+
+		var titles []string
+		for _, book := range books {
+			titles = append(titles, book.Title)
+		}
+		assert.Equal(t, []string{"Book Two", "Book One and a Half", "Book One", "Book Three"}, titles)
+		assert.Equal(t, 1.5, *books[1].SeriesPosition)
+
+		_, _, err = seriesService.GetSeriesBooks(99, 1, 10)
+		assert.ErrorIs(t, err, ErrSeriesNotFound)
+	})
+
+	t.Run("books know their neighbors", func(t *testing.T) {
+		previous, next, err := service.GetSeriesNeighbors(novella)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), previous)
+		assert.Equal(t, uint(1), next)
+
+		book, _ := service.GetBook(2)
+		previous, next, _ = service.GetSeriesNeighbors(book)
+		assert.Zero(t, previous)
+		assert.Equal(t, novella.ID, next)
+
+		book, _ = service.GetBook(3)
+		previous, next, _ = service.GetSeriesNeighbors(book)
+		assert.Equal(t, uint(1), previous)
+		assert.Zero(t, next)
+
+		previous, next, _ = service.GetSeriesNeighbors(&models.Book{})
+		assert.Zero(t, previous)
+		assert.Zero(t, next)
+	})
+
+	t.Run("books can only be part of existing series", func(t *testing.T) {
+		missing := uint(99)
+		_, err := service.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2024, SeriesID: &missing})
+		assert.ErrorIs(t, err, ErrUnknownSeries)
+
+		book, err := service.PatchBook(3, 0, func(book *models.Book) error {
+			book.SeriesID = nil
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, book.SeriesID)
+	})
+
+	t.Run("series with books can't be deleted", func(t *testing.T) {
+		_, err := seriesService.DeleteSeries(saga.ID)
+		assert.ErrorIs(t, err, ErrSeriesInUse)
+
+		empty, _ := seriesService.CreateSeries(&models.Series{Name: "Empty"})
+		_, err = seriesService.DeleteSeries(empty.ID)
+		assert.NoError(t, err)
+
+		_, err = seriesService.GetSeries(empty.ID)
+		assert.ErrorIs(t, err, ErrSeriesNotFound)
+	})
+
+	t.Run("series only list the books of the tenant", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		ours, theirs := seriesService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), seriesService.ForTenant(springfield)
+		_, before, _ := ours.GetSeriesBooks(saga.ID, 1, 10)
+
+		theirBook, err := service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Nine", Author: "Author I", Year: 2024, SeriesID: &saga.ID})
+		assert.NoError(t, err)
+
+		books, total, err := theirs.GetSeriesBooks(saga.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, theirBook.ID, books[0].ID)
+
+		_, total, err = ours.GetSeriesBooks(saga.ID, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, before, total)
+
+		theirSaga, _ := theirs.CreateSeries(&models.Series{Name: "Their Saga"})
+		_, err = service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Ten", Author: "Author J", Year: 2024, SeriesID: &theirSaga.ID})
+		assert.NoError(t, err)
+		_, err = ours.DeleteSeries(theirSaga.ID)
+		assert.ErrorIs(t, err, ErrSeriesInUse)
+	})
+}
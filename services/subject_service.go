@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSubjectNotFound  = errors.New("subject not found")
+	ErrSubjectInUse     = errors.New("subject has subjects or books")
+	ErrDuplicateSubject = errors.New("subject already exists")
+	ErrSubjectCycle     = errors.New("subject can't be its own ancestor")
+)
+
+type ISubjectService interface {
+	GetAllSubjects(name string, page, limit int) ([]*models.Subject, int64, error)
+	GetSubject(id uint) (*models.Subject, error)
+	CreateSubject(subject *models.Subject) (*models.Subject, error)
+	UpdateSubject(payload *models.Subject) (*models.Subject, error)
+	DeleteSubject(id uint) (*models.Subject, error)
+}
+
+var _ ISubjectService = (*SubjectService)(nil)
+
+type SubjectService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewSubjectService(db *gorm.DB, logger *slog.Logger) *SubjectService {
+	return &SubjectService{db: db, logger: logger}
+}
+
+// GetAllSubjects returns a page of subjects sorted by name. A non empty name
+// only returns the subjects whose name contains it.
+func (s *SubjectService) GetAllSubjects(name string, page, limit int) ([]*models.Subject, int64, error) {
+	db := s.db.Model(&models.Subject{})
+	if name != "" {
+		db = db.Where(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(name)+"%")
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting subjects", "error", err)
+		return nil, 0, fmt.Errorf("error while counting subjects : %w", err)
+	}
+
+	var subjects []*models.Subject
+	offset := (page - 1) * limit
+	if err := db.Order("name COLLATE NOCASE, id").Limit(limit).Offset(offset).Find(&subjects).Error; err != nil {
+		s.logger.Error("error fetching subjects", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching subjects : %w", err)
+	}
+	s.logger.Info("fetched subjects", "count", len(subjects), "total", total, "page", page, "limit", limit)
+	return subjects, total, nil
+}
+
+// GetSubject returns the subject with the given id along with its children.
+func (s *SubjectService) GetSubject(id uint) (*models.Subject, error) {
+	var subject models.Subject
+	err := s.db.
+		Preload("Children", func(db *gorm.DB) *gorm.DB { return db.Order("name COLLATE NOCASE, id") }).
+		First(&subject, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("subject not found", "id", id)
+			return nil, fmt.Errorf("%w: id %d", ErrSubjectNotFound, id)
+		}
+		s.logger.Error("error fetching subject", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the subject : %w", err)
+	}
+	s.logger.Info("fetched subject", "subject", subject)
+	return &subject, nil
+}
+
+func (s *SubjectService) CreateSubject(subject *models.Subject) (*models.Subject, error) {
+	subject.Name = models.NormalizeName(subject.Name)
+	if err := s.checkSubject(subject); err != nil {
+		return nil, err
+	}
+	if err := s.db.Omit("Children").Create(subject).Error; err != nil {
+		s.logger.Error("failed to create new subject", "error", err)
+		return nil, fmt.Errorf("failed to create new subject : %w", err)
+	}
+	s.logger.Info("created new subject", "subject", subject)
+	return subject, nil
+}
+
+// UpdateSubject renames the subject identified by payload.ID and moves it
+// under the parent of payload.
+func (s *SubjectService) UpdateSubject(payload *models.Subject) (*models.Subject, error) {
+	subject, err := s.GetSubject(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	subject.Name = models.NormalizeName(payload.Name)
+	subject.ParentID = payload.ParentID
+	if err := s.checkSubject(subject); err != nil {
+		return nil, err
+	}
+	if err := s.db.Omit("Children").Save(subject).Error; err != nil {
+		s.logger.Error("error saving updated subject", "subject", subject, "error", err)
+		return nil, fmt.Errorf("error while saving the subject : %w", err)
+	}
+	s.logger.Info("updated subject", "subject", subject)
+	return subject, nil
+}
+
+// DeleteSubject deletes a subject that has no children and isn't assigned to
+// any book of any tenant, trashed books included.
+func (s *SubjectService) DeleteSubject(id uint) (*models.Subject, error) {
+	subject, err := s.GetSubject(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(subject.Children) > 0 {
+		s.logger.Warn("subject to delete has children", "id", id, "children", len(subject.Children))
+		return nil, fmt.Errorf("%w: %d subjects", ErrSubjectInUse, len(subject.Children))
+	}
+
+	var count int64
+	if err := allTenants(s.db).Model(&models.BookSubject{}).Where("subject_id = ?", id).Count(&count).Error; err != nil {
+		s.logger.Error("error counting subject books", "id", id, "error", err)
+		return nil, fmt.Errorf("error while deleting the subject : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("subject to delete is assigned to books", "id", id, "books", count)
+		return nil, fmt.Errorf("%w: %d books", ErrSubjectInUse, count)
+	}
+
+	if err := s.db.Delete(subject).Error; err != nil {
+		s.logger.Error("error deleting subject", "subject", subject, "error", err)
+		return nil, fmt.Errorf("error while deleting the subject : %w", err)
+	}
+	s.logger.Info("deleted subject", "subject", subject)
+	return subject, nil
+}
+
+// checkSubject makes sure the parent of subject exists and isn't subject or
+// one of its descendants, and that no sibling of subject has its name,
+// ignoring case.
+func (s *SubjectService) checkSubject(subject *models.Subject) error {
+	if subject.ParentID != nil {
+		parentID := *subject.ParentID
+		var parent models.Subject
+		if err := s.db.First(&parent, parentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				s.logger.Warn("unknown parent subject", "parent_id", parentID)
+				return fmt.Errorf("%w: id %d", ErrUnknownSubject, parentID)
+			}
+			s.logger.Error("error fetching parent subject", "parent_id", parentID, "error", err)
+			return fmt.Errorf("error while checking the subject parent : %w", err)
+		}
+
+		if subject.ID != 0 {
+			var cycles int64
+			err := s.db.Model(&models.Subject{}).Where("id = ? AND id IN (?)", parentID, subjectTree(s.db, subject.ID)).Count(&cycles).Error
+			if err != nil {
+				s.logger.Error("error checking subject parent", "id", subject.ID, "error", err)
+				return fmt.Errorf("error while checking the subject parent : %w", err)
+			}
+			if cycles > 0 {
+				s.logger.Warn("subject moved under itself", "id", subject.ID, "parent_id", parentID)
+				return fmt.Errorf("%w: id %d under %d", ErrSubjectCycle, subject.ID, parentID)
+			}
+		}
+	}
+
+	var count int64
+	err := s.db.Model(&models.Subject{}).
+		Where("name = ? COLLATE NOCASE AND id <> ? AND parent_id IS ?", subject.Name, subject.ID, subject.ParentID).
+		Count(&count).Error
+	if err != nil {
+		s.logger.Error("error checking subject name", "name", subject.Name, "error", err)
+		return fmt.Errorf("error while checking the subject name : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("subject already exists", "name", subject.Name, "parent_id", subject.ParentID)
+		return fmt.Errorf("%w: %s", ErrDuplicateSubject, subject.Name)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubjects(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	subjectService := NewSubjectService(service.db, service.logger)
+
+	create := func(name string, parentID *uint) *models.Subject {
+		subject, err := subjectService.CreateSubject(&models.Subject{Name: name, ParentID: parentID})
+		assert.NoError(t, err)
+		return subject
+	}
+	fiction := create("Fiction", nil)
+	mystery := create("Mystery", &fiction.ID)
+	cozy := create("Cozy", &mystery.ID)
+	history := create("History", nil)
+
+	t.Run("subjects form a hierarchy", func(t *testing.T) {
+		subject, err := subjectService.GetSubject(fiction.ID)
+		assert.NoError(t, err)
+		assert.Len(t, subject.Children, 1)
+		assert.Equal(t, "Mystery", subject.Children[0].Name)
+
+		_, err = subjectService.CreateSubject(&models.Subject{Name: "mystery", ParentID: &fiction.ID})
+		assert.ErrorIs(t, err, ErrDuplicateSubject)
+
+		_, err = subjectService.CreateSubject(&models.Subject{Name: "Mystery", ParentID: &history.ID})
+		assert.NoError(t, err)
+
+		missing := uint(99)
+		_, err = subjectService.CreateSubject(&models.Subject{Name: "Nowhere", ParentID: &missing})
+		assert.ErrorIs(t, err, ErrUnknownSubject)
+
+		subjects, total, err := subjectService.GetAllSubjects("mys", 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Len(t, subjects, 2)
+	})
+
+	t.Run("a subject can't be moved under itself", func(t *testing.T) {
+		_, err := subjectService.UpdateSubject(&models.Subject{Model: fiction.Model, Name: "Fiction", ParentID: &cozy.ID})
+		assert.ErrorIs(t, err, ErrSubjectCycle)
+
+		_, err = subjectService.UpdateSubject(&models.Subject{Model: fiction.Model, Name: "Fiction", ParentID: &fiction.ID})
+		assert.ErrorIs(t, err, ErrSubjectCycle)
+
+		moved, err := subjectService.UpdateSubject(&models.Subject{Model: cozy.Model, Name: "Cozy Mystery", ParentID: &fiction.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, fiction.ID, *moved.ParentID)
+
+		_, err = subjectService.UpdateSubject(&models.Subject{Model: cozy.Model, Name: "Cozy", ParentID: &mystery.ID})
+		assert.NoError(t, err)
+	})
+
+	t.Run("books are assigned subjects", func(t *testing.T) {
+		book, err := service.GetBook(1)
+		assert.NoError(t, err)
+		book.Subjects = []models.BookSubject{{SubjectID: cozy.ID}, {SubjectID: history.ID}, {SubjectID: cozy.ID}}
+		book, err = service.UpdateBook(book)
+		assert.NoError(t, err)
+		assert.Len(t, book.Subjects, 2)
+
+		book, _ = service.GetBook(1)
+		assert.Len(t, book.Subjects, 2)
+		assert.Equal(t, "Cozy", book.Subjects[0].Subject.Name)
+
+		_, err = service.CreateBook(&models.Book{Title: "Book Two", Author: "Author B", Year: 2022, Subjects: []models.BookSubject{{SubjectID: mystery.ID}}})
+		assert.NoError(t, err)
+
+		_, err = service.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2022, Subjects: []models.BookSubject{{SubjectID: 99}}})
+		assert.ErrorIs(t, err, ErrUnknownSubject)
+	})
+
+	t.Run("filtering includes descendant subjects", func(t *testing.T) {
+		books, total, err := service.GetAllBooks(BookQuery{Page: 1, Limit: 10, Subject: fiction.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, "Book One", books[0].Title)
+
+		_, total, _ = service.GetAllBooks(BookQuery{Page: 1, Limit: 10, Subject: cozy.ID})
+		assert.Equal(t, int64(1), total)
+
+		_, total, _ = service.GetAllBooks(BookQuery{Page: 1, Limit: 10, Subject: 99})
+		assert.Equal(t, int64(0), total)
+	})
+
+	t.Run("subjects are counted with their descendants", func(t *testing.T) {
+		counts, err := service.GetSubjectCounts(BookQuery{})
+		assert.NoError(t, err)
+
+		books := make(map[string]int64)
+		for _, count := range counts {
+			books[count.Name] = count.Books
+		}
+		assert.Equal(t, map[string]int64{"Fiction": 2, "Mystery": 2, "Cozy": 1, "History": 1}, books)
+		assert.Equal(t, "Fiction", counts[0].Name)
+
+		counts, err = service.GetSubjectCounts(BookQuery{YearFrom: 2022})
+		assert.NoError(t, err)
+		assert.Len(t, counts, 2)
+	})
+
+	t.Run("subjects in use can't be deleted", func(t *testing.T) {
+		_, err := subjectService.DeleteSubject(mystery.ID)
+		assert.ErrorIs(t, err, ErrSubjectInUse)
+
+		_, err = subjectService.DeleteSubject(cozy.ID)
+		assert.ErrorIs(t, err, ErrSubjectInUse)
+
+		_, err = service.PurgeBook(1, 0)
+		assert.NoError(t, err)
+
+		_, err = subjectService.DeleteSubject(cozy.ID)
+		assert.NoError(t, err)
+
+		_, err = subjectService.GetSubject(cozy.ID)
+		assert.ErrorIs(t, err, ErrSubjectNotFound)
+	})
+
+	t.Run("subjects are shared by tenants", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		theirs := service.ForTenant(springfield)
+		poetry := create("Poetry", nil)
+
+		_, err = theirs.CreateBook(&models.Book{Title: "Book Eleven", Author: "Author K", Year: 2024, Subjects: []models.BookSubject{{SubjectID: poetry.ID}}})
+		assert.NoError(t, err)
+
+		counts, err := theirs.GetSubjectCounts(BookQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, counts, 1)
+		assert.Equal(t, "Poetry", counts[0].Name)
+
+		counts, err = service.ForTenant(&models.Tenant{ID: models.DefaultTenant}).GetSubjectCounts(BookQuery{})
+		assert.NoError(t, err)
+		for _, count := range counts {
+			assert.NotEqual(t, "Poetry", count.Name)
+		}
+
+		_, err = subjectService.DeleteSubject(poetry.ID)
+		assert.ErrorIs(t, err, ErrSubjectInUse)
+	})
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownSubject = errors.New("unknown subject")
+)
+
+// subjectTree selects the ids of the subject with the given id and of all
+// its descendants.
+func subjectTree(db *gorm.DB, id uint) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Raw(`WITH RECURSIVE tree(id) AS (
+		SELECT id FROM subjects WHERE id = ? AND deleted_at IS NULL
+		UNION
+		SELECT subjects.id FROM subjects JOIN tree ON subjects.parent_id = tree.id WHERE subjects.deleted_at IS NULL
+	) SELECT id FROM tree`, id)
+}
+
+// withSubjects loads the subjects of the books.
+func withSubjects(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Subjects", func(db *gorm.DB) *gorm.DB { return db.Order("subject_id") }).
+		Preload("Subjects.Subject")
+}
+
+// loadSubjects loads the subjects of books that were read without
+// withSubjects.
+func loadSubjects(db *gorm.DB, books []*models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+
+	var assigned []models.BookSubject
+	if err := db.Preload("Subject").Where("book_id IN ?", ids).Order("subject_id").Find(&assigned).Error; err != nil {
+		return err
+	}
+
+	byBook := make(map[uint][]models.BookSubject)
+	for _, subject := range assigned {
+		byBook[subject.BookID] = append(byBook[subject.BookID], subject)
+	}
+	for _, book := range books {
+		book.Subjects = byBook[book.ID]
+	}
+	return nil
+}
+
+// assignSubjects resolves the subjects of book before it is saved. Every
+// subject must exist, and a subject listed more than once is assigned once.
+func assignSubjects(db *gorm.DB, book *models.Book) error {
+	var subjects []models.BookSubject
+	seen := make(map[uint]bool)
+	for _, assigned := range book.Subjects {
+		if seen[assigned.SubjectID] {
+			continue
+		}
+		seen[assigned.SubjectID] = true
+
+		var subject models.Subject
+		if err := db.First(&subject, assigned.SubjectID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: id %d", ErrUnknownSubject, assigned.SubjectID)
+			}
+			return err
+		}
+		subjects = append(subjects, models.BookSubject{SubjectID: subject.ID, Subject: &subject})
+	}
+	book.Subjects = subjects
+	return nil
+}
+
+// saveSubjects replaces the stored subjects of book with its current ones.
+func saveSubjects(db *gorm.DB, book *models.Book) error {
+	if err := db.Where("book_id = ?", book.ID).Delete(&models.BookSubject{}).Error; err != nil {
+		return err
+	}
+	if len(book.Subjects) == 0 {
+		return nil
+	}
+
+	for i := range book.Subjects {
+		book.Subjects[i].BookID = book.ID
+	}
+	return db.Omit("Subject").Create(&book.Subjects).Error
+}
+
+// GetSubjectCounts returns the subjects of the books matching the filters of
+// query, with the number of those books assigned to each subject or to one of
+// its descendants. Subjects are sorted by that number, largest first.
+func (s *BookService) GetSubjectCounts(query BookQuery) ([]*models.SubjectCount, error) {
+	books := s.db.Model(&models.Book{}).Scopes(query.filters).Select("id")
+
+	var counts []*models.SubjectCount
+	err := s.db.Raw(`WITH RECURSIVE closure(ancestor_id, subject_id) AS (
+		SELECT id, id FROM subjects WHERE deleted_at IS NULL
+		UNION
+		SELECT closure.ancestor_id, subjects.id FROM subjects JOIN closure ON subjects.parent_id = closure.subject_id WHERE subjects.deleted_at IS NULL
+	)
+	SELECT subjects.*, COUNT(DISTINCT book_subjects.book_id) AS books
+	FROM closure
+	JOIN subjects ON subjects.id = closure.ancestor_id
+	JOIN book_subjects ON book_subjects.subject_id = closure.subject_id
+	WHERE book_subjects.book_id IN (?)
+	GROUP BY subjects.id
+	ORDER BY books DESC, subjects.name COLLATE NOCASE, subjects.id`, books).Scan(&counts).Error
+	if err != nil {
+		s.logger.Error("error counting subjects", "error", err)
+		return nil, fmt.Errorf("error while counting subjects : %w", err)
+	}
+	s.logger.Info("counted subjects", "count", len(counts))
+	return counts, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTenantNotFound  = errors.New("tenant not found")
+	ErrDuplicateTenant = errors.New("tenant already exists")
+)
+
+type ITenantService interface {
+	GetAllTenants(page, limit int) ([]*models.Tenant, int64, error)
+	GetTenant(id string) (*models.Tenant, error)
+	CreateTenant(tenant *models.Tenant) (*models.Tenant, error)
+	UpdateTenant(payload *models.Tenant) (*models.Tenant, error)
+}
+
+var _ ITenantService = (*TenantService)(nil)
+
+type TenantService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewTenantService(db *gorm.DB, logger *slog.Logger) *TenantService {
+	return &TenantService{db: db, logger: logger}
+}
+
+// GetAllTenants returns a page of tenants sorted by id.
+func (s *TenantService) GetAllTenants(page, limit int) ([]*models.Tenant, int64, error) {
+	db := s.db.Model(&models.Tenant{})
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		s.logger.Error("error counting tenants", "error", err)
+		return nil, 0, fmt.Errorf("error while counting tenants : %w", err)
+	}
+
+	var tenants []*models.Tenant
+	offset := (page - 1) * limit
+	if err := db.Order("id").Limit(limit).Offset(offset).Find(&tenants).Error; err != nil {
+		s.logger.Error("error fetching tenants", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching tenants : %w", err)
+	}
+	s.logger.Info("fetched tenants", "count", len(tenants), "total", total, "page", page, "limit", limit)
+	return tenants, total, nil
+}
+
+func (s *TenantService) GetTenant(id string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := s.db.Where("id = ?", id).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("tenant not found", "id", id)
+			return nil, fmt.Errorf("%w: id %q", ErrTenantNotFound, id)
+		}
+		s.logger.Error("error fetching tenant", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the tenant : %w", err)
+	}
+	return &tenant, nil
+}
+
+func (s *TenantService) CreateTenant(tenant *models.Tenant) (*models.Tenant, error) {
+	var count int64
+	if err := s.db.Model(&models.Tenant{}).Where("id = ?", tenant.ID).Count(&count).Error; err != nil {
+		s.logger.Error("error checking tenant id", "id", tenant.ID, "error", err)
+		return nil, fmt.Errorf("error while checking the tenant id : %w", err)
+	}
+	if count > 0 {
+		s.logger.Warn("tenant already exists", "id", tenant.ID)
+		return nil, fmt.Errorf("%w: id %q", ErrDuplicateTenant, tenant.ID)
+	}
+
+	if err := s.db.Create(tenant).Error; err != nil {
+		s.logger.Error("failed to create new tenant", "error", err)
+		return nil, fmt.Errorf("failed to create new tenant : %w", err)
+	}
+	s.logger.Info("created new tenant", "tenant", tenant)
+	return tenant, nil
+}
+
+// UpdateTenant replaces the name and the settings of the tenant identified by
+// payload.ID.
+func (s *TenantService) UpdateTenant(payload *models.Tenant) (*models.Tenant, error) {
+	tenant, err := s.GetTenant(payload.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant.Name = payload.Name
+	tenant.Settings = payload.Settings
+	if err := s.db.Save(tenant).Error; err != nil {
+		s.logger.Error("error saving updated tenant", "tenant", tenant, "error", err)
+		return nil, fmt.Errorf("error while saving the tenant : %w", err)
+	}
+	s.logger.Info("updated tenant", "tenant", tenant)
+	return tenant, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenants(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	tenantService := NewTenantService(service.db, service.logger)
+	isbn := func(s string) *string { return &s }
+
+	springfield, err := tenantService.CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+	assert.NoError(t, err)
+	shelbyville, err := tenantService.CreateTenant(&models.Tenant{ID: "shelbyville", Name: "Shelbyville Elementary"})
+	assert.NoError(t, err)
+	defaultTenant, err := tenantService.GetTenant(models.DefaultTenant)
+	assert.NoError(t, err)
+
+	ours := service.ForTenant(springfield)
+	theirs := service.ForTenant(shelbyville)
+
+	book, err := ours.CreateBook(&models.Book{Title: "Book Four", Author: "Author D", Year: 2024, ISBN: isbn("9780306406157")})
+	assert.NoError(t, err)
+
+	t.Run("tenants are managed", func(t *testing.T) {
+		_, err := tenantService.CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield"})
+		assert.ErrorIs(t, err, ErrDuplicateTenant)
+
+		_, err = tenantService.GetTenant("ogdenville")
+		assert.ErrorIs(t, err, ErrTenantNotFound)
+
+		tenants, total, err := tenantService.GetAllTenants(1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, models.DefaultTenant, tenants[0].ID)
+	})
+
+	t.Run("books are only visible to their tenant", func(t *testing.T) {
+		books, total, err := ours.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, book.ID, books[0].ID)
+
+		_, total, err = theirs.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Zero(t, total)
+
+		_, total, err = service.ForTenant(defaultTenant).GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+
+		_, err = theirs.GetBook(book.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = theirs.GetBookByISBN("9780306406157")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		works, _, err := theirs.GetBooksByWork(BookQuery{Page: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, works)
+
+		books, _, err = theirs.GetBooksAfter(BookQuery{Page: 1, Limit: 10}, "")
+		assert.NoError(t, err)
+		assert.Empty(t, books)
+
+		exported := 0
+		assert.NoError(t, theirs.ExportBooks(BookQuery{Page: 1, Limit: 10}, func(book *models.Book) error {
+			exported++
+			return nil
+		}))
+		assert.Zero(t, exported)
+	})
+
+	t.Run("books can't be modified by other tenants", func(t *testing.T) {
+		_, err := theirs.UpdateBook(&models.Book{Model: book.Model, Title: "Stolen", Author: "Author D", Year: 2024})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = theirs.PatchBook(book.ID, 0, func(book *models.Book) error {
+			book.Title = "Stolen"
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = theirs.DeleteBook(book.ID, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = theirs.PurgeBook(book.ID, 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		results, err := theirs.BulkWrite([]BulkOperation{{Op: BulkDelete, ID: book.ID}}, false)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, ErrNotFound)
+
+		_, err = ours.DeleteBook(book.ID, 0)
+		assert.NoError(t, err)
+		_, err = theirs.RestoreBook(book.ID)
+		assert.ErrorIs(t, err, ErrNotInTrash)
+		trash, _, err := theirs.GetTrashedBooks(1, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, trash)
+
+		restored, err := ours.RestoreBook(book.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Four", restored.Title)
+	})
+
+	t.Run("isbns are unique within a tenant", func(t *testing.T) {
+		_, err := ours.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2024, ISBN: isbn("0-306-40615-2")})
+		assert.ErrorIs(t, err, ErrDuplicateISBN)
+
+		copied, err := theirs.CreateBook(&models.Book{Title: "Book Four", Author: "Author D", Year: 2024, ISBN: isbn("0-306-40615-2")})
+		assert.NoError(t, err)
+		fetched, err := theirs.GetBookByISBN("9780306406157")
+		assert.NoError(t, err)
+		assert.Equal(t, copied.ID, fetched.ID)
+
+		results, err := theirs.ImportBooks([]*models.Book{
+			{Title: "Book Four", Author: "Author D", Year: 2024},
+			{Title: "Book Six", Author: "Author F", Year: 2024, ISBN: isbn("9780306406157")},
+			{Title: "Book One", Author: "Author A", Year: 2021, ISBN: isbn("9781861972712")},
+		}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportDuplicate, results[0].Status)
+		assert.Equal(t, ImportDuplicate, results[1].Status)
+		assert.Equal(t, ImportCreated, results[2].Status)
+	})
+
+	t.Run("settings apply to the books of the tenant", func(t *testing.T) {
+		shelbyville.Settings = models.TenantSettings{DefaultLanguage: "es", RequireISBN: true}
+		updated, err := tenantService.UpdateTenant(shelbyville)
+		assert.NoError(t, err)
+		theirs := service.ForTenant(updated)
+
+		_, err = theirs.CreateBook(&models.Book{Title: "Book Seven", Author: "Author G", Year: 2024})
+		assert.ErrorIs(t, err, ErrISBNRequired)
+
+		created, err := theirs.CreateBook(&models.Book{Title: "Book Seven", Author: "Author G", Year: 2024, ISBN: isbn("9781861972712")})
+		assert.NoError(t, err)
+		assert.Equal(t, "es", created.Language)
+
+		_, err = theirs.PatchBook(created.ID, 0, func(book *models.Book) error {
+			book.ISBN = nil
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrISBNRequired)
+
+		created, err = ours.CreateBook(&models.Book{Title: "Book Eight", Author: "Author H", Year: 2024})
+		assert.NoError(t, err)
+		assert.Empty(t, created.Language)
+
+		_, before, err := theirs.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.NoError(t, err)
+		for _, dryRun := range []bool{true, false} {
+			results, err := theirs.ImportBooks([]*models.Book{
+				{Title: "Book Nine", Author: "Author I", Year: 2024},
+				{Title: "Book Ten", Author: "Author J", Year: 2024, ISBN: isbn("9780262033848")},
+			}, dryRun)
+			assert.NoError(t, err)
+			assert.Equal(t, ImportRejected, results[0].Status, dryRun)
+			assert.ErrorIs(t, results[0].Err, ErrISBNRequired)
+			assert.Equal(t, ImportCreated, results[1].Status, dryRun)
+		}
+		_, after, err := theirs.GetAllBooks(BookQuery{Page: 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, before+1, after)
+	})
+}
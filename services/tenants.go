@@ -0,0 +1,77 @@
+package services
+
+import (
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tenantBooks selects the ids of the books of a tenant, trashed ones included.
+const tenantBooks = "SELECT id FROM books WHERE tenant_id = ?"
+
+// tenantScope restricts the queries on the books, patrons and api_keys tables
+// to the rows of tenant, the queries on the rows belonging to a book, its
+// copies, their loans, its holds, notifications, credits and subjects, to the
+// rows of those books, and the queries on the ledger to the entries of the
+// patrons of tenant. Queries on other tables, eg: branches or authors shared
+// by every tenant, are left untouched. The conditions the query already has
+// are grouped first, so an OR among them can't match the rows of other
+// tenants.
+func tenantScope(tenant string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		stmt := db.Statement
+		if stmt.Table == "" {
+			model := stmt.Model
+			if model == nil {
+				model = stmt.Dest
+			}
+			if model == nil || stmt.Parse(model) != nil {
+				return db
+			}
+		}
+
+		var condition clause.Expression
+		switch stmt.Table {
+		case "books", "patrons", "api_keys":
+			condition = clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"}, Value: tenant}
+		case "copies", "holds", "notifications", "book_authors", "book_subjects":
+			condition = clause.Expr{
+				SQL:  "? IN (" + tenantBooks + ")",
+				Vars: []any{clause.Column{Table: clause.CurrentTable, Name: "book_id"}, tenant},
+			}
+		case "ledger_entries":
+			condition = clause.Expr{
+				SQL:  "? IN (SELECT id FROM patrons WHERE tenant_id = ?)",
+				Vars: []any{clause.Column{Table: clause.CurrentTable, Name: "patron_id"}, tenant},
+			}
+		case "loans":
+			condition = clause.Expr{
+				SQL:  "? IN (SELECT id FROM copies WHERE book_id IN (" + tenantBooks + "))",
+				Vars: []any{clause.Column{Table: clause.CurrentTable, Name: "copy_id"}, tenant},
+			}
+		default:
+			return db
+		}
+
+		if c, ok := stmt.Clauses["WHERE"]; ok {
+			if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+				c.Expression = clause.Where{Exprs: []clause.Expression{clause.And(where.Exprs...)}}
+				stmt.Clauses["WHERE"] = c
+			}
+		}
+		return db.Where(condition)
+	}
+}
+
+// scopeTenant returns db with every query scoped to the rows of tenant by
+// tenantScope.
+func scopeTenant(db *gorm.DB, tenant *models.Tenant) *gorm.DB {
+	return db.Scopes(tenantScope(tenant.ID)).Session(&gorm.Session{})
+}
+
+// allTenants returns db without the tenant scope, for the checks that have to
+// see the rows of every tenant, eg: before deleting a series their books may
+// be part of.
+func allTenants(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true})
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWorkNotFound = errors.New("work not found")
+)
+
+type IWorkService interface {
+	GetWork(id uint) (*models.Work, error)
+	UpdateWork(payload *models.Work) (*models.Work, error)
+	MergeBooks(id uint, bookIDs []uint) (*models.Work, error)
+	ForTenant(tenant *models.Tenant) IWorkService
+}
+
+var _ IWorkService = (*WorkService)(nil)
+
+type WorkService struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewWorkService(db *gorm.DB, logger *slog.Logger) *WorkService {
+	return &WorkService{db: db, logger: logger}
+}
+
+// ForTenant returns a service that only sees the works with editions among
+// the books of tenant, and only the editions of tenant.
+func (s *WorkService) ForTenant(tenant *models.Tenant) IWorkService {
+	return &WorkService{db: scopeTenant(s.db, tenant), logger: s.logger.With("tenant", tenant.ID)}
+}
+
+// GetWork returns the work along with every edition of it, oldest first.
+// Trashed books are left out.
+func (s *WorkService) GetWork(id uint) (*models.Work, error) {
+	var work models.Work
+	err := findWork(s.db, &work, id)
+	if err == nil {
+		err = s.db.Scopes(withDetails).Where("work_id = ?", id).Order("year, id").Find(&work.Editions).Error
+	}
+	if err != nil {
+		if errors.Is(err, ErrWorkNotFound) {
+			s.logger.Warn("work not found", "id", id)
+			return nil, err
+		}
+		s.logger.Error("error fetching work", "id", id, "error", err)
+		return nil, fmt.Errorf("error while fetching the work : %w", err)
+	}
+	s.logger.Info("fetched work", "id", work.ID, "editions", len(work.Editions))
+	return &work, nil
+}
+
+// UpdateWork renames the work identified by payload.ID.
+func (s *WorkService) UpdateWork(payload *models.Work) (*models.Work, error) {
+	var work models.Work
+	err := findWork(s.db, &work, payload.ID)
+	if err == nil {
+		err = s.db.Model(&work).Update("title", payload.Title).Error
+	}
+	if err != nil {
+		if errors.Is(err, ErrWorkNotFound) {
+			s.logger.Warn("work to update not found", "id", payload.ID)
+			return nil, err
+		}
+		s.logger.Error("error updating work", "id", payload.ID, "error", err)
+		return nil, fmt.Errorf("error while updating the work : %w", err)
+	}
+	s.logger.Info("updated work", "id", payload.ID, "title", payload.Title)
+	return s.GetWork(payload.ID)
+}
+
+// MergeBooks makes the given books editions of the work, within a single
+// transaction, and bumps the version of the books that moved. The works the
+// books leave are deleted once they have no editions left.
+func (s *WorkService) MergeBooks(id uint, bookIDs []uint) (*models.Work, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := findWork(tx, &models.Work{}, id); err != nil {
+			return err
+		}
+
+		var found []uint
+		if err := tx.Model(&models.Book{}).Where("id IN ?", bookIDs).Pluck("id", &found).Error; err != nil {
+			return err
+		}
+		for _, bookID := range bookIDs {
+			if !slices.Contains(found, bookID) {
+				return fmt.Errorf("%w: id %d", ErrNotFound, bookID)
+			}
+		}
+
+		err := tx.Model(&models.Book{}).
+			Where("id IN ? AND (work_id IS NULL OR work_id <> ?)", bookIDs, id).
+			Updates(map[string]any{"work_id": id, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		return pruneWorks(tx)
+	})
+	if err != nil {
+		if errors.Is(err, ErrWorkNotFound) || errors.Is(err, ErrNotFound) {
+			s.logger.Warn("merge rejected", "id", id, "books", bookIDs, "error", err)
+			return nil, err
+		}
+		s.logger.Error("error merging books into work", "id", id, "books", bookIDs, "error", err)
+		return nil, fmt.Errorf("error while merging books into the work : %w", err)
+	}
+	s.logger.Info("merged books into work", "id", id, "books", bookIDs)
+	return s.GetWork(id)
+}
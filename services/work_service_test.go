@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nsltharaka/booksapi/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWorks(t *testing.T) {
+	service, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	workService := NewWorkService(service.db, service.logger)
+
+	bookOne, _ := service.GetBook(1)
+	bookTwo, _ := service.GetBook(2)
+
+	t.Run("every book has a work of its own", func(t *testing.T) {
+		assert.NotNil(t, bookOne.WorkID)
+		assert.NotEqual(t, *bookOne.WorkID, *bookTwo.WorkID)
+
+		work, err := workService.GetWork(*bookOne.WorkID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book One", work.Title)
+		assert.Len(t, work.Editions, 1)
+		assert.Equal(t, "Author A", work.Editions[0].Authors[0].Author.Name)
+	})
+
+	t.Run("books can be created as editions of a work", func(t *testing.T) {
+		book, err := service.CreateBook(&models.Book{Title: "Livre Un", Author: "Author A", Year: 2022, WorkID: bookOne.WorkID})
+		assert.NoError(t, err)
+		assert.Equal(t, *bookOne.WorkID, *book.WorkID)
+
+		work, _ := workService.GetWork(*bookOne.WorkID)
+		assert.Len(t, work.Editions, 2)
+
+		missing := uint(99)
+		_, err = service.CreateBook(&models.Book{Title: "Book Five", Author: "Author E", Year: 2022, WorkID: &missing})
+		assert.ErrorIs(t, err, ErrUnknownWork)
+	})
+
+	t.Run("merging books into a work", func(t *testing.T) {
+		work, err := workService.MergeBooks(*bookOne.WorkID, []uint{2})
+		assert.NoError(t, err)
+		assert.Len(t, work.Editions, 3)
+
+		_, err = workService.GetWork(*bookTwo.WorkID)
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+
+		merged, _ := service.GetBook(2)
+		assert.Equal(t, bookTwo.Version+1, merged.Version)
+
+		_, err = workService.MergeBooks(*bookOne.WorkID, []uint{3, 99})
+		assert.ErrorIs(t, err, ErrNotFound)
+		three, _ := service.GetBook(3)
+		assert.NotEqual(t, *bookOne.WorkID, *three.WorkID)
+
+		_, err = workService.MergeBooks(99, []uint{3})
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+	})
+
+	t.Run("renaming a work", func(t *testing.T) {
+		work, err := workService.UpdateWork(&models.Work{Model: bookOne.Model, Title: "The One"})
+		assert.NoError(t, err)
+		assert.Equal(t, "The One", work.Title)
+
+		_, err = workService.UpdateWork(&models.Work{Title: "Nothing"})
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+	})
+
+	t.Run("listing books by work", func(t *testing.T) {
+		works, total, err := service.GetBooksByWork(BookQuery{Page: 1, Limit: 10, Sort: []SortField{{Field: "year", Desc: true}}})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, "Book Three", works[0].Editions[0].Title)
+		assert.Len(t, works[1].Editions, 3)
+		assert.Equal(t, 2022, works[1].Editions[0].Year)
+
+		works, total, err = service.GetBooksByWork(BookQuery{Page: 1, Limit: 10, YearFrom: 2022, YearTo: 2022})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Len(t, works[0].Editions, 2)
+
+		works, total, _ = service.GetBooksByWork(BookQuery{Page: 2, Limit: 1})
+		assert.Equal(t, int64(2), total)
+		assert.Len(t, works, 1)
+	})
+
+	t.Run("works without editions are deleted", func(t *testing.T) {
+		three, _ := service.GetBook(3)
+		_, err := service.PurgeBook(3, 0)
+		assert.NoError(t, err)
+
+		_, err = workService.GetWork(*three.WorkID)
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+	})
+
+	t.Run("books are created as works of their own", func(t *testing.T) {
+		book, err := service.CreateBook(&models.Book{Title: "Book Seven", Author: "Author G", Year: 2024})
+		assert.NoError(t, err)
+		assert.NotNil(t, book.WorkID)
+
+		work, err := workService.GetWork(*book.WorkID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Book Seven", work.Title)
+	})
+
+	t.Run("search is collapsed by work", func(t *testing.T) {
+		results, err := service.SearchBooks("author", 1, 10)
+		if errors.Is(err, ErrSearchUnavailable) {
+			t.Skip("sqlite built without fts5, run with -tags sqlite_fts5")
+		}
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		editions := map[string]int{}
+		for _, result := range results {
+			editions[result.Author] = result.Editions
+		}
+		assert.Equal(t, map[string]int{"Author A": 3, "Author G": 1}, editions)
+	})
+
+	t.Run("works of other tenants are out of reach", func(t *testing.T) {
+		springfield, err := NewTenantService(service.db, service.logger).CreateTenant(&models.Tenant{ID: "springfield", Name: "Springfield Elementary"})
+		assert.NoError(t, err)
+		ours, theirs := workService.ForTenant(&models.Tenant{ID: models.DefaultTenant}), workService.ForTenant(springfield)
+
+		_, err = service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Eight", Author: "Author H", Year: 2024, WorkID: bookOne.WorkID})
+		assert.ErrorIs(t, err, ErrUnknownWork)
+		theirBook, err := service.ForTenant(springfield).CreateBook(&models.Book{Title: "Book Eight", Author: "Author H", Year: 2024})
+		assert.NoError(t, err)
+
+		_, err = theirs.GetWork(*bookOne.WorkID)
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+		_, err = theirs.UpdateWork(&models.Work{Model: gorm.Model{ID: *bookOne.WorkID}, Title: "Their One"})
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+		_, err = theirs.MergeBooks(*bookOne.WorkID, []uint{theirBook.ID})
+		assert.ErrorIs(t, err, ErrWorkNotFound)
+		_, err = theirs.MergeBooks(*theirBook.WorkID, []uint{1})
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = ours.MergeBooks(*bookOne.WorkID, []uint{theirBook.ID})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		moved, _ := service.GetBook(theirBook.ID)
+		assert.Equal(t, *theirBook.WorkID, *moved.WorkID)
+
+		// Works shared by tenants only list the editions of the tenant.
+		service.db.Model(&models.Book{}).Where("id = ?", theirBook.ID).Update("work_id", *bookOne.WorkID)
+		work, err := ours.GetWork(*bookOne.WorkID)
+		assert.NoError(t, err)
+		assert.Len(t, work.Editions, 3)
+		work, err = theirs.GetWork(*bookOne.WorkID)
+		assert.NoError(t, err)
+		assert.Len(t, work.Editions, 1)
+		assert.Equal(t, theirBook.ID, work.Editions[0].ID)
+	})
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nsltharaka/booksapi/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnknownWork = errors.New("unknown work")
+)
+
+// assignWork makes sure the work of book exists and has editions db sees. A
+// book without a work gets a work of its own, named after it.
+func assignWork(db *gorm.DB, book *models.Book) error {
+	if book.WorkID == nil {
+		work := models.Work{Title: book.Title}
+		if err := db.Create(&work).Error; err != nil {
+			return err
+		}
+		book.WorkID = &work.ID
+		return nil
+	}
+
+	err := findWork(db, &models.Work{}, *book.WorkID)
+	if errors.Is(err, ErrWorkNotFound) {
+		return fmt.Errorf("%w: id %d", ErrUnknownWork, *book.WorkID)
+	}
+	return err
+}
+
+// findWork loads the work with the given id into work, as long as db sees one
+// of its editions, trashed ones included, so the works of other tenants can't
+// be found.
+func findWork(db *gorm.DB, work *models.Work, id uint) error {
+	editions := db.Unscoped().Model(&models.Book{}).Select("work_id")
+	err := db.Where("id IN (?)", editions).First(work, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: id %d", ErrWorkNotFound, id)
+	}
+	return err
+}
+
+// pruneWorks deletes the works that no book of any tenant, trashed ones
+// included, is an edition of anymore.
+func pruneWorks(db *gorm.DB) error {
+	editions := allTenants(db).Unscoped().Model(&models.Book{}).Select("work_id").Where("work_id IS NOT NULL")
+	return db.Unscoped().Where("id NOT IN (?)", editions).Delete(&models.Work{}).Error
+}
+
+// GetBooksByWork returns a page of the works that have books matching the
+// filters of query, each with its matching editions in the sort order of
+// query. Works are sorted by their first matching edition.
+func (s *BookService) GetBooksByWork(query BookQuery) ([]*models.Work, int64, error) {
+	ranked := s.db.Model(&models.Book{}).Scopes(query.filters).
+		Select("id, ROW_NUMBER() OVER (PARTITION BY work_id ORDER BY " + query.orderSQL() + ") AS edition_rank")
+	firstEditions := s.db.Table("(?) AS ranked", ranked).Select("id").Where("edition_rank = 1")
+
+	var total int64
+	if err := s.db.Model(&models.Book{}).Where("id IN (?)", firstEditions).Count(&total).Error; err != nil {
+		s.logger.Error("error counting works", "error", err)
+		return nil, 0, fmt.Errorf("error while counting works : %w", err)
+	}
+
+	var representatives []*models.Book
+	offset := (query.Page - 1) * query.Limit
+	err := s.db.Where("id IN (?)", firstEditions).Scopes(query.order).Limit(query.Limit).Offset(offset).Find(&representatives).Error
+	if err != nil {
+		s.logger.Error("error fetching works", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching works : %w", err)
+	}
+
+	workIDs := make([]uint, 0, len(representatives))
+	for _, book := range representatives {
+		if book.WorkID != nil {
+			workIDs = append(workIDs, *book.WorkID)
+		}
+	}
+
+	var works []*models.Work
+	if err := s.db.Where("id IN ?", workIDs).Find(&works).Error; err != nil {
+		s.logger.Error("error fetching works", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching works : %w", err)
+	}
+
+	var editions []*models.Book
+	if err := s.db.Scopes(query.filters, query.order, withDetails).Where("work_id IN ?", workIDs).Find(&editions).Error; err != nil {
+		s.logger.Error("error fetching editions", "error", err)
+		return nil, 0, fmt.Errorf("error while fetching works : %w", err)
+	}
+
+	byID := make(map[uint]*models.Work, len(works))
+	for _, work := range works {
+		byID[work.ID] = work
+	}
+	for _, edition := range editions {
+		work := byID[*edition.WorkID]
+		work.Editions = append(work.Editions, *edition)
+	}
+
+	page := make([]*models.Work, 0, len(workIDs))
+	for _, id := range workIDs {
+		if work, ok := byID[id]; ok {
+			page = append(page, work)
+		}
+	}
+	s.logger.Info("fetched books by work", "count", len(page), "total", total, "page", query.Page, "limit", query.Limit)
+	return page, total, nil
+}